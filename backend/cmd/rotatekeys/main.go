@@ -0,0 +1,48 @@
+// Command rotatekeys re-wraps data keys of reports and attachments with the
+// current master key from the config, seals the ones stored before the
+// encryption was turned on and builds the search terms of reports that have
+// none. Run it after every backend has been switched to the new
+// encryption.current_key, the old key can be removed afterwards.
+package main
+
+import (
+	"reports_system/internal/repository"
+	"reports_system/internal/service/encryption"
+	"reports_system/internal/session"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/envelope"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/storage"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	logging.Init()
+	logger := logging.GetLogger()
+
+	cfg := session.GetConfig()
+
+	keys, err := envelope.Load(cfg.Encryption)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	client, err := psqlclient.NewClient(cfg.DB)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	backend, err := storage.New(cfg.Storage)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	repos := repository.New(client, keys, logger)
+	blobs := storage.NewEncrypted(backend, keys)
+
+	logger.Infof("Rotating data keys to master key %q", cfg.Encryption.CurrentKey)
+	if err = encryption.NewService(repos.Report, repos.Attachment, blobs, logger).RotateKeys(); err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -11,8 +11,20 @@ import (
 	"reports_system/pkg/shutdown"
 	"syscall"
 	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
+// NewRouter creates the gin router that believes X-Forwarded-For only when it
+// comes from one of the trusted proxies.
+func NewRouter(cfg session.Listen) (*gin.Engine, error) {
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
+	return router, nil
+}
+
 type Server struct {
 	httpServer *http.Server
 }
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reports_system/internal/session"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func clientIP(t *testing.T, router *gin.Engine, remoteAddr, forwardedFor string) string {
+	t.Helper()
+
+	var ip string
+	router.GET("/ip", func(ctx *gin.Context) { ip = ctx.ClientIP() })
+
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	return ip
+}
+
+func TestNewRouterIgnoresForwardedForFromClients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router, err := NewRouter(session.Listen{TrustedProxies: []string{"172.28.0.10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ip := clientIP(t, router, "203.0.113.7:51234", "10.0.0.1"); ip != "203.0.113.7" {
+		t.Errorf("client ip = %s, forged X-Forwarded-For was believed", ip)
+	}
+}
+
+func TestNewRouterBelievesTrustedProxy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router, err := NewRouter(session.Listen{TrustedProxies: []string{"172.28.0.10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ip := clientIP(t, router, "172.28.0.10:40000", "203.0.113.7"); ip != "203.0.113.7" {
+		t.Errorf("client ip = %s, want the one set by the proxy", ip)
+	}
+}
+
+func TestNewRouterTrustsNobodyByDefault(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router, err := NewRouter(session.Listen{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ip := clientIP(t, router, "203.0.113.7:51234", "10.0.0.1"); ip != "203.0.113.7" {
+		t.Errorf("client ip = %s, forged X-Forwarded-For was believed", ip)
+	}
+}
+
+func TestNewRouterRejectsInvalidProxy(t *testing.T) {
+	if _, err := NewRouter(session.Listen{TrustedProxies: []string{"nginx"}}); err == nil {
+		t.Error("router was created with a proxy that is not an address")
+	}
+}
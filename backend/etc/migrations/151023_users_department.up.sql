@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN department VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+CREATE TABLE attachments (
+    id SERIAL NOT NULL UNIQUE,
+    reports_id INT REFERENCES reports(id) ON DELETE CASCADE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX attachments_reports_id_idx ON attachments (reports_id);
@@ -0,0 +1,9 @@
+DROP INDEX attachments_text_status_idx;
+
+DROP INDEX attachments_text_search_idx;
+
+ALTER TABLE attachments
+    DROP COLUMN text_search,
+    DROP COLUMN text_content,
+    DROP COLUMN text_claimed,
+    DROP COLUMN text_status;
@@ -0,0 +1,9 @@
+ALTER TABLE attachments
+    ADD COLUMN text_status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    ADD COLUMN text_claimed TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN text_content TEXT,
+    ADD COLUMN text_search TSVECTOR GENERATED ALWAYS AS (to_tsvector('russian', COALESCE(text_content, ''))) STORED;
+
+CREATE INDEX attachments_text_search_idx ON attachments USING GIN (text_search);
+
+CREATE INDEX attachments_text_status_idx ON attachments (text_status) WHERE text_status IN ('pending', 'processing');
@@ -0,0 +1,3 @@
+DROP TABLE users_templates;
+
+DROP TABLE templates;
@@ -0,0 +1,13 @@
+CREATE TABLE templates (
+    id SERIAL NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    header_pattern VARCHAR(255) NOT NULL,
+    body_skeleton TEXT NOT NULL DEFAULT '',
+    default_labels TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE users_templates (
+    id SERIAL NOT NULL UNIQUE,
+    users_id INT REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    templates_id INT REFERENCES templates(id) ON DELETE CASCADE NOT NULL
+);
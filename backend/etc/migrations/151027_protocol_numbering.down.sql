@@ -0,0 +1,11 @@
+DROP TABLE protocol_counters;
+
+DROP INDEX reports_number_idx;
+
+ALTER TABLE reports
+    DROP COLUMN finalized,
+    DROP COLUMN number_seq,
+    DROP COLUMN number_year,
+    DROP COLUMN number_department,
+    DROP COLUMN number,
+    DROP COLUMN status;
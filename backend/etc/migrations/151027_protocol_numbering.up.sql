@@ -0,0 +1,17 @@
+ALTER TABLE reports
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'draft',
+    ADD COLUMN number VARCHAR(255),
+    ADD COLUMN number_department VARCHAR(255),
+    ADD COLUMN number_year INT,
+    ADD COLUMN number_seq INT,
+    ADD COLUMN finalized TIMESTAMP WITH TIME ZONE;
+
+CREATE UNIQUE INDEX reports_number_idx ON reports (number_department, number_year, number_seq)
+    WHERE number_seq IS NOT NULL;
+
+CREATE TABLE protocol_counters (
+    department VARCHAR(255) NOT NULL,
+    year INT NOT NULL,
+    last_value INT NOT NULL,
+    PRIMARY KEY (department, year)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE sessions (
+    id VARCHAR(64) NOT NULL UNIQUE,
+    users_id INT REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    expires TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE refresh_tokens (
+    id SERIAL NOT NULL UNIQUE,
+    sessions_id VARCHAR(64) REFERENCES sessions(id) ON DELETE CASCADE NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires TIMESTAMP WITH TIME ZONE NOT NULL,
+    used TIMESTAMP WITH TIME ZONE
+);
@@ -0,0 +1,6 @@
+DROP INDEX sessions_users_id_idx;
+
+ALTER TABLE sessions
+    DROP COLUMN last_seen,
+    DROP COLUMN ip,
+    DROP COLUMN user_agent;
@@ -0,0 +1,6 @@
+ALTER TABLE sessions
+    ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ADD COLUMN ip VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN last_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+CREATE INDEX sessions_users_id_idx ON sessions (users_id);
@@ -0,0 +1,8 @@
+CREATE TABLE password_resets (
+    id SERIAL NOT NULL UNIQUE,
+    users_id INT REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    expires TIMESTAMP WITH TIME ZONE NOT NULL,
+    used TIMESTAMP WITH TIME ZONE
+);
@@ -0,0 +1,8 @@
+DROP TABLE login_challenges;
+
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+    DROP COLUMN totp_last_counter,
+    DROP COLUMN totp_enabled,
+    DROP COLUMN totp_secret;
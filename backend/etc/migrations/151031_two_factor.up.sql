@@ -0,0 +1,22 @@
+ALTER TABLE users
+    ADD COLUMN totp_secret VARCHAR(64),
+    ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN totp_last_counter BIGINT;
+
+CREATE TABLE recovery_codes (
+    id SERIAL NOT NULL UNIQUE,
+    users_id INT REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX recovery_codes_users_id_idx ON recovery_codes (users_id);
+
+CREATE TABLE login_challenges (
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    users_id INT REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    expires TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempts INT NOT NULL DEFAULT 0
+);
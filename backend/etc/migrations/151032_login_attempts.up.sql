@@ -0,0 +1,6 @@
+CREATE TABLE login_attempts (
+    subject VARCHAR(320) NOT NULL PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    locked_until TIMESTAMP WITH TIME ZONE
+);
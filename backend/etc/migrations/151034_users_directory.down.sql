@@ -0,0 +1,6 @@
+DROP INDEX users_department_idx;
+DROP INDEX users_email_trgm_idx;
+DROP INDEX users_username_trgm_idx;
+DROP INDEX users_name_trgm_idx;
+
+ALTER TABLE users DROP COLUMN email_public;
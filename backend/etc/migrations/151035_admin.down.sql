@@ -0,0 +1,7 @@
+DROP TABLE admin_audit;
+
+ALTER TABLE sessions DROP COLUMN impersonator_id;
+
+ALTER TABLE users
+    DROP COLUMN deactivated,
+    DROP COLUMN role;
@@ -0,0 +1,16 @@
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'user',
+    ADD COLUMN deactivated TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE sessions ADD COLUMN impersonator_id INT REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE TABLE admin_audit (
+    id SERIAL NOT NULL UNIQUE,
+    admin_id INT REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(64) NOT NULL,
+    target_id INT REFERENCES users(id) ON DELETE SET NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX admin_audit_created_idx ON admin_audit (created DESC);
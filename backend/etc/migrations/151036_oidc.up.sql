@@ -0,0 +1,16 @@
+CREATE TABLE oidc_logins (
+    state_hash VARCHAR(64) NOT NULL UNIQUE,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE user_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    users_id INT REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX user_identities_users_id_idx ON user_identities (users_id);
@@ -0,0 +1,14 @@
+CREATE TABLE api_keys (
+    id SERIAL NOT NULL UNIQUE,
+    users_id INT REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    expires TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used TIMESTAMP WITH TIME ZONE,
+    revoked TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX api_keys_users_id_idx ON api_keys (users_id);
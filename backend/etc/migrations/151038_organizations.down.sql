@@ -0,0 +1,18 @@
+DROP INDEX reports_number_idx;
+CREATE UNIQUE INDEX reports_number_idx ON reports (number_department, number_year, number_seq)
+    WHERE number_seq IS NOT NULL;
+
+ALTER TABLE protocol_counters DROP CONSTRAINT protocol_counters_pkey;
+DELETE FROM protocol_counters
+    WHERE organization_id <> (SELECT id FROM organizations WHERE slug = 'default');
+ALTER TABLE protocol_counters
+    DROP COLUMN organization_id,
+    ADD PRIMARY KEY (department, year);
+
+ALTER TABLE admin_audit DROP COLUMN organization_id;
+ALTER TABLE labels DROP COLUMN organization_id;
+ALTER TABLE reports DROP COLUMN organization_id;
+ALTER TABLE users DROP COLUMN organization_id;
+
+DROP FUNCTION organization_for_email(TEXT);
+DROP TABLE organizations;
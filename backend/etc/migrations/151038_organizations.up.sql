@@ -0,0 +1,55 @@
+CREATE TABLE organizations (
+    id SERIAL NOT NULL UNIQUE,
+    slug VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    domains TEXT[] NOT NULL DEFAULT '{}',
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+INSERT INTO organizations (slug, name) VALUES ('default', 'Default');
+
+-- organization_for_email picks the organization that owns the domain of the
+-- email, accounts with other emails go to the default one.
+CREATE FUNCTION organization_for_email(email TEXT) RETURNS INT AS $$
+    SELECT COALESCE(
+        (SELECT id FROM organizations WHERE lower(split_part(email, '@', 2)) = ANY(domains) ORDER BY id LIMIT 1),
+        (SELECT id FROM organizations WHERE slug = 'default')
+    )
+$$ LANGUAGE SQL STABLE;
+
+ALTER TABLE users ADD COLUMN organization_id INT REFERENCES organizations(id);
+UPDATE users SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+ALTER TABLE users ALTER COLUMN organization_id SET NOT NULL;
+CREATE INDEX users_organization_id_idx ON users (organization_id);
+
+ALTER TABLE reports ADD COLUMN organization_id INT REFERENCES organizations(id);
+UPDATE reports n SET organization_id = u.organization_id
+    FROM users_reports un JOIN users u ON u.id = un.users_id
+    WHERE un.reports_id = n.id;
+UPDATE reports SET organization_id = (SELECT id FROM organizations WHERE slug = 'default')
+    WHERE organization_id IS NULL;
+ALTER TABLE reports ALTER COLUMN organization_id SET NOT NULL;
+
+ALTER TABLE labels ADD COLUMN organization_id INT REFERENCES organizations(id);
+UPDATE labels t SET organization_id = u.organization_id
+    FROM users_labels ut JOIN users u ON u.id = ut.users_id
+    WHERE ut.labels_id = t.id;
+UPDATE labels SET organization_id = (SELECT id FROM organizations WHERE slug = 'default')
+    WHERE organization_id IS NULL;
+ALTER TABLE labels ALTER COLUMN organization_id SET NOT NULL;
+
+ALTER TABLE admin_audit ADD COLUMN organization_id INT REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE admin_audit a SET organization_id = u.organization_id FROM users u WHERE u.id = a.target_id;
+
+-- protocol numbers are counted per department of an organization, two
+-- organizations may well have departments with the same name
+ALTER TABLE protocol_counters ADD COLUMN organization_id INT REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE protocol_counters SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+ALTER TABLE protocol_counters
+    ALTER COLUMN organization_id SET NOT NULL,
+    DROP CONSTRAINT protocol_counters_pkey,
+    ADD PRIMARY KEY (organization_id, department, year);
+
+DROP INDEX reports_number_idx;
+CREATE UNIQUE INDEX reports_number_idx ON reports (organization_id, number_department, number_year, number_seq)
+    WHERE number_seq IS NOT NULL;
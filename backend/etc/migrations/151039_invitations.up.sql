@@ -0,0 +1,16 @@
+CREATE TABLE invitations (
+    id SERIAL NOT NULL UNIQUE,
+    organization_id INT REFERENCES organizations(id) ON DELETE CASCADE NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(32) NOT NULL,
+    department VARCHAR(255) NOT NULL DEFAULT '',
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    expires TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted TIMESTAMP WITH TIME ZONE,
+    accepted_by INT REFERENCES users(id) ON DELETE SET NULL,
+    revoked TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX invitations_organization_id_idx ON invitations (organization_id);
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN clearance;
+ALTER TABLE reports DROP COLUMN classification;
+
+DROP TYPE classification;
@@ -0,0 +1,5 @@
+-- the order of the values is the order of the levels, so they compare with < and >
+CREATE TYPE classification AS ENUM ('public', 'internal', 'confidential', 'restricted');
+
+ALTER TABLE reports ADD COLUMN classification classification NOT NULL DEFAULT 'internal';
+ALTER TABLE users ADD COLUMN clearance classification NOT NULL DEFAULT 'internal';
@@ -0,0 +1,15 @@
+-- values sealed by the application stay sealed, the extracted text is gone,
+-- so attachments are indexed again
+ALTER TABLE attachments
+    DROP COLUMN text_search,
+    ADD COLUMN text_content TEXT,
+    ADD COLUMN text_search TSVECTOR GENERATED ALWAYS AS (to_tsvector('russian', COALESCE(text_content, ''))) STORED;
+
+CREATE INDEX attachments_text_search_idx ON attachments USING GIN (text_search);
+
+UPDATE attachments SET text_status = 'pending', text_claimed = NULL;
+
+DROP INDEX reports_body_search_idx;
+
+ALTER TABLE reports_body
+    DROP COLUMN body_search;
@@ -0,0 +1,14 @@
+-- bodies get sealed by the application, search works on their lexemes
+ALTER TABLE reports_body
+    ADD COLUMN body_search TSVECTOR NOT NULL DEFAULT '';
+
+UPDATE reports_body SET body_search = to_tsvector('russian', COALESCE(body, ''));
+
+CREATE INDEX reports_body_search_idx ON reports_body USING GIN (body_search);
+
+-- the text of attachments was only kept to build the search vector
+ALTER TABLE attachments
+    ALTER COLUMN text_search DROP EXPRESSION;
+
+ALTER TABLE attachments
+    DROP COLUMN text_content;
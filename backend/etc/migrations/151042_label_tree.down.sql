@@ -0,0 +1,2 @@
+DROP INDEX labels_parent_id_idx;
+ALTER TABLE labels DROP COLUMN parent_id;
@@ -0,0 +1,3 @@
+-- deleting a label makes its children top level labels
+ALTER TABLE labels ADD COLUMN parent_id INT REFERENCES labels(id) ON DELETE SET NULL;
+CREATE INDEX labels_parent_id_idx ON labels (parent_id);
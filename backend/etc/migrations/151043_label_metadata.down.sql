@@ -0,0 +1,4 @@
+ALTER TABLE labels
+    DROP COLUMN icon,
+    DROP COLUMN description,
+    DROP COLUMN color;
@@ -0,0 +1,4 @@
+ALTER TABLE labels
+    ADD COLUMN color VARCHAR(7) NOT NULL DEFAULT '' CHECK (color = '' OR color ~ '^#[0-9a-f]{6}$'),
+    ADD COLUMN description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN icon VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+ALTER TABLE users
+    ADD COLUMN auth_source VARCHAR(16) NOT NULL DEFAULT 'local' CHECK (auth_source IN ('local', 'ldap', 'oidc'));
+
+-- accounts without a password were provisioned by single sign-on or synced
+-- from ldap, unless an admin reset their password
+UPDATE users u SET auth_source = 'oidc'
+    WHERE u.password_hash = '!' AND EXISTS (SELECT 1 FROM user_identities i WHERE i.users_id = u.id);
+UPDATE users u SET auth_source = 'ldap'
+    WHERE u.password_hash = '!' AND u.auth_source = 'local'
+        AND NOT EXISTS (SELECT 1 FROM admin_audit a WHERE a.target_id = u.id AND a.action = 'password_reset');
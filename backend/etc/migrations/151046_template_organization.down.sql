@@ -0,0 +1 @@
+ALTER TABLE templates DROP COLUMN organization_id;
@@ -0,0 +1,7 @@
+ALTER TABLE templates ADD COLUMN organization_id INT REFERENCES organizations(id);
+UPDATE templates t SET organization_id = u.organization_id
+    FROM users_templates ut JOIN users u ON u.id = ut.users_id
+    WHERE ut.templates_id = t.id;
+UPDATE templates SET organization_id = (SELECT id FROM organizations WHERE slug = 'default')
+    WHERE organization_id IS NULL;
+ALTER TABLE templates ALTER COLUMN organization_id SET NOT NULL;
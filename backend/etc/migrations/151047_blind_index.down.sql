@@ -0,0 +1,20 @@
+-- sealed headers stay sealed and keep the column wide, the search vectors
+-- can't be built from sealed bodies, so reports are found again only once
+-- they are changed, attachments are indexed again
+DROP INDEX attachments_search_terms_idx;
+
+ALTER TABLE attachments
+    DROP COLUMN search_terms,
+    ADD COLUMN text_search TSVECTOR;
+
+CREATE INDEX attachments_text_search_idx ON attachments USING GIN (text_search);
+
+UPDATE attachments SET text_status = 'pending', text_claimed = NULL;
+
+DROP INDEX reports_body_search_terms_idx;
+
+ALTER TABLE reports_body
+    DROP COLUMN search_terms,
+    ADD COLUMN body_search TSVECTOR NOT NULL DEFAULT '';
+
+CREATE INDEX reports_body_search_idx ON reports_body USING GIN (body_search);
@@ -0,0 +1,24 @@
+-- search vectors kept the words of sealed texts in the clear, the search works
+-- on blind index terms computed by the application instead
+DROP INDEX reports_body_search_idx;
+
+ALTER TABLE reports_body
+    DROP COLUMN body_search,
+    ADD COLUMN search_terms TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX reports_body_search_terms_idx ON reports_body USING GIN (search_terms);
+
+DROP INDEX attachments_text_search_idx;
+
+ALTER TABLE attachments
+    DROP COLUMN text_search,
+    ADD COLUMN search_terms TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX attachments_search_terms_idx ON attachments USING GIN (search_terms);
+
+-- headers get sealed too, sealed ones don't fit into 255 characters
+ALTER TABLE reports ALTER COLUMN header TYPE TEXT;
+
+-- attachments are indexed again by the backends, existing reports get their
+-- terms and sealed headers from cmd/rotatekeys
+UPDATE attachments SET text_status = 'pending', text_claimed = NULL;
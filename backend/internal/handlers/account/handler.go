@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/go-ozzo/ozzo-validation/v4"
+	"math"
 	"net/http"
 	"reports_system/internal/handlers/middleware"
 	"reports_system/internal/mapper"
@@ -18,6 +20,19 @@ const (
 	accountsURLGroup = "/accounts"
 	registerURL      = "/register"
 	loginURL         = "/login"
+	refreshURL       = "/refresh"
+	logoutURL        = "/logout"
+	sessionsURL      = "/me/sessions"
+	sessionURL       = "/me/sessions/:id"
+	passwordURL      = "/me/password"
+	resetURL         = "/password/reset"
+	resetConfirmURL  = "/password/reset/confirm"
+	secondFactorURL  = "/login/second-factor"
+	totpURL          = "/me/totp"
+	totpConfirmURL   = "/me/totp/confirm"
+	totpDisableURL   = "/me/totp/disable"
+	verifyURL        = "/verify"
+	verifyResendURL  = "/verify/resend"
 	apiURLGroup      = "/api"
 	apiVersion       = "1"
 )
@@ -41,23 +56,37 @@ func (h *Handler) Register(router *gin.Engine) {
 	{
 		auth.POST(registerURL, h.register)
 		auth.POST(loginURL, h.login)
+		auth.POST(secondFactorURL, h.verifySecondFactor)
+		auth.GET(verifyURL, h.verifyEmail)
+		auth.POST(verifyResendURL, h.resendVerification)
+		auth.POST(refreshURL, h.refresh)
+		auth.POST(resetURL, h.requestPasswordReset)
+		auth.POST(resetConfirmURL, h.resetPassword)
 	}
 
 	accounts := router.Group(groupName, middleware.Authenticate)
 	{
+		accounts.POST(logoutURL, h.logout)
+		accounts.GET(sessionsURL, h.getSessions)
+		accounts.DELETE(sessionURL, h.revokeSession)
+		accounts.POST(passwordURL, middleware.ForbidImpersonation, h.changePassword)
+		accounts.POST(totpURL, middleware.ForbidImpersonation, h.enrollTOTP)
+		accounts.POST(totpConfirmURL, middleware.ForbidImpersonation, h.confirmTOTP)
+		accounts.POST(totpDisableURL, middleware.ForbidImpersonation, h.disableTOTP)
+		accounts.GET("", h.searchAccounts)
 		accounts.GET("/:id", h.getAccount)
 	}
 }
 
 // @Summary Register
 // @Tags account
-// @Description create account
+// @Description create account, a verification link is mailed to the email. With an invite token from an invitation email the account joins the organization with the role and department chosen by the admin, and the email counts as verified
 // @ID create-account
 // @Accept  json
 // @Produce  json
 // @Param dto body account.RegisterAccountDTO true "account info"
 // @Success 201 {string} string 1
-// @Failure 500 {object} e.ErrorResponse
+// @Failure 400,409,500 {object} e.ErrorResponse
 // @Failure default {object} e.ErrorResponse
 // @Router /api/v1/accounts/register [post]
 func (h *Handler) register(ctx *gin.Context) {
@@ -80,8 +109,19 @@ func (h *Handler) register(ctx *gin.Context) {
 		return
 	}
 
-	err = h.service.CreateAccount(&a)
+	err = h.service.CreateAccount(&a, dto.Invite)
 	if err != nil {
+		var validationErrs validation.Errors
+		if errors.As(err, &validationErrs) ||
+			errors.Is(err, &account.InvalidInvitationErr{}) ||
+			errors.Is(err, &account.InvitationEmailMismatchErr{}) {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, &account.UsernameTakenErr{}) {
+			e.NewErrorResponse(ctx, http.StatusConflict, err)
+			return
+		}
 		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
@@ -139,13 +179,13 @@ func (h *Handler) getAccount(ctx *gin.Context) {
 
 // @Summary Login
 // @Tags account
-// @Description login
+// @Description login, accounts with two-factor authentication get mfaToken instead of tokens
 // @ID login
 // @Accept  json
 // @Produce  json
 // @Param dto body account.LoginAccountDTO true "credentials"
 // @Success 200 {object} account.WithTokenDTO
-// @Failure 500 {object} e.ErrorResponse
+// @Failure 401,403,429,500 {object} e.ErrorResponse
 // @Failure default {object} e.ErrorResponse
 // @Router /api/v1/accounts/login [post]
 func (h *Handler) login(ctx *gin.Context) {
@@ -159,14 +199,528 @@ func (h *Handler) login(ctx *gin.Context) {
 
 	a := h.mapper.MapLogInAccountDTO(loginDto)
 
-	token, err := h.service.GenerateJWT(&a)
+	client := account.NewClient(ctx.Request.UserAgent(), ctx.ClientIP())
+
+	tokens, err := h.service.GenerateJWT(&a, client)
 	if err != nil {
-		if errors.Is(err, &account.PasswordDoesNotMatchErr{}) {
+		h.logger.Info(err)
+		if h.tooManyAttempts(ctx, err) {
+			return
+		}
+		if errors.Is(err, &account.PasswordDoesNotMatchErr{}) || errors.Is(err, &account.AccountNotFoundErr{}) {
 			e.NewErrorResponse(ctx, http.StatusUnauthorized, err)
+			return
+		}
+		if errors.Is(err, &account.EmailNotVerifiedErr{}) || errors.Is(err, &account.AccountDeactivatedErr{}) {
+			e.NewErrorResponse(ctx, http.StatusForbidden, err)
+			return
 		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
-	loginWithTokenDto := h.mapper.MapAccountWithTokenDTO(token, a)
+	loginWithTokenDto := h.mapper.MapAccountWithTokenDTO(tokens, a)
 
 	ctx.JSON(http.StatusOK, loginWithTokenDto)
 }
+
+// @Summary Refresh
+// @Tags account
+// @Description exchange refresh token for a new pair of tokens, the old refresh token stops working
+// @ID refresh
+// @Accept  json
+// @Produce  json
+// @Param dto body account.RefreshTokenDTO true "refresh token"
+// @Success 200 {object} account.TokensDTO
+// @Failure 401,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/refresh [post]
+func (h *Handler) refresh(ctx *gin.Context) {
+	var dto account.RefreshTokenDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Error(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	tokens, err := h.service.Refresh(dto.RefreshToken)
+	if err != nil {
+		h.logger.Info(err)
+		if errors.Is(err, &account.InvalidRefreshTokenErr{}) || errors.Is(err, &account.SessionRevokedErr{}) {
+			e.NewErrorResponse(ctx, http.StatusUnauthorized, err)
+			return
+		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapTokensDTO(tokens))
+}
+
+// @Summary Logout
+// @Security ApiKeyAuth
+// @Tags account
+// @Description revoke current session, its access and refresh tokens stop working on every backend
+// @ID logout
+// @Accept  json
+// @Produce  json
+// @Success 204
+// @Failure 500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/logout [post]
+func (h *Handler) logout(ctx *gin.Context) {
+	sessionID, err := middleware.GetSessionID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = h.service.Logout(sessionID); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary getSessions
+// @Security ApiKeyAuth
+// @Tags account
+// @Description list active sessions of the current user
+// @ID get-sessions
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} account.GetAllSessionsDTO
+// @Failure 500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/sessions [get]
+func (h *Handler) getSessions(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	sessionID, err := middleware.GetSessionID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	sessions, err := h.service.GetSessions(userID)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapGetAllSessionsDTO(sessions, sessionID))
+}
+
+// @Summary revokeSession
+// @Security ApiKeyAuth
+// @Tags account
+// @Description sign out one of the current user's sessions
+// @ID revoke-session
+// @Accept  json
+// @Produce  json
+// @Param id   path  string  true  "session id"
+// @Success 204
+// @Failure 404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/sessions/{id} [delete]
+func (h *Handler) revokeSession(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.service.RevokeSession(userID, ctx.Param("id"))
+	if err != nil {
+		h.logger.Info(err)
+		if errors.Is(err, &account.SessionNotFoundErr{}) {
+			e.NewErrorResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary changePassword
+// @Security ApiKeyAuth
+// @Tags account
+// @Description change password of the current user, other sessions are signed out and api keys are revoked
+// @ID change-password
+// @Accept  json
+// @Produce  json
+// @Param dto body account.ChangePasswordDTO true "old and new password"
+// @Success 204
+// @Failure 400,403,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/password [post]
+func (h *Handler) changePassword(ctx *gin.Context) {
+	var dto account.ChangePasswordDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	sessionID, err := middleware.GetSessionID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.service.ChangePassword(userID, sessionID, dto.OldPassword, dto.NewPassword)
+	if err != nil {
+		h.logger.Info(err)
+		h.newPasswordErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary requestPasswordReset
+// @Tags account
+// @Description mail a password reset link to accounts registered with the email
+// @ID request-password-reset
+// @Accept  json
+// @Produce  json
+// @Param dto body account.RequestPasswordResetDTO true "email"
+// @Success 202
+// @Failure 400,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/password/reset [post]
+func (h *Handler) requestPasswordReset(ctx *gin.Context) {
+	var dto account.RequestPasswordResetDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.RequestPasswordReset(dto.Email); err != nil {
+		h.logger.Error(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary resetPassword
+// @Tags account
+// @Description set a new password using the token from the reset email, all sessions are signed out and api keys are revoked
+// @ID reset-password
+// @Accept  json
+// @Produce  json
+// @Param dto body account.ResetPasswordDTO true "token and new password"
+// @Success 204
+// @Failure 400,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/password/reset/confirm [post]
+func (h *Handler) resetPassword(ctx *gin.Context) {
+	var dto account.ResetPasswordDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.ResetPassword(dto.Token, dto.Password); err != nil {
+		h.logger.Info(err)
+		h.newPasswordErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) newPasswordErrorResponse(ctx *gin.Context, err error) {
+	var validationErrs validation.Errors
+	switch {
+	case errors.Is(err, &account.PasswordDoesNotMatchErr{}):
+		e.NewErrorResponse(ctx, http.StatusForbidden, err)
+	case errors.Is(err, &account.InvalidResetTokenErr{}), errors.As(err, &validationErrs):
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+	default:
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
+
+// @Summary verifySecondFactor
+// @Tags account
+// @Description finish login with a TOTP or recovery code
+// @ID verify-second-factor
+// @Accept  json
+// @Produce  json
+// @Param dto body account.SecondFactorDTO true "mfa token from login and code"
+// @Success 200 {object} account.WithTokenDTO
+// @Failure 401,429,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/login/second-factor [post]
+func (h *Handler) verifySecondFactor(ctx *gin.Context) {
+	var dto account.SecondFactorDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	tokens, a, err := h.service.VerifySecondFactor(dto.MFAToken, dto.Code)
+	if err != nil {
+		h.logger.Info(err)
+		h.newTwoFactorErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapAccountWithTokenDTO(tokens, a))
+}
+
+// @Summary enrollTOTP
+// @Security ApiKeyAuth
+// @Tags account
+// @Description generate a TOTP secret, uri is meant to be shown as a QR code
+// @ID enroll-totp
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} account.TOTPEnrollmentDTO
+// @Failure 403,409,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/totp [post]
+func (h *Handler) enrollTOTP(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	enrollment, err := h.service.EnrollTOTP(userID)
+	if err != nil {
+		h.logger.Info(err)
+		h.newTwoFactorErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapTOTPEnrollmentDTO(enrollment))
+}
+
+// @Summary confirmTOTP
+// @Security ApiKeyAuth
+// @Tags account
+// @Description enable two-factor authentication with a code from the app, recovery codes are shown only once
+// @ID confirm-totp
+// @Accept  json
+// @Produce  json
+// @Param dto body account.TOTPCodeDTO true "code"
+// @Success 200 {object} account.RecoveryCodesDTO
+// @Failure 400,401,403,409,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/totp/confirm [post]
+func (h *Handler) confirmTOTP(ctx *gin.Context) {
+	var dto account.TOTPCodeDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	codes, err := h.service.ConfirmTOTP(userID, dto.Code)
+	if err != nil {
+		h.logger.Info(err)
+		h.newTwoFactorErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, account.RecoveryCodesDTO{Codes: codes})
+}
+
+// @Summary disableTOTP
+// @Security ApiKeyAuth
+// @Tags account
+// @Description turn two-factor authentication off
+// @ID disable-totp
+// @Accept  json
+// @Produce  json
+// @Param dto body account.DisableTOTPDTO true "password"
+// @Success 204
+// @Failure 403,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/totp/disable [post]
+func (h *Handler) disableTOTP(ctx *gin.Context) {
+	var dto account.DisableTOTPDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = h.service.DisableTOTP(userID, dto.Password); err != nil {
+		h.logger.Info(err)
+		h.newTwoFactorErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) newTwoFactorErrorResponse(ctx *gin.Context, err error) {
+	if h.tooManyAttempts(ctx, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, &account.SecondFactorInvalidErr{}), errors.Is(err, &account.ChallengeExpiredErr{}):
+		e.NewErrorResponse(ctx, http.StatusUnauthorized, err)
+	case errors.Is(err, &account.PasswordDoesNotMatchErr{}):
+		e.NewErrorResponse(ctx, http.StatusForbidden, err)
+	case errors.Is(err, &account.TwoFactorAlreadyEnabledErr{}), errors.Is(err, &account.TwoFactorNotEnrolledErr{}):
+		e.NewErrorResponse(ctx, http.StatusConflict, err)
+	default:
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
+
+// tooManyAttempts responds with 429 when the login is locked out.
+func (h *Handler) tooManyAttempts(ctx *gin.Context, err error) bool {
+	var lockedErr *account.TooManyAttemptsErr
+	if !errors.As(err, &lockedErr) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(lockedErr.RetryAfter.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+	e.NewErrorResponse(ctx, http.StatusTooManyRequests, err)
+	return true
+}
+
+// @Summary verifyEmail
+// @Tags account
+// @Description verify email with the token from the verification email
+// @ID verify-email
+// @Accept  json
+// @Produce  json
+// @Param token query string true "verification token"
+// @Success 200 {string} string "email verified"
+// @Failure 400,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/verify [get]
+func (h *Handler) verifyEmail(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		e.NewErrorResponse(ctx, http.StatusBadRequest, &account.InvalidVerificationTokenErr{})
+		return
+	}
+
+	if err := h.service.VerifyEmail(token); err != nil {
+		h.logger.Info(err)
+		if errors.Is(err, &account.InvalidVerificationTokenErr{}) {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "email verified")
+}
+
+// @Summary resendVerification
+// @Tags account
+// @Description mail a new verification link to unverified accounts with the email
+// @ID resend-verification
+// @Accept  json
+// @Produce  json
+// @Param dto body account.ResendVerificationDTO true "email"
+// @Success 202
+// @Failure 400,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/verify/resend [post]
+func (h *Handler) resendVerification(ctx *gin.Context) {
+	var dto account.ResendVerificationDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.ResendVerification(dto.Email); err != nil {
+		h.logger.Error(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary searchAccounts
+// @Security ApiKeyAuth
+// @Tags account
+// @Description find colleagues by part of the name or prefix of username or email
+// @ID search-accounts
+// @Accept  json
+// @Produce  json
+// @Param q          query string false "search text"
+// @Param department query string false "only accounts of the department"
+// @Param limit      query int    false "max number of accounts, 20 by default, at most 100"
+// @Success 200 {object} account.GetAllPublicAccountsDTO
+// @Failure 400,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts [get]
+func (h *Handler) searchAccounts(ctx *gin.Context) {
+	organizationID, err := middleware.GetOrganizationID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	q := account.DirectoryQuery{
+		OrganizationID: organizationID,
+		Text:           ctx.Query("q"),
+		Department:     ctx.Query("department"),
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	accounts, err := h.service.Search(q)
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapGetAllPublicAccountsDTO(accounts))
+}
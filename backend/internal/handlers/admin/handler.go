@@ -0,0 +1,413 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reports_system/internal/handlers/middleware"
+	"reports_system/internal/mapper"
+	"reports_system/internal/model/account"
+	"reports_system/internal/model/report"
+	"reports_system/internal/service"
+	"reports_system/pkg/e"
+	"reports_system/pkg/logging"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	apiURLGroup      = "/api"
+	adminURLGroup    = "/admin"
+	accountsURL      = "/accounts"
+	deactivateURL    = "/:id/deactivate"
+	reactivateURL    = "/:id/reactivate"
+	passwordResetURL = "/:id/password-reset"
+	roleURL          = "/:id/role"
+	clearanceURL     = "/:id/clearance"
+	impersonateURL   = "/:id/impersonate"
+	auditURL         = "/audit"
+	invitationsURL   = "/invitations"
+	apiVersion       = "1"
+)
+
+type Handler struct {
+	logger  logging.Logger
+	service service.Admin
+	mapper  mapper.Account
+}
+
+func NewHandler(logger logging.Logger, service service.Admin, mapper mapper.Account) *Handler {
+	return &Handler{logger: logger, service: service, mapper: mapper}
+}
+
+func (h *Handler) Register(router *gin.Engine) {
+	groupName := fmt.Sprintf("%v/v%v%v", apiURLGroup, apiVersion, adminURLGroup)
+
+	h.logger.Tracef("Register route: %v", groupName)
+
+	group := router.Group(groupName, middleware.Authenticate, middleware.RequireRole(account.RoleAdmin))
+	{
+		accounts := group.Group(accountsURL)
+		{
+			accounts.GET("", h.getAllAccounts)
+			accounts.POST(deactivateURL, h.deactivate)
+			accounts.POST(reactivateURL, h.reactivate)
+			accounts.POST(passwordResetURL, h.forcePasswordReset)
+			accounts.PUT(roleURL, h.setRole)
+			accounts.PUT(clearanceURL, h.setClearance)
+			accounts.POST(impersonateURL, h.impersonate)
+		}
+		invitations := group.Group(invitationsURL)
+		{
+			invitations.GET("", h.getInvitations)
+			invitations.POST("", h.invite)
+			invitations.DELETE("/:id", h.revokeInvitation)
+		}
+		group.GET(auditURL, h.getAudit)
+	}
+}
+
+// @Summary getAllAccounts
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description list accounts including deactivated ones, filtered like the directory
+// @ID admin-get-all-accounts
+// @Accept  json
+// @Produce  json
+// @Param q          query string false "search text"
+// @Param department query string false "only accounts of the department"
+// @Param limit      query int    false "max number of accounts, 20 by default, at most 100"
+// @Success 200 {object} account.GetAllAdminAccountsDTO
+// @Failure 400,403,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/accounts [get]
+func (h *Handler) getAllAccounts(ctx *gin.Context) {
+	organizationID, err := middleware.GetOrganizationID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	q := account.DirectoryQuery{
+		OrganizationID: organizationID,
+		Text:           ctx.Query("q"),
+		Department:     ctx.Query("department"),
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	accounts, err := h.service.GetAll(q)
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapGetAllAdminAccountsDTO(accounts))
+}
+
+// @Summary deactivate
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description deactivate account, its sessions are revoked and it can't log in until reactivated
+// @ID admin-deactivate
+// @Param id path int true "account id"
+// @Success 204
+// @Failure 400,403,404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/accounts/{id}/deactivate [post]
+func (h *Handler) deactivate(ctx *gin.Context) {
+	h.change(ctx, h.service.Deactivate)
+}
+
+// @Summary reactivate
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description reactivate deactivated account
+// @ID admin-reactivate
+// @Param id path int true "account id"
+// @Success 204
+// @Failure 400,403,404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/accounts/{id}/reactivate [post]
+func (h *Handler) reactivate(ctx *gin.Context) {
+	h.change(ctx, h.service.Reactivate)
+}
+
+// @Summary forcePasswordReset
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description invalidate password of the account and mail it a reset link, its sessions are revoked
+// @ID admin-force-password-reset
+// @Param id path int true "account id"
+// @Success 204
+// @Failure 400,403,404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/accounts/{id}/password-reset [post]
+func (h *Handler) forcePasswordReset(ctx *gin.Context) {
+	h.change(ctx, h.service.ForcePasswordReset)
+}
+
+// @Summary setRole
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description assign role to the account
+// @ID admin-set-role
+// @Accept  json
+// @Param id  path int              true "account id"
+// @Param dto body account.SetRoleDTO true "new role"
+// @Success 204
+// @Failure 400,403,404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/accounts/{id}/role [put]
+func (h *Handler) setRole(ctx *gin.Context) {
+	var dto account.SetRoleDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	h.change(ctx, func(adminID, userID int) error {
+		return h.service.SetRole(adminID, userID, dto.Role)
+	})
+}
+
+// @Summary setClearance
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description set the highest report classification the account can see
+// @ID admin-set-clearance
+// @Accept  json
+// @Param id  path int                     true "account id"
+// @Param dto body account.SetClearanceDTO true "new clearance"
+// @Success 204
+// @Failure 400,403,404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/accounts/{id}/clearance [put]
+func (h *Handler) setClearance(ctx *gin.Context) {
+	var dto account.SetClearanceDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	h.change(ctx, func(adminID, userID int) error {
+		return h.service.SetClearance(adminID, userID, dto.Clearance)
+	})
+}
+
+// @Summary impersonate
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description log in as the account, the session remembers the admin and every request is logged
+// @ID admin-impersonate
+// @Produce  json
+// @Param id path int true "account id"
+// @Success 200 {object} account.WithTokenDTO
+// @Failure 400,403,404,409,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/accounts/{id}/impersonate [post]
+func (h *Handler) impersonate(ctx *gin.Context) {
+	adminID, userID, ok := h.ids(ctx)
+	if !ok {
+		return
+	}
+
+	client := account.NewClient(ctx.Request.UserAgent(), ctx.ClientIP())
+
+	tokens, a, err := h.service.Impersonate(adminID, userID, client)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapAccountWithTokenDTO(tokens, a))
+}
+
+// @Summary getAudit
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description latest admin actions, newest first
+// @ID admin-get-audit
+// @Produce  json
+// @Param limit query int false "max number of entries, at most 100"
+// @Success 200 {object} account.GetAllAuditEntriesDTO
+// @Failure 400,403,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/audit [get]
+func (h *Handler) getAudit(ctx *gin.Context) {
+	organizationID, err := middleware.GetOrganizationID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	var limit int
+	if s := ctx.Query("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	entries, err := h.service.GetAudit(organizationID, limit)
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapGetAllAuditEntriesDTO(entries))
+}
+
+// @Summary getInvitations
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description invitations of the organization that are neither accepted, expired nor revoked, newest first
+// @ID admin-get-invitations
+// @Produce  json
+// @Success 200 {object} account.GetAllInvitationsDTO
+// @Failure 403,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/invitations [get]
+func (h *Handler) getInvitations(ctx *gin.Context) {
+	organizationID, err := middleware.GetOrganizationID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	invitations, err := h.service.GetInvitations(organizationID)
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapGetAllInvitationsDTO(invitations))
+}
+
+// @Summary invite
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description mail an invitation link, the person registers with it and joins the organization with the role and department. A new invitation to the same email revokes the older ones
+// @ID admin-invite
+// @Accept  json
+// @Produce  json
+// @Param dto body account.InviteDTO true "who to invite, role is user by default"
+// @Success 201 {object} account.InvitationDTO
+// @Failure 400,403,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/invitations [post]
+func (h *Handler) invite(ctx *gin.Context) {
+	adminID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	organizationID, err := middleware.GetOrganizationID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	var dto account.InviteDTO
+	if err = ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	i := h.mapper.MapInviteDTO(organizationID, dto)
+	if err = h.service.Invite(adminID, &i); err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, h.mapper.MapInvitationDTO(i))
+}
+
+// @Summary revokeInvitation
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description revoke a pending invitation, its link stops working
+// @ID admin-revoke-invitation
+// @Param id path int true "invitation id"
+// @Success 204
+// @Failure 400,403,404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/admin/invitations/{id} [delete]
+func (h *Handler) revokeInvitation(ctx *gin.Context) {
+	organizationID, err := middleware.GetOrganizationID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.change(ctx, func(adminID, invitationID int) error {
+		return h.service.RevokeInvitation(adminID, organizationID, invitationID)
+	})
+}
+
+// change runs an action of the current admin on the account from the path.
+func (h *Handler) change(ctx *gin.Context, action func(adminID, userID int) error) {
+	adminID, userID, ok := h.ids(ctx)
+	if !ok {
+		return
+	}
+
+	if err := action(adminID, userID); err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ids(ctx *gin.Context) (int, int, bool) {
+	adminID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return 0, 0, false
+	}
+
+	userID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return 0, 0, false
+	}
+
+	return adminID, userID, true
+}
+
+func (h *Handler) newErrorResponse(ctx *gin.Context, err error) {
+	var validationErrs validation.Errors
+	switch {
+	case errors.As(err, &validationErrs),
+		errors.Is(err, &account.UnknownRoleErr{}),
+		errors.Is(err, &report.UnknownClassificationErr{}),
+		errors.Is(err, &account.OwnAccountErr{}):
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, &account.AccountNotFoundErr{}),
+		errors.Is(err, &account.InvitationNotFoundErr{}):
+		e.NewErrorResponse(ctx, http.StatusNotFound, err)
+	case errors.Is(err, &account.CanNotImpersonateErr{}):
+		e.NewErrorResponse(ctx, http.StatusConflict, err)
+	default:
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
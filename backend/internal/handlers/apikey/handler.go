@@ -0,0 +1,154 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reports_system/internal/handlers/middleware"
+	"reports_system/internal/mapper"
+	"reports_system/internal/model/account"
+	"reports_system/internal/service"
+	"reports_system/pkg/e"
+	"reports_system/pkg/logging"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	apiURLGroup      = "/api"
+	accountsURLGroup = "/accounts"
+	apiKeysURL       = "/me/api-keys"
+	apiVersion       = "1"
+)
+
+type Handler struct {
+	logger  logging.Logger
+	service service.APIKey
+	mapper  mapper.Account
+}
+
+func NewHandler(logger logging.Logger, service service.APIKey, mapper mapper.Account) *Handler {
+	return &Handler{logger: logger, service: service, mapper: mapper}
+}
+
+func (h *Handler) Register(router *gin.Engine) {
+	groupName := fmt.Sprintf("%v/v%v%v%v", apiURLGroup, apiVersion, accountsURLGroup, apiKeysURL)
+
+	h.logger.Tracef("Register route: %v", groupName)
+
+	group := router.Group(groupName, middleware.Authenticate)
+	{
+		group.GET("", h.getAll)
+		group.POST("", middleware.ForbidImpersonation, h.create)
+		group.DELETE("/:id", middleware.ForbidImpersonation, h.revoke)
+	}
+}
+
+// @Summary getAPIKeys
+// @Security ApiKeyAuth
+// @Tags account
+// @Description list api keys of the current user that are neither expired nor revoked
+// @ID get-api-keys
+// @Produce  json
+// @Success 200 {object} account.GetAllAPIKeysDTO
+// @Failure 500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/api-keys [get]
+func (h *Handler) getAll(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	keys, err := h.service.GetAll(userID)
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapGetAllAPIKeysDTO(keys))
+}
+
+// @Summary createAPIKey
+// @Security ApiKeyAuth
+// @Tags account
+// @Description create an api key for scripts, send it in the X-API-Key header or as "Authorization: ApiKey <key>". The key is shown only once
+// @ID create-api-key
+// @Accept  json
+// @Produce  json
+// @Param dto body account.CreateAPIKeyDTO true "name, scopes and expiry, 90 days by default"
+// @Success 201 {object} account.CreatedAPIKeyDTO
+// @Failure 400,403,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/api-keys [post]
+func (h *Handler) create(ctx *gin.Context) {
+	var dto account.CreateAPIKeyDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	k := h.mapper.MapCreateAPIKeyDTO(userID, dto)
+	key, err := h.service.Create(&k)
+	if err != nil {
+		h.logger.Info(err)
+		var validationErrs validation.Errors
+		if errors.As(err, &validationErrs) {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, h.mapper.MapCreatedAPIKeyDTO(k, key))
+}
+
+// @Summary revokeAPIKey
+// @Security ApiKeyAuth
+// @Tags account
+// @Description revoke api key of the current user, it stops working immediately
+// @ID revoke-api-key
+// @Param id path int true "api key id"
+// @Success 204
+// @Failure 400,403,404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/api-keys/{id} [delete]
+func (h *Handler) revoke(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	keyID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = h.service.Revoke(userID, keyID); err != nil {
+		h.logger.Info(err)
+		if errors.Is(err, &account.APIKeyNotFoundErr{}) {
+			e.NewErrorResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,255 @@
+package attachment
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reports_system/internal/handlers/middleware"
+	"reports_system/internal/mapper"
+	"reports_system/internal/model/attachment"
+	"reports_system/internal/model/report"
+	"reports_system/internal/service"
+	"reports_system/pkg/e"
+	"reports_system/pkg/logging"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiURLGroup         = "/api"
+	reportsURLGroup     = "/reports"
+	attachmentsURLGroup = "/attachments"
+	apiVersion          = "1"
+	fileFormKey         = "file"
+
+	// multipartOverhead leaves room for boundaries and part headers on top of the file itself
+	multipartOverhead = 1 << 20
+)
+
+type Handler struct {
+	logger  logging.Logger
+	service service.Attachment
+	mapper  mapper.Attachment
+	maxSize int64
+}
+
+func NewHandler(logger logging.Logger, service service.Attachment, mapper mapper.Attachment, maxSize int64) *Handler {
+	return &Handler{logger: logger, service: service, mapper: mapper, maxSize: maxSize}
+}
+
+func (h *Handler) Register(router *gin.Engine) {
+	groupName := fmt.Sprintf("%v/v%v%v/:id%v", apiURLGroup, apiVersion, reportsURLGroup, attachmentsURLGroup)
+
+	h.logger.Tracef("Register route: %v", groupName)
+
+	group := router.Group(groupName, middleware.Authenticate)
+	{
+		group.POST("", h.uploadAttachment)                  // /api/v1/reports/:id/attachments
+		group.GET("", h.getAllAttachments)                  // /api/v1/reports/:id/attachments
+		group.GET("/:attachment_id", h.downloadAttachment)  // /api/v1/reports/:id/attachments/:attachment_id
+		group.DELETE("/:attachment_id", h.deleteAttachment) // /api/v1/reports/:id/attachments/:attachment_id
+	}
+}
+
+// @Summary Upload attachment
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags attachments
+// @Description upload file and attach it to report
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param   id  path  string  true  "id"
+// @Param   file formData file true "attached file"
+// @Success 201 {object} attachment.Attachment
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400,404,409,413,415 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/reports/{id}/attachments [post]
+func (h *Handler) uploadAttachment(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	reportID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if ctx.Request.ContentLength > h.maxSize+multipartOverhead {
+		e.NewErrorResponse(ctx, http.StatusRequestEntityTooLarge, &attachment.AttachmentTooLargeErr{})
+		return
+	}
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.maxSize+multipartOverhead)
+
+	header, err := ctx.FormFile(fileFormKey)
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+
+	a := h.mapper.MapUploadAttachment(header.Filename, header.Size)
+	err = h.service.Upload(userID, reportID, &a, file)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, a)
+}
+
+// @Summary Get all attachments of report
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags attachments
+// @Description list attachments of report
+// @Accept  json
+// @Produce  json
+// @Param   id  path  string  true  "id"
+// @Success 200 {object} attachment.GetAllAttachmentsDTO
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400,404 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/reports/{id}/attachments [get]
+func (h *Handler) getAllAttachments(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	reportID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	attachments, err := h.service.GetAll(userID, reportID)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	dto := h.mapper.MapGetAllAttachmentsDTO(attachments)
+
+	ctx.JSON(http.StatusOK, dto)
+}
+
+// @Summary Download attachment
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags attachments
+// @Description download attached file
+// @Produce  octet-stream
+// @Param   id  path  string  true  "id"
+// @Param   attachment_id  path  string  true  "attachment id"
+// @Success 200 {file} file
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400,404 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/reports/{id}/attachments/{attachment_id} [get]
+func (h *Handler) downloadAttachment(ctx *gin.Context) {
+	userID, reportID, attachmentID, ok := h.getIDs(ctx)
+	if !ok {
+		return
+	}
+
+	a, blob, err := h.service.Download(userID, reportID, attachmentID)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+	defer blob.Close()
+
+	ctx.DataFromReader(http.StatusOK, a.Size, a.ContentType, blob, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// @Summary Delete attachment
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags attachments
+// @Description delete attached file
+// @Accept  json
+// @Produce  json
+// @Param   id  path  string  true  "id"
+// @Param   attachment_id  path  string  true  "attachment id"
+// @Success 204
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400,404,409 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/reports/{id}/attachments/{attachment_id} [delete]
+func (h *Handler) deleteAttachment(ctx *gin.Context) {
+	userID, reportID, attachmentID, ok := h.getIDs(ctx)
+	if !ok {
+		return
+	}
+
+	err := h.service.Delete(userID, reportID, attachmentID)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) getIDs(ctx *gin.Context) (int, int, int, bool) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return 0, 0, 0, false
+	}
+
+	reportID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return 0, 0, 0, false
+	}
+
+	attachmentID, err := strconv.Atoi(ctx.Param("attachment_id"))
+	if err != nil {
+		h.logger.Info("error while getting attachment id from request")
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return 0, 0, 0, false
+	}
+
+	return userID, reportID, attachmentID, true
+}
+
+func (h *Handler) newErrorResponse(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, &report.ReportNotFoundErr{}), errors.Is(err, &attachment.AttachmentNotFoundErr{}):
+		e.NewErrorResponse(ctx, http.StatusNotFound, err)
+	case errors.Is(err, &report.AlreadyFinalizedErr{}):
+		e.NewErrorResponse(ctx, http.StatusConflict, err)
+	case errors.Is(err, &attachment.AttachmentTooLargeErr{}):
+		e.NewErrorResponse(ctx, http.StatusRequestEntityTooLarge, err)
+	case errors.Is(err, &attachment.ContentTypeNotAllowedErr{}):
+		e.NewErrorResponse(ctx, http.StatusUnsupportedMediaType, err)
+	default:
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
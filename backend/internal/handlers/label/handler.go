@@ -14,6 +14,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-ozzo/ozzo-validation/v4"
 )
 
 const (
@@ -45,6 +46,7 @@ func (h *Handler) Register(router *gin.Engine) {
 		labelsGroup.GET("", h.getAllLabels)
 		labelsGroup.GET("/:id", h.getOneLabel)
 		labelsGroup.PATCH("/:id", h.updateLabel)
+		labelsGroup.PUT("/:id/parent", h.moveLabel)
 		labelsGroup.DELETE("/:id", h.deleteLabel)
 	}
 
@@ -59,6 +61,7 @@ func (h *Handler) Register(router *gin.Engine) {
 
 // @Summary Create label
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags labels
 // @Description create label
 // @Accept  json
@@ -67,7 +70,7 @@ func (h *Handler) Register(router *gin.Engine) {
 // @Param dto body label.CreateLabelDTO true "label info"
 // @Success 201 {string} string 1
 // @Failure 500 {object}  e.ErrorResponse
-// @Failure 400,404 {object} e.ErrorResponse
+// @Failure 400,404,409 {object} e.ErrorResponse
 // @Failure default {object}  e.ErrorResponse
 // @Router /api/v1/reports/{id}/labels [post]
 func (h *Handler) createLabel(ctx *gin.Context) {
@@ -99,10 +102,19 @@ func (h *Handler) createLabel(ctx *gin.Context) {
 	err = h.service.Create(userID, reportID, &t)
 
 	if err != nil {
-		if errors.Is(err, &report.ReportNotFoundErr{}) {
+		var validationErrs validation.Errors
+		if errors.As(err, &validationErrs) {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, &report.ReportNotFoundErr{}) || errors.Is(err, &label.LabelNotFoundErr{}) {
 			e.NewErrorResponse(ctx, http.StatusNotFound, err)
 			return
 		}
+		if errors.Is(err, &label.LabelExistsErr{}) || errors.Is(err, &report.AlreadyFinalizedErr{}) {
+			e.NewErrorResponse(ctx, http.StatusConflict, err)
+			return
+		}
 		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
@@ -144,6 +156,7 @@ func (h *Handler) getAllLabelsOnReport(ctx *gin.Context) {
 
 // @Summary Get all labels
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags labels
 // @Parametrs
 // @Description get labels from user
@@ -205,6 +218,7 @@ func (h *Handler) getOneLabel(ctx *gin.Context) {
 
 // @Summary Update label by ID
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags labels
 // @Description update one label by ID
 // @Accept  json
@@ -213,7 +227,7 @@ func (h *Handler) getOneLabel(ctx *gin.Context) {
 // @Param dto body label.UpdateLabelDTO true "label info"
 // @Success 204
 // @Failure 500 {object}  e.ErrorResponse
-// @Failure 400,404 {object} e.ErrorResponse
+// @Failure 400,404,409 {object} e.ErrorResponse
 // @Failure default {object}  e.ErrorResponse
 // @Router /api/v1/labels/{id} [patch]
 func (h *Handler) updateLabel(ctx *gin.Context) {
@@ -238,14 +252,75 @@ func (h *Handler) updateLabel(ctx *gin.Context) {
 		return
 	}
 
-	t := h.mapper.MapUpdateLabelDTO(dto)
-	err = h.service.Update(userID, labelID, t)
+	u := h.mapper.MapUpdateLabelDTO(dto)
+	err = h.service.Update(userID, labelID, u)
 	if err != nil {
 		h.logger.Info(err)
+		var validationErrs validation.Errors
+		if errors.As(err, &validationErrs) {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
 		if errors.Is(err, &label.LabelNotFoundErr{}) {
 			e.NewErrorResponse(ctx, http.StatusNotFound, err)
 			return
 		}
+		if errors.Is(err, &label.LabelNameTakenErr{}) {
+			e.NewErrorResponse(ctx, http.StatusConflict, err)
+			return
+		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Move label
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags labels
+// @Description put the label under another label, or to the top level without parentId
+// @Accept  json
+// @Param   id  path  string  true  "id"
+// @Param dto body label.MoveLabelDTO true "new parent"
+// @Success 204
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400,404,409 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/labels/{id}/parent [put]
+func (h *Handler) moveLabel(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		h.logger.Info(err)
+		return
+	}
+
+	labelID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var dto label.MoveLabelDTO
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.service.Move(userID, labelID, dto.ParentID)
+	if err != nil {
+		h.logger.Info(err)
+		if errors.Is(err, &label.LabelNotFoundErr{}) {
+			e.NewErrorResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, &label.LabelCycleErr{}) || errors.Is(err, &label.LabelNameTakenErr{}) {
+			e.NewErrorResponse(ctx, http.StatusConflict, err)
+			return
+		}
 		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
@@ -255,6 +330,7 @@ func (h *Handler) updateLabel(ctx *gin.Context) {
 
 // @Summary Delete one label by ID
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags labels
 // @Description delete one label by ID
 // @Accept  json
@@ -296,6 +372,7 @@ func (h *Handler) deleteLabel(ctx *gin.Context) {
 
 // @Summary Detach label by ID from report by ID
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags reports
 // @Description detach label by ID from report by ID
 // @Accept  json
@@ -304,7 +381,7 @@ func (h *Handler) deleteLabel(ctx *gin.Context) {
 // @Param   label_id  path  string  true  "label id"
 // @Success 200 {integer} integer 1
 // @Failure 500 {object}  e.ErrorResponse
-// @Failure 400,404 {object} e.ErrorResponse
+// @Failure 400,404,409 {object} e.ErrorResponse
 // @Failure default {object}  e.ErrorResponse
 // @Router /api/v1/reports/{id}/labels/{label_id} [delete]
 func (h *Handler) detachLabel(ctx *gin.Context) {
@@ -336,6 +413,10 @@ func (h *Handler) detachLabel(ctx *gin.Context) {
 			e.NewErrorResponse(ctx, http.StatusNotFound, err)
 			return
 		}
+		if errors.Is(err, &report.AlreadyFinalizedErr{}) {
+			e.NewErrorResponse(ctx, http.StatusConflict, err)
+			return
+		}
 		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/e"
+	"reports_system/pkg/logging"
+	"strings"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyScheme = "ApiKey"
+	apiKeyCtx    = "api_key_id"
+)
+
+// APIKeyChecker finds the owner and scopes of a key.
+type APIKeyChecker interface {
+	CheckAPIKey(key string) (account.APIKeyState, error)
+}
+
+var apiKeys APIKeyChecker
+
+// apiKeyRoutes are the route prefixes API keys can be used with and the scope
+// needed to change something there, reading needs any scope. The first match
+// wins, so longer prefixes go first. Account and admin routes aren't listed,
+// a key can't be used to manage the account or to create more keys.
+var apiKeyRoutes = []struct {
+	prefix string
+	scope  string
+}{
+	{"/api/v1/reports/:id/labels", account.ScopeLabelsWrite},
+	{"/api/v1/reports", account.ScopeReportsWrite},
+	{"/api/v1/templates", account.ScopeReportsWrite},
+	{"/api/v1/labels", account.ScopeLabelsWrite},
+}
+
+func getAPIKey(ctx *gin.Context) (string, bool) {
+	if key := ctx.GetHeader(apiKeyHeader); key != "" {
+		return key, true
+	}
+	scheme, key, found := strings.Cut(ctx.GetHeader(authorizationHeader), " ")
+	if found && strings.EqualFold(scheme, apiKeyScheme) {
+		return strings.TrimSpace(key), true
+	}
+	return "", false
+}
+
+func authenticateAPIKey(ctx *gin.Context, key string) {
+	state, err := apiKeys.CheckAPIKey(key)
+	if err != nil {
+		if errors.Is(err, &account.InvalidAPIKeyErr{}) {
+			e.NewErrorResponse(ctx, http.StatusUnauthorized, err)
+			return
+		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if state.Deactivated {
+		e.NewErrorResponse(ctx, http.StatusForbidden, &account.AccountDeactivatedErr{})
+		return
+	}
+
+	scope, ok := requiredScope(ctx.Request.Method, ctx.FullPath())
+	if !ok || !state.Allows(scope) {
+		e.NewErrorResponse(ctx, http.StatusForbidden, &account.ScopeNotGrantedErr{})
+		return
+	}
+
+	logging.GetLogger().Infof("api key %v of user %v: %v %v",
+		state.KeyID, state.UserID, ctx.Request.Method, ctx.Request.URL.Path)
+
+	ctx.Set(userCtx, state.UserID)
+	ctx.Set(roleCtx, state.Role)
+	ctx.Set(organizationCtx, state.OrganizationID)
+	ctx.Set(apiKeyCtx, state.KeyID)
+}
+
+func requiredScope(method, route string) (string, bool) {
+	for _, r := range apiKeyRoutes {
+		if route != r.prefix && !strings.HasPrefix(route, r.prefix+"/") {
+			continue
+		}
+		if method == http.MethodGet || method == http.MethodHead {
+			return account.ScopeRead, true
+		}
+		return r.scope, true
+	}
+	return "", false
+}
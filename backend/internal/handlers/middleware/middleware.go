@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"reports_system/internal/model/account"
 	"reports_system/pkg/e"
 	"reports_system/pkg/jwt"
 	"reports_system/pkg/logging"
@@ -13,9 +14,33 @@ import (
 const (
 	authorizationHeader = "Authorization"
 	userCtx             = "user_id"
+	sessionCtx          = "session_id"
+	roleCtx             = "role"
+	organizationCtx     = "organization_id"
+	impersonatorCtx     = "impersonator_id"
 )
 
+// SessionChecker tells whether a session is still alive. Sessions live in
+// postgres, so a logout on one backend is seen by every other one.
+type SessionChecker interface {
+	CheckSession(sessionID string) (account.SessionState, error)
+}
+
+var sessions SessionChecker
+
+func Init(checker SessionChecker, keys APIKeyChecker) {
+	sessions = checker
+	apiKeys = keys
+}
+
+// Authenticate accepts access tokens of sessions and API keys. Keys work only
+// on the routes listed in apiKeyRoutes.
 func Authenticate(ctx *gin.Context) {
+	if key, ok := getAPIKey(ctx); ok {
+		authenticateAPIKey(ctx, key)
+		return
+	}
+
 	header := ctx.GetHeader(authorizationHeader)
 	if header == "" {
 		e.NewErrorResponse(ctx, http.StatusUnauthorized, errors.New("unauthorized"))
@@ -26,14 +51,71 @@ func Authenticate(ctx *gin.Context) {
 		e.NewErrorResponse(ctx, http.StatusUnauthorized, errors.New("malformed token"))
 		return
 	}
-	userID, err := jwt.GetIdFromToken(headerParts[1])
+	claims, err := jwt.ParseAccessToken(headerParts[1])
 	if err != nil {
 		e.NewErrorResponse(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	state, err := sessions.CheckSession(claims.SessionID)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if status, err := checkState(state, claims); err != nil {
+		e.NewErrorResponse(ctx, status, err)
+		return
+	}
+
+	logger := logging.GetLogger()
+	logger.Info("authorized")
+	if state.ImpersonatorID != nil {
+		logger.Infof("admin %v acts as user %v: %v %v",
+			*state.ImpersonatorID, claims.UserID, ctx.Request.Method, ctx.Request.URL.Path)
+		ctx.Set(impersonatorCtx, *state.ImpersonatorID)
+	}
+
+	ctx.Set(userCtx, claims.UserID)
+	ctx.Set(sessionCtx, claims.SessionID)
+	ctx.Set(roleCtx, state.Role)
+	ctx.Set(organizationCtx, claims.OrganizationID)
+}
+
+// checkState lets a token through only while its session is active and
+// belongs to the account and the organization the token is issued for.
+func checkState(state account.SessionState, claims jwt.UserClaims) (int, error) {
+	if state.Deactivated {
+		return http.StatusForbidden, &account.AccountDeactivatedErr{}
+	}
+	if !state.Active {
+		return http.StatusUnauthorized, &account.SessionRevokedErr{}
+	}
+	if state.UserID != claims.UserID {
+		return http.StatusUnauthorized, errors.New("token is issued for another account")
 	}
+	// tokens issued before the organization was known carry none, a refresh fixes that
+	if state.OrganizationID != claims.OrganizationID {
+		return http.StatusUnauthorized, errors.New("token is issued for another organization")
+	}
+	return http.StatusOK, nil
+}
 
-	logging.GetLogger().Info("authorized")
+// RequireRole goes after Authenticate and lets through only accounts with the role.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetString(roleCtx) != role {
+			e.NewErrorResponse(ctx, http.StatusForbidden, &account.ForbiddenErr{})
+		}
+	}
+}
 
-	ctx.Set(userCtx, userID)
+// ForbidImpersonation goes after Authenticate and keeps admins acting as a
+// user away from the credentials of the account: passwords, second factor
+// and API keys, which would outlive the impersonation session.
+func ForbidImpersonation(ctx *gin.Context) {
+	if _, ok := ctx.Get(impersonatorCtx); ok {
+		e.NewErrorResponse(ctx, http.StatusForbidden, &account.ImpersonationForbiddenErr{})
+	}
 }
 
 func GetUserID(ctx *gin.Context) (int, error) {
@@ -49,3 +131,33 @@ func GetUserID(ctx *gin.Context) (int, error) {
 
 	return idNum, nil
 }
+
+// GetOrganizationID is the tenant of the request, every lookup across accounts
+// has to be limited to it.
+func GetOrganizationID(ctx *gin.Context) (int, error) {
+	id, ok := ctx.Get(organizationCtx)
+	if !ok {
+		return 0, errors.New("can't get authorization parameters")
+	}
+
+	organizationID, ok := id.(int)
+	if !ok {
+		return 0, errors.New("can't get authorization params")
+	}
+
+	return organizationID, nil
+}
+
+func GetSessionID(ctx *gin.Context) (string, error) {
+	id, ok := ctx.Get(sessionCtx)
+	if !ok {
+		return "", errors.New("can't get authorization parameters")
+	}
+
+	sessionID, ok := id.(string)
+	if !ok {
+		return "", errors.New("can't get authorization params")
+	}
+
+	return sessionID, nil
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/jwt"
+	"testing"
+)
+
+func impersonationRouter(impersonatorID *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/password", func(ctx *gin.Context) {
+		ctx.Set(userCtx, 2)
+		if impersonatorID != nil {
+			ctx.Set(impersonatorCtx, *impersonatorID)
+		}
+	}, ForbidImpersonation, func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+func TestForbidImpersonation(t *testing.T) {
+	adminID := 1
+	tests := []struct {
+		name           string
+		impersonatorID *int
+		want           int
+	}{
+		{"own session", nil, http.StatusNoContent},
+		{"impersonated session", &adminID, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			impersonationRouter(tt.impersonatorID).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/password", nil))
+			if w.Code != tt.want {
+				t.Fatalf("got status %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckState(t *testing.T) {
+	claims := jwt.UserClaims{UserID: 2, OrganizationID: 1, SessionID: "s"}
+	active := account.SessionState{UserID: 2, Active: true, Role: account.RoleUser, OrganizationID: 1}
+
+	tests := []struct {
+		name   string
+		change func(s *account.SessionState)
+		want   int
+	}{
+		{"own session", func(s *account.SessionState) {}, http.StatusOK},
+		{"revoked session", func(s *account.SessionState) { s.Active = false }, http.StatusUnauthorized},
+		{"deactivated account", func(s *account.SessionState) { s.Deactivated = true }, http.StatusForbidden},
+		{"session of another account", func(s *account.SessionState) { s.UserID = 3 }, http.StatusUnauthorized},
+		{"another organization", func(s *account.SessionState) { s.OrganizationID = 4 }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := active
+			tt.change(&state)
+			status, err := checkState(state, claims)
+			if status != tt.want || (err == nil) != (tt.want == http.StatusOK) {
+				t.Fatalf("got status %v and %v, want %v", status, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package numbering
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reports_system/internal/handlers/middleware"
+	"reports_system/internal/model/report"
+	"reports_system/internal/service"
+	"reports_system/pkg/e"
+	"reports_system/pkg/logging"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiURLGroup     = "/api"
+	reportsURLGroup = "/reports"
+	finalizeURL     = "/finalize"
+	apiVersion      = "1"
+)
+
+type Handler struct {
+	logger  logging.Logger
+	service service.Numbering
+}
+
+func NewHandler(logger logging.Logger, service service.Numbering) *Handler {
+	return &Handler{logger: logger, service: service}
+}
+
+func (h *Handler) Register(router *gin.Engine) {
+	groupName := fmt.Sprintf("%v/v%v%v/:id", apiURLGroup, apiVersion, reportsURLGroup)
+
+	h.logger.Tracef("Register route: %v%v", groupName, finalizeURL)
+
+	group := router.Group(groupName, middleware.Authenticate)
+	{
+		group.POST(finalizeURL, h.finalizeReport) // /api/v1/reports/:id/finalize
+	}
+}
+
+// @Summary Finalize report
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags reports
+// @Description finalize report draft and allocate its registration number
+// @Accept  json
+// @Produce  json
+// @Param   id  path  string  true  "id"
+// @Success 200 {object} report.FinalizedReportDTO
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400,404,409 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/reports/{id}/finalize [post]
+func (h *Handler) finalizeReport(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	reportID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	number, err := h.service.Finalize(userID, reportID)
+	if err != nil {
+		h.logger.Info(err)
+		switch {
+		case errors.Is(err, &report.ReportNotFoundErr{}):
+			e.NewErrorResponse(ctx, http.StatusNotFound, err)
+		case errors.Is(err, &report.AlreadyFinalizedErr{}):
+			e.NewErrorResponse(ctx, http.StatusConflict, err)
+		case errors.Is(err, &report.DepartmentRequiredErr{}):
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		default:
+			e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report.FinalizedReportDTO{
+		ID:     reportID,
+		Status: report.StatusFinalized,
+		Number: number,
+	})
+}
@@ -0,0 +1,73 @@
+package organization
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reports_system/internal/handlers/middleware"
+	"reports_system/internal/mapper"
+	"reports_system/internal/model/account"
+	"reports_system/internal/service"
+	"reports_system/pkg/e"
+	"reports_system/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiURLGroup     = "/api"
+	organizationURL = "/organization"
+	apiVersion      = "1"
+)
+
+type Handler struct {
+	logger  logging.Logger
+	service service.Organization
+	mapper  mapper.Account
+}
+
+func NewHandler(logger logging.Logger, service service.Organization, mapper mapper.Account) *Handler {
+	return &Handler{logger: logger, service: service, mapper: mapper}
+}
+
+func (h *Handler) Register(router *gin.Engine) {
+	groupName := fmt.Sprintf("%v/v%v%v", apiURLGroup, apiVersion, organizationURL)
+
+	h.logger.Tracef("Register route: %v", groupName)
+
+	group := router.Group(groupName, middleware.Authenticate)
+	{
+		group.GET("", h.getCurrent)
+	}
+}
+
+// @Summary getOrganization
+// @Security ApiKeyAuth
+// @Tags account
+// @Description organization of the current user, nothing of other organizations is ever visible
+// @ID get-organization
+// @Produce  json
+// @Success 200 {object} account.OrganizationDTO
+// @Failure 404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/organization [get]
+func (h *Handler) getCurrent(ctx *gin.Context) {
+	organizationID, err := middleware.GetOrganizationID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	o, err := h.service.GetOne(organizationID)
+	if err != nil {
+		h.logger.Info(err)
+		if errors.Is(err, &account.OrganizationNotFoundErr{}) {
+			e.NewErrorResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapOrganizationDTO(o))
+}
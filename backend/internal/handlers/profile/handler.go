@@ -0,0 +1,231 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reports_system/internal/handlers/middleware"
+	"reports_system/internal/mapper"
+	"reports_system/internal/model/account"
+	"reports_system/internal/service"
+	"reports_system/pkg/e"
+	"reports_system/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	apiURLGroup      = "/api"
+	accountsURLGroup = "/accounts"
+	meURL            = "/me"
+	exportURL        = "/export"
+	deletionURL      = "/deletion"
+	apiVersion       = "1"
+
+	exportContentType = "application/zip"
+)
+
+type Handler struct {
+	logger  logging.Logger
+	service service.Profile
+	mapper  mapper.Account
+}
+
+func NewHandler(logger logging.Logger, service service.Profile, mapper mapper.Account) *Handler {
+	return &Handler{logger: logger, service: service, mapper: mapper}
+}
+
+func (h *Handler) Register(router *gin.Engine) {
+	groupName := fmt.Sprintf("%v/v%v%v%v", apiURLGroup, apiVersion, accountsURLGroup, meURL)
+
+	h.logger.Tracef("Register route: %v", groupName)
+
+	me := router.Group(groupName, middleware.Authenticate)
+	{
+		me.GET("", h.getProfile)
+		me.PATCH("", h.updateProfile)
+		me.DELETE("", h.deleteAccount)
+		me.POST(deletionURL, h.requestDeletion)
+		me.GET(exportURL, h.exportData)
+	}
+}
+
+// @Summary getProfile
+// @Security ApiKeyAuth
+// @Tags account
+// @Description get account of the current user
+// @ID get-profile
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} account.GetAccountDTO
+// @Failure 500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me [get]
+func (h *Handler) getProfile(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	a, err := h.service.Get(userID)
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapAccountDTO(a))
+}
+
+// @Summary updateProfile
+// @Security ApiKeyAuth
+// @Tags account
+// @Description change name, username or email, a new email has to be verified again
+// @ID update-profile
+// @Accept  json
+// @Produce  json
+// @Param dto body account.UpdateAccountDTO true "changed fields"
+// @Success 200 {object} account.GetAccountDTO
+// @Failure 400,409,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me [patch]
+func (h *Handler) updateProfile(ctx *gin.Context) {
+	var dto account.UpdateAccountDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	a, err := h.service.Update(userID, h.mapper.MapUpdateAccountDTO(dto))
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapAccountDTO(a))
+}
+
+// @Summary deleteAccount
+// @Security ApiKeyAuth
+// @Tags account
+// @Description delete account of the current user, its reports are transferred to another account or deleted, accounts with finalized reports have to transfer them, accounts signing in through ldap or sso confirm with the token mailed by /accounts/me/deletion instead of the password
+// @ID delete-account
+// @Accept  json
+// @Produce  json
+// @Param dto body account.DeleteAccountDTO true "password or token and what to do with reports"
+// @Success 204
+// @Failure 400,403,409,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me [delete]
+func (h *Handler) deleteAccount(ctx *gin.Context) {
+	var dto account.DeleteAccountDTO
+
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = h.service.Delete(userID, h.mapper.MapDeleteAccountDTO(dto)); err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary requestDeletion
+// @Security ApiKeyAuth
+// @Tags account
+// @Description mail a token confirming the deletion to an account signing in through ldap or sso
+// @ID request-deletion
+// @Produce  json
+// @Success 204
+// @Failure 400,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/deletion [post]
+func (h *Handler) requestDeletion(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = h.service.RequestDeletion(userID); err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary exportData
+// @Security ApiKeyAuth
+// @Tags account
+// @Description download a zip archive with all data of the current user: account, reports, templates and attachments
+// @ID export-data
+// @Produce  application/zip
+// @Success 200 {file} file
+// @Failure 500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/me/export [get]
+func (h *Handler) exportData(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Header("Content-Type", exportContentType)
+	ctx.Header("Content-Disposition", `attachment; filename="account.zip"`)
+	ctx.Status(http.StatusOK)
+
+	if err = h.service.Export(userID, ctx.Writer); err != nil {
+		h.logger.Error(err)
+		// the archive is built after all data is collected, so most
+		// failures happen before anything is sent
+		if !ctx.Writer.Written() {
+			ctx.Writer.Header().Del("Content-Type")
+			ctx.Writer.Header().Del("Content-Disposition")
+			e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		}
+	}
+}
+
+func (h *Handler) newErrorResponse(ctx *gin.Context, err error) {
+	var validationErrs validation.Errors
+	switch {
+	case errors.As(err, &validationErrs),
+		errors.Is(err, &account.TransferTargetNotFoundErr{}),
+		errors.Is(err, &account.UnknownReportsDispositionErr{}),
+		errors.Is(err, &account.DeletionByPasswordErr{}):
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, &account.PasswordDoesNotMatchErr{}),
+		errors.Is(err, &account.InvalidDeletionTokenErr{}):
+		e.NewErrorResponse(ctx, http.StatusForbidden, err)
+	case errors.Is(err, &account.UsernameTakenErr{}),
+		errors.Is(err, &account.FinalizedReportsErr{}):
+		e.NewErrorResponse(ctx, http.StatusConflict, err)
+	default:
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
@@ -11,19 +11,31 @@ import (
 	"reports_system/internal/mapper"
 	"reports_system/internal/model/account"
 	"reports_system/internal/model/report"
+	"reports_system/internal/model/template"
 	"reports_system/internal/service"
 	"reports_system/pkg/e"
+	"reports_system/pkg/export"
 	"reports_system/pkg/logging"
 	"strconv"
+	"strings"
 )
 
 const (
-	reportsURLGroup = "/reports"
-	apiURLGroup     = "/api"
-	apiVersion      = "1"
-	labelSearchKey  = "label"
+	reportsURLGroup  = "/reports"
+	apiURLGroup      = "/api"
+	apiVersion       = "1"
+	labelSearchKey   = "label"
+	textSearchKey    = "q"
+	templateIDKey    = "template_id"
+	formatKey        = "format"
+	csvFormat        = "csv"
+	xlsxFormat       = "xlsx"
+	registryLabelSep = ", "
+	registryTimeFmt  = "2006-01-02 15:04"
 )
 
+var registryColumns = []string{"id", "number", "header", "short body", "labels", "edited", "department", "classification"}
+
 type Handler struct {
 	logger  logging.Logger
 	service service.Report
@@ -51,14 +63,16 @@ func (h *Handler) Register(router *gin.Engine) {
 
 // @Summary Create report
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags reports
 // @Description create report
 // @Accept  json
 // @Produce  json
 // @Param dto body report.CreateReportDTO true "report content"
+// @Param   template_id query  string  false  "create report from template, header and body become optional"
 // @Success 201 {string} string 1
 // @Failure 500 {object}  e.ErrorResponse
-// @Failure 400,404 {object} e.ErrorResponse
+// @Failure 400,403,404 {object} e.ErrorResponse
 // @Failure default {object}  e.ErrorResponse
 // @Router /api/v1/reports [post]
 func (h *Handler) createReport(ctx *gin.Context) {
@@ -69,6 +83,11 @@ func (h *Handler) createReport(ctx *gin.Context) {
 		return
 	}
 
+	if rawTemplateID := ctx.Query(templateIDKey); rawTemplateID != "" {
+		h.createReportFromTemplate(ctx, userID, rawTemplateID)
+		return
+	}
+
 	var dto report.CreateReportDTO
 	if err := ctx.BindJSON(&dto); err != nil {
 		h.logger.Info(err)
@@ -83,6 +102,56 @@ func (h *Handler) createReport(ctx *gin.Context) {
 	n := h.mapper.MapCreateReportDTO(dto)
 	err = h.service.Create(userID, &n)
 	if err != nil {
+		h.logger.Info(err)
+		if errors.Is(err, &report.UnknownClassificationErr{}) {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, &report.AboveClearanceErr{}) {
+			e.NewErrorResponse(ctx, http.StatusForbidden, err)
+			return
+		}
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, fmt.Sprintf(
+		"%s%s/%v", apiURLGroup, reportsURLGroup, n.ID))
+}
+
+func (h *Handler) createReportFromTemplate(ctx *gin.Context, userID int, rawTemplateID string) {
+	templateID, err := strconv.Atoi(rawTemplateID)
+	if err != nil {
+		h.logger.Info("error while getting template id from request")
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var dto report.CreateReportFromTemplateDTO
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.BindJSON(&dto); err != nil {
+			h.logger.Info(err)
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	n := h.mapper.MapCreateReportFromTemplateDTO(dto)
+	err = h.service.CreateFromTemplate(userID, templateID, &n)
+	if err != nil {
+		h.logger.Info(err)
+		if errors.Is(err, &template.TemplateNotFoundErr{}) {
+			e.NewErrorResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, &report.UnknownClassificationErr{}) {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, &report.AboveClearanceErr{}) {
+			e.NewErrorResponse(ctx, http.StatusForbidden, err)
+			return
+		}
 		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
@@ -93,11 +162,14 @@ func (h *Handler) createReport(ctx *gin.Context) {
 
 // @Summary Get all reports from user filter by label
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags reports
 // @Description create report
 // @Accept  json
 // @Produce  json
 // @Param   label query  string  false  "reports search by label"
+// @Param   q query  string  false  "full-text search in reports and attached documents"
+// @Param   format query  string  false  "export registry as csv or xlsx instead of json"
 // @Success 200 {object} report.GetAllReportsDTO
 // @Failure 500 {object}  e.ErrorResponse
 // @Failure 400,404 {object} e.ErrorResponse
@@ -118,7 +190,19 @@ func (h *Handler) getAllReports(ctx *gin.Context) {
 
 	keys := ctx.Request.URL.Query()
 	values := keys[labelSearchKey]
-	if values == nil {
+	if format := keys.Get(formatKey); format != "" {
+		h.exportReports(ctx, userID, values, format)
+		return
+	}
+
+	if query := keys.Get(textSearchKey); query != "" {
+		ns, err = h.service.Search(userID, query, values)
+		if err != nil {
+			h.logger.Info(err)
+			e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+			return
+		}
+	} else if values == nil {
 		ns, err = h.service.GetAll(userID)
 		if err != nil {
 			if errors.Is(err, &report.ReportNotFoundErr{}) {
@@ -142,8 +226,73 @@ func (h *Handler) getAllReports(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, dto)
 }
 
+func (h *Handler) exportReports(ctx *gin.Context, userID int, labelNames []string, format string) {
+	var (
+		w           export.Writer
+		contentType string
+		filename    string
+		err         error
+	)
+
+	switch format {
+	case csvFormat:
+		w, err = export.NewCSVWriter(ctx.Writer)
+		contentType, filename = export.CSVContentType, "reports.csv"
+	case xlsxFormat:
+		w, err = export.NewXLSXWriter(ctx.Writer)
+		contentType, filename = export.XLSXContentType, "reports.xlsx"
+	default:
+		e.NewErrorResponse(ctx, http.StatusBadRequest, &report.UnsupportedExportFormatErr{})
+		return
+	}
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	// nothing is written before the writer is ready, so the errors above still
+	// reach the client as json
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(http.StatusOK)
+
+	if err = w.Write(registryColumns); err != nil {
+		h.logger.Info(err)
+		return
+	}
+
+	err = h.service.Export(userID, labelNames, func(row report.RegistryRow) error {
+		labels := make([]string, 0, len(row.Labels))
+		for _, t := range row.Labels {
+			labels = append(labels, t.Name)
+		}
+
+		return w.Write([]string{
+			strconv.Itoa(row.ID),
+			row.Number,
+			row.Header,
+			row.ShortBody,
+			strings.Join(labels, registryLabelSep),
+			row.Edited.Format(registryTimeFmt),
+			row.Department,
+			row.Classification,
+		})
+	})
+	if err != nil {
+		// headers are already sent, so the only thing left is to cut the file short
+		h.logger.Error(err)
+		return
+	}
+
+	if err = w.Close(); err != nil {
+		h.logger.Error(err)
+	}
+}
+
 // @Summary Get Report By Id
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags reports
 // @Description get report by id
 // @ID get-report-by-id
@@ -184,6 +333,7 @@ func (h *Handler) getOneReport(ctx *gin.Context) {
 
 // @Summary Update Report
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags reports
 // @Description update report
 // @ID update-report
@@ -192,6 +342,7 @@ func (h *Handler) getOneReport(ctx *gin.Context) {
 // @Param   id   path  string  true  "id"
 // @Param dto body report.UpdateReportDTO true "report content"
 // @Success 204
+// @Failure 400,403,404,409 {object} e.ErrorResponse
 // @Failure 500 {object} e.ErrorResponse
 // @Failure default {object} e.ErrorResponse
 // @Router /api/v1/reports/{id} [patch]
@@ -243,6 +394,22 @@ func (h *Handler) updateReport(ctx *gin.Context) {
 
 	if err != nil {
 		h.logger.Info(err)
+		if errors.Is(err, &report.UnknownClassificationErr{}) {
+			e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, &report.AboveClearanceErr{}) {
+			e.NewErrorResponse(ctx, http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, &report.ReportNotFoundErr{}) {
+			e.NewErrorResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, &report.AlreadyFinalizedErr{}) {
+			e.NewErrorResponse(ctx, http.StatusConflict, err)
+			return
+		}
 		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
@@ -252,6 +419,7 @@ func (h *Handler) updateReport(ctx *gin.Context) {
 
 // @Summary Delete Report
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 // @Tags reports
 // @Description delete report
 // @ID delete-report
@@ -259,6 +427,7 @@ func (h *Handler) updateReport(ctx *gin.Context) {
 // @Produce json
 // @Param   id   path string  true  "id"
 // @Success 204
+// @Failure 404,409 {object} e.ErrorResponse
 // @Failure 500 {object} e.ErrorResponse
 // @Failure default {object} e.ErrorResponse
 // @Router /api/v1/reports/{id} [delete]
@@ -278,6 +447,15 @@ func (h *Handler) deleteReport(ctx *gin.Context) {
 
 	err = h.service.Delete(userID, reportID)
 	if err != nil {
+		h.logger.Info(err)
+		if errors.Is(err, &report.ReportNotFoundErr{}) {
+			e.NewErrorResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, &report.AlreadyFinalizedErr{}) {
+			e.NewErrorResponse(ctx, http.StatusConflict, err)
+			return
+		}
 		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
@@ -0,0 +1,134 @@
+package sso
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reports_system/internal/mapper"
+	"reports_system/internal/model/account"
+	"reports_system/internal/service"
+	"reports_system/pkg/e"
+	"reports_system/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiURLGroup      = "/api"
+	accountsURLGroup = "/accounts"
+	oidcURLGroup     = "/oidc"
+	loginURL         = "/login"
+	callbackURL      = "/callback"
+	apiVersion       = "1"
+
+	// stateCookie binds the callback to the browser that started the login,
+	// so nobody can slip their own login into someone else's browser.
+	stateCookie = "oidc_state"
+)
+
+var groupName = fmt.Sprintf("%v/v%v%v%v", apiURLGroup, apiVersion, accountsURLGroup, oidcURLGroup)
+
+type Handler struct {
+	logger  logging.Logger
+	service service.OIDC
+	mapper  mapper.Account
+}
+
+func NewHandler(logger logging.Logger, service service.OIDC, mapper mapper.Account) *Handler {
+	return &Handler{logger: logger, service: service, mapper: mapper}
+}
+
+func (h *Handler) Register(router *gin.Engine) {
+	h.logger.Tracef("Register route: %v", groupName)
+
+	group := router.Group(groupName)
+	{
+		group.GET(loginURL, h.login)
+		group.GET(callbackURL, h.callback)
+	}
+}
+
+// @Summary oidcLogin
+// @Tags account
+// @Description start single sign-on, redirects to the identity provider of the university
+// @ID oidc-login
+// @Success 302
+// @Failure 404,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/oidc/login [get]
+func (h *Handler) login(ctx *gin.Context) {
+	state, redirect, err := h.service.Begin(ctx.Request.Context())
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	h.setStateCookie(ctx, state, 0)
+	ctx.Redirect(http.StatusFound, redirect)
+}
+
+// @Summary oidcCallback
+// @Tags account
+// @Description the identity provider redirects here after login, returns tokens like the password login
+// @ID oidc-callback
+// @Produce  json
+// @Param code  query string true "authorization code"
+// @Param state query string true "state from the login redirect"
+// @Success 200 {object} account.WithTokenDTO
+// @Failure 400,401,403,404,409,500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /api/v1/accounts/oidc/callback [get]
+func (h *Handler) callback(ctx *gin.Context) {
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		h.logger.Infof("identity provider refused login: %s %s", providerErr, ctx.Query("error_description"))
+		e.NewErrorResponse(ctx, http.StatusUnauthorized, &account.SSOFailedErr{})
+		return
+	}
+
+	state := ctx.Query("state")
+	code := ctx.Query("code")
+	if state == "" || code == "" {
+		e.NewErrorResponse(ctx, http.StatusBadRequest, errors.New("code and state are required"))
+		return
+	}
+	if cookie, err := ctx.Cookie(stateCookie); err != nil || cookie != state {
+		e.NewErrorResponse(ctx, http.StatusBadRequest, &account.InvalidSSOStateErr{})
+		return
+	}
+	h.setStateCookie(ctx, "", -1)
+
+	client := account.NewClient(ctx.Request.UserAgent(), ctx.ClientIP())
+
+	tokens, a, err := h.service.Complete(ctx.Request.Context(), code, state, client)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.mapper.MapAccountWithTokenDTO(tokens, a))
+}
+
+// setStateCookie uses Lax, the callback is a top level redirect from the provider.
+func (h *Handler) setStateCookie(ctx *gin.Context, state string, maxAge int) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(stateCookie, state, maxAge, groupName, "", ctx.Request.TLS != nil, true)
+}
+
+func (h *Handler) newErrorResponse(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, &account.SSODisabledErr{}):
+		e.NewErrorResponse(ctx, http.StatusNotFound, err)
+	case errors.Is(err, &account.InvalidSSOStateErr{}):
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, &account.SSOFailedErr{}):
+		e.NewErrorResponse(ctx, http.StatusUnauthorized, err)
+	case errors.Is(err, &account.AccountDeactivatedErr{}):
+		e.NewErrorResponse(ctx, http.StatusForbidden, err)
+	case errors.Is(err, &account.SSOEmailConflictErr{}):
+		e.NewErrorResponse(ctx, http.StatusConflict, err)
+	default:
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,249 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reports_system/internal/handlers/middleware"
+	"reports_system/internal/mapper"
+	"reports_system/internal/model/template"
+	"reports_system/internal/service"
+	"reports_system/pkg/e"
+	"reports_system/pkg/logging"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	apiURLGroup       = "/api"
+	templatesURLGroup = "/templates"
+	apiVersion        = "1"
+)
+
+type Handler struct {
+	logger  logging.Logger
+	service service.Template
+	mapper  mapper.Template
+}
+
+func NewHandler(logger logging.Logger, service service.Template, mapper mapper.Template) *Handler {
+	return &Handler{logger: logger, service: service, mapper: mapper}
+}
+
+func (h *Handler) Register(router *gin.Engine) {
+	groupName := fmt.Sprintf("%v/v%v%v", apiURLGroup, apiVersion, templatesURLGroup)
+
+	h.logger.Tracef("Register route: %v", groupName)
+
+	group := router.Group(groupName, middleware.Authenticate)
+	{
+		group.GET("", h.getAllTemplates)       // /api/v1/templates
+		group.POST("", h.createTemplate)       // /api/v1/templates
+		group.GET("/:id", h.getOneTemplate)    // /api/v1/templates/:id
+		group.PATCH("/:id", h.updateTemplate)  // /api/v1/templates/:id
+		group.DELETE("/:id", h.deleteTemplate) // /api/v1/templates/:id
+	}
+}
+
+// @Summary Create template
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags templates
+// @Description create report template, header and body may contain {{date}}, {{year}}, {{department}} and {{author}}
+// @Accept  json
+// @Produce  json
+// @Param dto body template.CreateTemplateDTO true "template content"
+// @Success 201 {string} string 1
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/templates [post]
+func (h *Handler) createTemplate(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	var dto template.CreateTemplateDTO
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	t := h.mapper.MapCreateTemplateDTO(dto)
+	err = h.service.Create(userID, &t)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, fmt.Sprintf(
+		"%s/v%s%s/%v", apiURLGroup, apiVersion, templatesURLGroup, t.ID))
+}
+
+// @Summary Get all templates
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags templates
+// @Description get templates of user
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} template.GetAllTemplatesDTO
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/templates [get]
+func (h *Handler) getAllTemplates(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates, err := h.service.GetAll(userID)
+	if err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	dto := h.mapper.MapGetAllTemplatesDTO(templates)
+
+	ctx.JSON(http.StatusOK, dto)
+}
+
+// @Summary Get template by ID
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags templates
+// @Description get one template by ID
+// @Accept  json
+// @Produce  json
+// @Param   id  path  string  true  "id"
+// @Success 200 {object} template.Template
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400,404 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/templates/{id} [get]
+func (h *Handler) getOneTemplate(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	templateID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	t, err := h.service.GetOne(userID, templateID)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, t)
+}
+
+// @Summary Update template by ID
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags templates
+// @Description update one template by ID
+// @Accept  json
+// @Produce  json
+// @Param   id  path  string  true  "id"
+// @Param dto body template.UpdateTemplateDTO true "template content"
+// @Success 204
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400,404 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/templates/{id} [patch]
+func (h *Handler) updateTemplate(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	templateID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	var dto template.UpdateTemplateDTO
+	if err := ctx.BindJSON(&dto); err != nil {
+		h.logger.Info(err)
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	t := h.mapper.MapUpdateTemplateDTO(templateID, dto)
+	err = h.service.Update(userID, t)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Delete template by ID
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+// @Tags templates
+// @Description delete one template by ID
+// @Accept  json
+// @Produce  json
+// @Param   id  path  string  true  "id"
+// @Success 204
+// @Failure 500 {object}  e.ErrorResponse
+// @Failure 400,404 {object} e.ErrorResponse
+// @Failure default {object}  e.ErrorResponse
+// @Router /api/v1/templates/{id} [delete]
+func (h *Handler) deleteTemplate(ctx *gin.Context) {
+	userID, err := middleware.GetUserID(ctx)
+	if err != nil {
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	templateID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		h.logger.Info("error while getting id from request")
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.service.Delete(userID, templateID)
+	if err != nil {
+		h.logger.Info(err)
+		h.newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) newErrorResponse(ctx *gin.Context, err error) {
+	var validationErrs validation.Errors
+	switch {
+	case errors.Is(err, &template.TemplateNotFoundErr{}):
+		e.NewErrorResponse(ctx, http.StatusNotFound, err)
+	case errors.As(err, &validationErrs):
+		e.NewErrorResponse(ctx, http.StatusBadRequest, err)
+	default:
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,57 @@
+package wellknown
+
+import (
+	"net/http"
+	"reports_system/pkg/e"
+	"reports_system/pkg/jwt"
+	"reports_system/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	jwksURL = "/.well-known/jwks.json"
+
+	// jwksMaxAge lets verifiers cache the keys, a new key is added well before
+	// it starts signing, see session.JWT.
+	jwksMaxAge = "public, max-age=300"
+)
+
+type JWKS struct {
+	Keys []jwt.JSONWebKey `json:"keys"`
+}
+
+type Handler struct {
+	logger logging.Logger
+}
+
+func NewHandler(logger logging.Logger) *Handler {
+	return &Handler{logger: logger}
+}
+
+func (h *Handler) Register(router *gin.Engine) {
+	h.logger.Tracef("Register route: %v", jwksURL)
+
+	router.GET(jwksURL, h.getJWKS)
+}
+
+// @Summary getJWKS
+// @Tags auth
+// @Description public keys access tokens are signed with, for services that verify them
+// @ID get-jwks
+// @Produce  json
+// @Success 200 {object} wellknown.JWKS
+// @Failure 500 {object} e.ErrorResponse
+// @Failure default {object} e.ErrorResponse
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) getJWKS(ctx *gin.Context) {
+	keys, err := jwt.PublicKeys()
+	if err != nil {
+		h.logger.Error(err)
+		e.NewErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", jwksMaxAge)
+	ctx.JSON(http.StatusOK, JWKS{Keys: keys})
+}
@@ -13,21 +13,16 @@ func New(logger logging.Logger) *mapper {
 	return &mapper{logger: logger}
 }
 
+// MapRegisterAccountDTO leaves the hash empty, the password is hashed by the
+// service once it passed validation.
 func (m *mapper) MapRegisterAccountDTO(dto account.RegisterAccountDTO) (account.Account, error) {
-	phash, err := account.GeneratePasswordHash(dto.Password)
-	if err != nil {
-		m.logger.Info(err)
-		return account.Account{}, err
-	}
-
-	m.logger.Info("Account password hashed")
 	return account.Account{
 		ID:           0,
 		Name:         dto.Name,
 		Username:     dto.Username,
 		Email:        dto.Email,
 		Password:     dto.Password,
-		PasswordHash: phash,
+		PasswordHash: "",
 	}, nil
 }
 
@@ -42,19 +37,200 @@ func (m *mapper) MapLogInAccountDTO(dto account.LoginAccountDTO) account.Account
 	}
 }
 
-func (m *mapper) MapAccountWithTokenDTO(token string, a account.Account) account.WithTokenDTO {
+func (m *mapper) MapAccountWithTokenDTO(tokens account.Tokens, a account.Account) account.WithTokenDTO {
 	return account.WithTokenDTO{
-		Token:    token,
-		Name:     a.Name,
-		Username: a.Username,
-		Email:    a.Email,
+		Token:        tokens.Access,
+		RefreshToken: tokens.Refresh,
+		MFARequired:  tokens.Challenge != "",
+		MFAToken:     tokens.Challenge,
+		Name:         a.Name,
+		Username:     a.Username,
+		Email:        a.Email,
+		Department:   a.Department,
+	}
+}
+
+func (m *mapper) MapTokensDTO(tokens account.Tokens) account.TokensDTO {
+	return account.TokensDTO{
+		Token:        tokens.Access,
+		RefreshToken: tokens.Refresh,
 	}
 }
 
 func (m *mapper) MapAccountDTO(a account.Account) account.GetAccountDTO {
 	return account.GetAccountDTO{
-		Name:     a.Name,
-		Username: a.Username,
-		Email:    a.Email,
+		Name:          a.Name,
+		Username:      a.Username,
+		Email:         a.Email,
+		Department:    a.Department,
+		EmailVerified: a.EmailVerified,
+		EmailPublic:   a.EmailPublic,
 	}
 }
+
+func (m *mapper) MapGetAllSessionsDTO(sessions []account.Session, currentID string) account.GetAllSessionsDTO {
+	dtos := make([]account.SessionDTO, len(sessions))
+	for i, ss := range sessions {
+		dtos[i] = account.SessionDTO{
+			ID:        ss.ID,
+			UserAgent: ss.UserAgent,
+			IP:        ss.IP,
+			Created:   ss.Created,
+			LastSeen:  ss.LastSeen,
+			Current:   ss.ID == currentID,
+		}
+	}
+	return account.GetAllSessionsDTO{Sessions: dtos}
+}
+
+func (m *mapper) MapTOTPEnrollmentDTO(e account.Enrollment) account.TOTPEnrollmentDTO {
+	return account.TOTPEnrollmentDTO{
+		Secret: e.Secret,
+		URI:    e.URI,
+	}
+}
+
+func (m *mapper) MapUpdateAccountDTO(dto account.UpdateAccountDTO) account.ProfileUpdate {
+	return account.ProfileUpdate{
+		Name:        dto.Name,
+		Username:    dto.Username,
+		Email:       dto.Email,
+		EmailPublic: dto.EmailPublic,
+	}
+}
+
+func (m *mapper) MapDeleteAccountDTO(dto account.DeleteAccountDTO) account.Deletion {
+	return account.Deletion{
+		Password:   dto.Password,
+		Token:      dto.Token,
+		Reports:    dto.Reports,
+		TransferTo: dto.TransferTo,
+	}
+}
+
+func (m *mapper) MapGetAllPublicAccountsDTO(accounts []account.Account) account.GetAllPublicAccountsDTO {
+	dtos := make([]account.PublicAccountDTO, len(accounts))
+	for i, a := range accounts {
+		dtos[i] = account.PublicAccountDTO{
+			ID:         a.ID,
+			Name:       a.Name,
+			Username:   a.Username,
+			Department: a.Department,
+		}
+		if a.EmailPublic {
+			dtos[i].Email = a.Email
+		}
+	}
+	return account.GetAllPublicAccountsDTO{Accounts: dtos}
+}
+
+func (m *mapper) MapGetAllAdminAccountsDTO(accounts []account.Account) account.GetAllAdminAccountsDTO {
+	dtos := make([]account.AdminAccountDTO, len(accounts))
+	for i, a := range accounts {
+		dtos[i] = account.AdminAccountDTO{
+			ID:            a.ID,
+			Name:          a.Name,
+			Username:      a.Username,
+			Email:         a.Email,
+			Department:    a.Department,
+			Role:          a.Role,
+			Clearance:     a.Clearance,
+			EmailVerified: a.EmailVerified,
+			TOTPEnabled:   a.TOTPEnabled,
+			Deactivated:   a.Deactivated,
+		}
+	}
+	return account.GetAllAdminAccountsDTO{Accounts: dtos}
+}
+
+func (m *mapper) MapGetAllAuditEntriesDTO(entries []account.AuditEntry) account.GetAllAuditEntriesDTO {
+	dtos := make([]account.AuditEntryDTO, len(entries))
+	for i, e := range entries {
+		dtos[i] = account.AuditEntryDTO{
+			ID:       e.ID,
+			AdminID:  e.AdminID,
+			Action:   e.Action,
+			TargetID: e.TargetID,
+			Details:  e.Details,
+			Created:  e.Created,
+		}
+	}
+	return account.GetAllAuditEntriesDTO{Entries: dtos}
+}
+
+func (m *mapper) MapInviteDTO(organizationID int, dto account.InviteDTO) account.Invitation {
+	i := account.Invitation{
+		OrganizationID: organizationID,
+		Email:          dto.Email,
+		Role:           dto.Role,
+		Department:     dto.Department,
+	}
+	if dto.Expires != nil {
+		i.Expires = *dto.Expires
+	}
+	return i
+}
+
+func (m *mapper) MapInvitationDTO(i account.Invitation) account.InvitationDTO {
+	return account.InvitationDTO{
+		ID:         i.ID,
+		Email:      i.Email,
+		Role:       i.Role,
+		Department: i.Department,
+		CreatedBy:  i.CreatedBy,
+		Created:    i.Created,
+		Expires:    i.Expires,
+	}
+}
+
+func (m *mapper) MapGetAllInvitationsDTO(invitations []account.Invitation) account.GetAllInvitationsDTO {
+	dtos := make([]account.InvitationDTO, len(invitations))
+	for i, inv := range invitations {
+		dtos[i] = m.MapInvitationDTO(inv)
+	}
+	return account.GetAllInvitationsDTO{Invitations: dtos}
+}
+
+func (m *mapper) MapCreateAPIKeyDTO(userID int, dto account.CreateAPIKeyDTO) account.APIKey {
+	k := account.APIKey{
+		UserID: userID,
+		Name:   dto.Name,
+		Scopes: dto.Scopes,
+	}
+	if dto.Expires != nil {
+		k.Expires = *dto.Expires
+	}
+	return k
+}
+
+func (m *mapper) MapCreatedAPIKeyDTO(k account.APIKey, key string) account.CreatedAPIKeyDTO {
+	return account.CreatedAPIKeyDTO{
+		ID:      k.ID,
+		Name:    k.Name,
+		Prefix:  k.Prefix,
+		Scopes:  k.Scopes,
+		Created: k.Created,
+		Expires: k.Expires,
+		Key:     key,
+	}
+}
+
+func (m *mapper) MapGetAllAPIKeysDTO(keys []account.APIKey) account.GetAllAPIKeysDTO {
+	dtos := make([]account.APIKeyDTO, len(keys))
+	for i, k := range keys {
+		dtos[i] = account.APIKeyDTO{
+			ID:       k.ID,
+			Name:     k.Name,
+			Prefix:   k.Prefix,
+			Scopes:   k.Scopes,
+			Created:  k.Created,
+			Expires:  k.Expires,
+			LastUsed: k.LastUsed,
+		}
+	}
+	return account.GetAllAPIKeysDTO{Keys: dtos}
+}
+
+func (m *mapper) MapOrganizationDTO(o account.Organization) account.OrganizationDTO {
+	return account.OrganizationDTO{Slug: o.Slug, Name: o.Name}
+}
@@ -0,0 +1,29 @@
+package account
+
+import (
+	"encoding/json"
+	"io"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/logging"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRegistrationIgnoresDepartment(t *testing.T) {
+	var dto account.RegisterAccountDTO
+	body := `{"name": "Петров", "username": "petrov", "email": "petrov@bmstu.ru", "department": "ИУ7", "password": "x"}`
+	if err := json.Unmarshal([]byte(body), &dto); err != nil {
+		t.Fatal(err)
+	}
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	a, err := New(logging.Logger{Entry: logrus.NewEntry(l)}).MapRegisterAccountDTO(dto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Department != "" {
+		t.Errorf("self-registered account got department %q", a.Department)
+	}
+}
@@ -0,0 +1,29 @@
+package attachment
+
+import (
+	"path/filepath"
+	"reports_system/internal/model/attachment"
+	"reports_system/pkg/logging"
+)
+
+type mapper struct {
+	logger logging.Logger
+}
+
+func New(logger logging.Logger) *mapper {
+	return &mapper{logger: logger}
+}
+
+func (m *mapper) MapUploadAttachment(name string, size int64) attachment.Attachment {
+	return attachment.Attachment{
+		ID:   0,
+		Name: filepath.Base(name),
+		Size: size,
+	}
+}
+
+func (m *mapper) MapGetAllAttachmentsDTO(attachments []attachment.Attachment) attachment.GetAllAttachmentsDTO {
+	return attachment.GetAllAttachmentsDTO{
+		Attachments: attachments,
+	}
+}
@@ -15,15 +15,21 @@ func New(logger logging.Logger) *mapper {
 
 func (m *mapper) MapCreateLabelDTO(dto label.CreateLabelDTO) label.Label {
 	return label.Label{
-		ID:   0,
-		Name: dto.Name,
+		ID:          0,
+		Name:        dto.Name,
+		ParentID:    dto.ParentID,
+		Color:       dto.Color,
+		Description: dto.Description,
+		Icon:        dto.Icon,
 	}
 }
 
-func (m *mapper) MapUpdateLabelDTO(dto label.UpdateLabelDTO) label.Label {
-	return label.Label{
-		ID:   0,
-		Name: dto.Name,
+func (m *mapper) MapUpdateLabelDTO(dto label.UpdateLabelDTO) label.Update {
+	return label.Update{
+		Name:        dto.Name,
+		Color:       dto.Color,
+		Description: dto.Description,
+		Icon:        dto.Icon,
 	}
 }
 
@@ -2,43 +2,78 @@ package mapper
 
 import (
 	authMapper "reports_system/internal/mapper/account"
+	attachmentMapper "reports_system/internal/mapper/attachment"
 	labelMapper "reports_system/internal/mapper/label"
 	reportMapper "reports_system/internal/mapper/report"
+	templateMapper "reports_system/internal/mapper/template"
 	"reports_system/internal/model/account"
+	"reports_system/internal/model/attachment"
 	"reports_system/internal/model/label"
 	"reports_system/internal/model/report"
+	"reports_system/internal/model/template"
 	"reports_system/pkg/logging"
 )
 
 type Account interface {
 	MapRegisterAccountDTO(dto account.RegisterAccountDTO) (account.Account, error)
 	MapLogInAccountDTO(dto account.LoginAccountDTO) account.Account
-	MapAccountWithTokenDTO(token string, a account.Account) account.WithTokenDTO
+	MapAccountWithTokenDTO(tokens account.Tokens, a account.Account) account.WithTokenDTO
+	MapTokensDTO(tokens account.Tokens) account.TokensDTO
 	MapAccountDTO(a account.Account) account.GetAccountDTO
+	MapGetAllSessionsDTO(sessions []account.Session, currentID string) account.GetAllSessionsDTO
+	MapTOTPEnrollmentDTO(e account.Enrollment) account.TOTPEnrollmentDTO
+	MapUpdateAccountDTO(dto account.UpdateAccountDTO) account.ProfileUpdate
+	MapDeleteAccountDTO(dto account.DeleteAccountDTO) account.Deletion
+	MapGetAllPublicAccountsDTO(accounts []account.Account) account.GetAllPublicAccountsDTO
+	MapGetAllAdminAccountsDTO(accounts []account.Account) account.GetAllAdminAccountsDTO
+	MapGetAllAuditEntriesDTO(entries []account.AuditEntry) account.GetAllAuditEntriesDTO
+	MapInviteDTO(organizationID int, dto account.InviteDTO) account.Invitation
+	MapInvitationDTO(i account.Invitation) account.InvitationDTO
+	MapGetAllInvitationsDTO(invitations []account.Invitation) account.GetAllInvitationsDTO
+	MapCreateAPIKeyDTO(userID int, dto account.CreateAPIKeyDTO) account.APIKey
+	MapCreatedAPIKeyDTO(k account.APIKey, key string) account.CreatedAPIKeyDTO
+	MapGetAllAPIKeysDTO(keys []account.APIKey) account.GetAllAPIKeysDTO
+	MapOrganizationDTO(o account.Organization) account.OrganizationDTO
 }
 
 type Report interface {
 	MapCreateReportDTO(dto report.CreateReportDTO) report.Report
+	MapCreateReportFromTemplateDTO(dto report.CreateReportFromTemplateDTO) report.Report
 	MapUpdateReportDTO(dto report.UpdateReportDTO) report.Report
 	MapGetAllReportsDTO(ns []report.Report) report.GetAllReportsDTO
 }
 
 type Label interface {
 	MapCreateLabelDTO(dto label.CreateLabelDTO) label.Label
-	MapUpdateLabelDTO(dto label.UpdateLabelDTO) label.Label
+	MapUpdateLabelDTO(dto label.UpdateLabelDTO) label.Update
 	MapGetAllLabelsDTO(labels []label.Label) label.GetAllLabelsDTO
 }
 
+type Attachment interface {
+	MapUploadAttachment(name string, size int64) attachment.Attachment
+	MapGetAllAttachmentsDTO(attachments []attachment.Attachment) attachment.GetAllAttachmentsDTO
+}
+
+type Template interface {
+	MapCreateTemplateDTO(dto template.CreateTemplateDTO) template.Template
+	MapUpdateTemplateDTO(templateID int, dto template.UpdateTemplateDTO) template.Template
+	MapGetAllTemplatesDTO(templates []template.Template) template.GetAllTemplatesDTO
+}
+
 type Mapper struct {
 	Account
 	Report
 	Label
+	Attachment
+	Template
 }
 
 func New(l logging.Logger) *Mapper {
 	return &Mapper{
-		Account: authMapper.New(l),
-		Report:  reportMapper.New(l),
-		Label:   labelMapper.New(l),
+		Account:    authMapper.New(l),
+		Report:     reportMapper.New(l),
+		Label:      labelMapper.New(l),
+		Attachment: attachmentMapper.New(l),
+		Template:   templateMapper.New(l),
 	}
 }
@@ -20,6 +20,8 @@ func (m *mapper) MapCreateReportDTO(dto report.CreateReportDTO) report.Report {
 		Body:      dto.Body,
 		ShortBody: "",
 		Labels:    nil,
+
+		Classification: dto.Classification,
 	}
 
 	n.GenerateShortBody()
@@ -28,6 +30,20 @@ func (m *mapper) MapCreateReportDTO(dto report.CreateReportDTO) report.Report {
 	return n
 }
 
+func (m *mapper) MapCreateReportFromTemplateDTO(dto report.CreateReportFromTemplateDTO) report.Report {
+	n := report.Report{
+		ID:     0,
+		Header: dto.Header,
+		Body:   dto.Body,
+
+		Classification: dto.Classification,
+	}
+
+	n.GenerateShortBody()
+
+	return n
+}
+
 func (m *mapper) MapGetAllReportsDTO(ns []report.Report) report.GetAllReportsDTO {
 	return report.GetAllReportsDTO{
 		Reports: ns,
@@ -41,6 +57,8 @@ func (m *mapper) MapUpdateReportDTO(dto report.UpdateReportDTO) report.Report {
 		Header:    dto.Header,
 		Body:      dto.Body,
 		ShortBody: "",
+
+		Classification: dto.Classification,
 	}
 
 	n.GenerateShortBody()
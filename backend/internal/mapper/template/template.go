@@ -0,0 +1,40 @@
+package template
+
+import (
+	"reports_system/internal/model/template"
+	"reports_system/pkg/logging"
+)
+
+type mapper struct {
+	logger logging.Logger
+}
+
+func New(logger logging.Logger) *mapper {
+	return &mapper{logger: logger}
+}
+
+func (m *mapper) MapCreateTemplateDTO(dto template.CreateTemplateDTO) template.Template {
+	return template.Template{
+		ID:            0,
+		Name:          dto.Name,
+		HeaderPattern: dto.HeaderPattern,
+		BodySkeleton:  dto.BodySkeleton,
+		DefaultLabels: dto.DefaultLabels,
+	}
+}
+
+func (m *mapper) MapUpdateTemplateDTO(templateID int, dto template.UpdateTemplateDTO) template.Template {
+	return template.Template{
+		ID:            templateID,
+		Name:          dto.Name,
+		HeaderPattern: dto.HeaderPattern,
+		BodySkeleton:  dto.BodySkeleton,
+		DefaultLabels: dto.DefaultLabels,
+	}
+}
+
+func (m *mapper) MapGetAllTemplatesDTO(templates []template.Template) template.GetAllTemplatesDTO {
+	return template.GetAllTemplatesDTO{
+		Templates: templates,
+	}
+}
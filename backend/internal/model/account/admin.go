@@ -0,0 +1,47 @@
+package account
+
+import "time"
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Actions recorded in the admin audit log.
+const (
+	AuditDeactivate    = "deactivate"
+	AuditReactivate    = "reactivate"
+	AuditPasswordReset = "password_reset"
+	AuditSetRole       = "set_role"
+	AuditImpersonate   = "impersonate"
+	AuditInvite        = "invite"
+	AuditRevokeInvite  = "revoke_invite"
+	AuditSetClearance  = "set_clearance"
+)
+
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin
+}
+
+// SessionState is checked on every authenticated request, so role changes and
+// deactivation apply to already issued tokens right away.
+type SessionState struct {
+	UserID         int    `db:"users_id"`
+	Active         bool   `db:"active"`
+	Deactivated    bool   `db:"deactivated"`
+	Role           string `db:"role"`
+	ImpersonatorID *int   `db:"impersonator_id"`
+	OrganizationID int    `db:"organization_id"`
+}
+
+// AuditEntry belongs to the organization of the target account, entries
+// without a target set OrganizationID.
+type AuditEntry struct {
+	ID             int       `db:"id"`
+	AdminID        *int      `db:"admin_id"`
+	Action         string    `db:"action"`
+	TargetID       *int      `db:"target_id"`
+	OrganizationID int       `db:"-"`
+	Details        string    `db:"details"`
+	Created        time.Time `db:"created"`
+}
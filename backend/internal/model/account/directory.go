@@ -0,0 +1,35 @@
+package account
+
+import "strings"
+
+const (
+	DirectoryDefaultLimit = 20
+	DirectoryMaxLimit     = 100
+)
+
+// DirectoryQuery searches accounts by prefix of username or email and by any
+// part of the name, optionally within one department. Only accounts of the
+// organization are searched.
+type DirectoryQuery struct {
+	OrganizationID int
+	Text           string
+	Department     string
+	Limit          int
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// LikePattern escapes s so it is matched literally by LIKE.
+func LikePattern(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func (q *DirectoryQuery) Normalize() {
+	q.Text = strings.TrimSpace(q.Text)
+	if q.Limit <= 0 {
+		q.Limit = DirectoryDefaultLimit
+	}
+	if q.Limit > DirectoryMaxLimit {
+		q.Limit = DirectoryMaxLimit
+	}
+}
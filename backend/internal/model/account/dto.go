@@ -1,10 +1,16 @@
 package account
 
+import "time"
+
+// RegisterAccountDTO has no department, protocols are numbered by it, so it
+// comes only from an invitation, an admin or the directory of the university.
 type RegisterAccountDTO struct {
 	Name     string `json:"name"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Invite is the token from an invitation email.
+	Invite string `json:"invite,omitempty"`
 }
 
 type LoginAccountDTO struct {
@@ -12,15 +18,213 @@ type LoginAccountDTO struct {
 	Password string `json:"password"`
 }
 
+// WithTokenDTO has no tokens when MFARequired is set, MFAToken has to be sent
+// together with the second factor code to finish the login.
 type WithTokenDTO struct {
-	Token    string `json:"token"`
-	Name     string `json:"name"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	MFARequired  bool   `json:"mfaRequired"`
+	MFAToken     string `json:"mfaToken,omitempty"`
+	Name         string `json:"name"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Department   string `json:"department"`
 }
 
 type GetAccountDTO struct {
-	Name     string `json:"name"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	Name          string `json:"name"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	Department    string `json:"department"`
+	EmailVerified bool   `json:"emailVerified"`
+	EmailPublic   bool   `json:"emailPublic"`
+}
+
+type RefreshTokenDTO struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type TokensDTO struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type SessionDTO struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Current   bool      `json:"current"`
+}
+
+type GetAllSessionsDTO struct {
+	Sessions []SessionDTO `json:"sessions"`
+}
+
+type ChangePasswordDTO struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+type RequestPasswordResetDTO struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordDTO struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type SecondFactorDTO struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TOTPEnrollmentDTO struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TOTPCodeDTO struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTOTPDTO struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type RecoveryCodesDTO struct {
+	Codes []string `json:"codes"`
+}
+
+type ResendVerificationDTO struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// UpdateAccountDTO EmailPublic shows the email to other users in the directory.
+type UpdateAccountDTO struct {
+	Name        *string `json:"name"`
+	Username    *string `json:"username"`
+	Email       *string `json:"email"`
+	EmailPublic *bool   `json:"emailPublic"`
+}
+
+// DeleteAccountDTO Reports is either transfer, then TransferTo is the username
+// of the new owner, or delete. Accounts without a password send Token from
+// the deletion email instead of Password.
+type DeleteAccountDTO struct {
+	Password   string `json:"password"`
+	Token      string `json:"token"`
+	Reports    string `json:"reports" binding:"required" enums:"transfer,delete"`
+	TransferTo string `json:"transferTo"`
+}
+
+// PublicAccountDTO is what other users see, Email is set only when the
+// owner made it public.
+type PublicAccountDTO struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Username   string `json:"username"`
+	Department string `json:"department"`
+	Email      string `json:"email,omitempty"`
+}
+
+type GetAllPublicAccountsDTO struct {
+	Accounts []PublicAccountDTO `json:"accounts"`
+}
+
+type AdminAccountDTO struct {
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	Department    string     `json:"department"`
+	Role          string     `json:"role"`
+	Clearance     string     `json:"clearance"`
+	EmailVerified bool       `json:"emailVerified"`
+	TOTPEnabled   bool       `json:"totpEnabled"`
+	Deactivated   *time.Time `json:"deactivated,omitempty"`
+}
+
+type GetAllAdminAccountsDTO struct {
+	Accounts []AdminAccountDTO `json:"accounts"`
+}
+
+type SetRoleDTO struct {
+	Role string `json:"role" binding:"required" enums:"user,admin"`
+}
+
+type SetClearanceDTO struct {
+	Clearance string `json:"clearance" binding:"required" enums:"public,internal,confidential,restricted"`
+}
+
+type AuditEntryDTO struct {
+	ID       int       `json:"id"`
+	AdminID  *int      `json:"adminId"`
+	Action   string    `json:"action"`
+	TargetID *int      `json:"targetId"`
+	Details  string    `json:"details"`
+	Created  time.Time `json:"created"`
+}
+
+type GetAllAuditEntriesDTO struct {
+	Entries []AuditEntryDTO `json:"entries"`
+}
+
+type InviteDTO struct {
+	Email      string     `json:"email" binding:"required"`
+	Role       string     `json:"role" enums:"user,admin"`
+	Department string     `json:"department"`
+	Expires    *time.Time `json:"expires"`
+}
+
+type InvitationDTO struct {
+	ID         int       `json:"id"`
+	Email      string    `json:"email"`
+	Role       string    `json:"role"`
+	Department string    `json:"department"`
+	CreatedBy  *int      `json:"createdBy"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+}
+
+type GetAllInvitationsDTO struct {
+	Invitations []InvitationDTO `json:"invitations"`
+}
+
+type CreateAPIKeyDTO struct {
+	Name    string     `json:"name" binding:"required"`
+	Scopes  []string   `json:"scopes" binding:"required" enums:"read,reports:write,labels:write"`
+	Expires *time.Time `json:"expires"`
+}
+
+type APIKeyDTO struct {
+	ID       int        `json:"id"`
+	Name     string     `json:"name"`
+	Prefix   string     `json:"prefix"`
+	Scopes   []string   `json:"scopes"`
+	Created  time.Time  `json:"created"`
+	Expires  time.Time  `json:"expires"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+}
+
+type CreatedAPIKeyDTO struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Prefix  string    `json:"prefix"`
+	Scopes  []string  `json:"scopes"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	// Key is shown only once.
+	Key string `json:"key"`
+}
+
+type GetAllAPIKeysDTO struct {
+	Keys []APIKeyDTO `json:"keys"`
+}
+
+type OrganizationDTO struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
 }
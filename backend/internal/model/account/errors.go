@@ -1,5 +1,10 @@
 package account
 
+import (
+	"fmt"
+	"time"
+)
+
 type CanNotCreateAccountErr struct{}
 
 func (a *CanNotCreateAccountErr) Error() string {
@@ -29,3 +34,217 @@ type PasswordDoesNotMatchErr struct{}
 func (a *PasswordDoesNotMatchErr) Error() string {
 	return "password does not match"
 }
+
+type InvalidRefreshTokenErr struct{}
+
+func (a *InvalidRefreshTokenErr) Error() string {
+	return "refresh token is invalid or expired"
+}
+
+type SessionRevokedErr struct{}
+
+func (a *SessionRevokedErr) Error() string {
+	return "session has been revoked"
+}
+
+type SessionNotFoundErr struct{}
+
+func (a *SessionNotFoundErr) Error() string {
+	return "session does not exist or does not belong to user"
+}
+
+type InvalidResetTokenErr struct{}
+
+func (a *InvalidResetTokenErr) Error() string {
+	return "password reset token is invalid, expired or already used"
+}
+
+type SecondFactorInvalidErr struct{}
+
+func (a *SecondFactorInvalidErr) Error() string {
+	return "two-factor code is invalid"
+}
+
+type ChallengeExpiredErr struct{}
+
+func (a *ChallengeExpiredErr) Error() string {
+	return "two-factor login has expired, log in again"
+}
+
+type TwoFactorAlreadyEnabledErr struct{}
+
+func (a *TwoFactorAlreadyEnabledErr) Error() string {
+	return "two-factor authentication is already enabled"
+}
+
+type TwoFactorNotEnrolledErr struct{}
+
+func (a *TwoFactorNotEnrolledErr) Error() string {
+	return "two-factor authentication has not been set up"
+}
+
+// TooManyAttemptsErr is returned instead of PasswordDoesNotMatchErr while
+// logins of the username or from the ip are locked.
+type TooManyAttemptsErr struct {
+	RetryAfter time.Duration
+}
+
+func (a *TooManyAttemptsErr) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %v", a.RetryAfter.Round(time.Second))
+}
+
+type EmailNotVerifiedErr struct{}
+
+func (a *EmailNotVerifiedErr) Error() string {
+	return "email address is not verified, follow the link from the verification email"
+}
+
+type InvalidVerificationTokenErr struct{}
+
+func (a *InvalidVerificationTokenErr) Error() string {
+	return "verification token is invalid, expired or already used"
+}
+
+type UsernameTakenErr struct{}
+
+func (a *UsernameTakenErr) Error() string {
+	return "username is already taken"
+}
+
+type TransferTargetNotFoundErr struct{}
+
+func (a *TransferTargetNotFoundErr) Error() string {
+	return "account to transfer reports to does not exist"
+}
+
+type FinalizedReportsErr struct{}
+
+func (a *FinalizedReportsErr) Error() string {
+	return "finalized reports are numbered records and can't be deleted, transfer them to another account"
+}
+
+type UnknownReportsDispositionErr struct{}
+
+func (a *UnknownReportsDispositionErr) Error() string {
+	return "reports of a deleted account must be either transferred or deleted"
+}
+
+type AccountDeactivatedErr struct{}
+
+func (a *AccountDeactivatedErr) Error() string {
+	return "account has been deactivated"
+}
+
+type ForbiddenErr struct{}
+
+func (a *ForbiddenErr) Error() string {
+	return "not enough permissions"
+}
+
+type UnknownRoleErr struct{}
+
+func (a *UnknownRoleErr) Error() string {
+	return "unknown role"
+}
+
+type CanNotImpersonateErr struct{}
+
+func (a *CanNotImpersonateErr) Error() string {
+	return "only active accounts without admin role can be impersonated"
+}
+
+type ImpersonationForbiddenErr struct{}
+
+func (a *ImpersonationForbiddenErr) Error() string {
+	return "credentials of the account can't be changed while impersonating"
+}
+
+type OwnAccountErr struct{}
+
+func (a *OwnAccountErr) Error() string {
+	return "admins can't deactivate, demote or impersonate themselves"
+}
+
+type SSODisabledErr struct{}
+
+func (a *SSODisabledErr) Error() string {
+	return "single sign-on is not configured"
+}
+
+type InvalidSSOStateErr struct{}
+
+func (a *InvalidSSOStateErr) Error() string {
+	return "single sign-on attempt is unknown or expired, please start again"
+}
+
+type SSOFailedErr struct{}
+
+func (a *SSOFailedErr) Error() string {
+	return "identity provider did not confirm the login"
+}
+
+type SSOEmailConflictErr struct{}
+
+func (a *SSOEmailConflictErr) Error() string {
+	return "an account with this email already exists and can't be linked automatically"
+}
+
+type InvalidAPIKeyErr struct{}
+
+func (a *InvalidAPIKeyErr) Error() string {
+	return "api key is invalid, expired or revoked"
+}
+
+type APIKeyNotFoundErr struct{}
+
+func (a *APIKeyNotFoundErr) Error() string {
+	return "api key not found"
+}
+
+type ScopeNotGrantedErr struct{}
+
+func (a *ScopeNotGrantedErr) Error() string {
+	return "api key is not allowed to do this"
+}
+
+type OrganizationNotFoundErr struct{}
+
+func (a *OrganizationNotFoundErr) Error() string {
+	return "organization not found"
+}
+
+type InvalidInvitationErr struct{}
+
+func (a *InvalidInvitationErr) Error() string {
+	return "invitation is invalid, expired, revoked or already used"
+}
+
+type InvitationNotFoundErr struct{}
+
+func (a *InvitationNotFoundErr) Error() string {
+	return "invitation not found"
+}
+
+type InvitationEmailMismatchErr struct{}
+
+func (a *InvitationEmailMismatchErr) Error() string {
+	return "the invitation was sent to another email"
+}
+
+type InvalidDeletionTokenErr struct{}
+
+func (a *InvalidDeletionTokenErr) Error() string {
+	return "deletion token is invalid, expired or already used"
+}
+
+type DeletionByPasswordErr struct{}
+
+func (a *DeletionByPasswordErr) Error() string {
+	return "the account has a password, deletion is confirmed with it"
+}
+
+type AuthSourceConflictErr struct{}
+
+func (a *AuthSourceConflictErr) Error() string {
+	return "username belongs to an account that signs in another way"
+}
@@ -0,0 +1,74 @@
+package account
+
+import (
+	"errors"
+	"reports_system/internal/session"
+	"strings"
+	"time"
+
+	"github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// Invitation lets a person register straight into the organization with the
+// role and department chosen by an admin. Only the hash of the token is
+// stored, the token itself is mailed.
+type Invitation struct {
+	ID             int       `db:"id"`
+	OrganizationID int       `db:"organization_id"`
+	Email          string    `db:"email"`
+	Role           string    `db:"role"`
+	Department     string    `db:"department"`
+	CreatedBy      *int      `db:"created_by"`
+	Created        time.Time `db:"created"`
+	Expires        time.Time `db:"expires"`
+}
+
+// Validate checks the invitation before creation and sets the default role
+// and expiry.
+func (i *Invitation) Validate(now time.Time) error {
+	i.Email = strings.TrimSpace(i.Email)
+	if i.Role == "" {
+		i.Role = RoleUser
+	}
+	if i.Expires.IsZero() {
+		i.Expires = now.Add(session.GetConfig().Registration.InvitationTTL)
+	}
+
+	return validation.ValidateStruct(
+		i,
+		validation.Field(&i.Email, validation.Required, is.Email),
+		validation.Field(&i.Role, validation.By(func(value interface{}) error {
+			role, _ := value.(string)
+			if !ValidRole(role) {
+				return &UnknownRoleErr{}
+			}
+			return nil
+		})),
+		validation.Field(&i.Department, validation.RuneLength(0, 255)),
+		validation.Field(&i.Expires, validation.By(func(value interface{}) error {
+			expires, _ := value.(time.Time)
+			if !expires.After(now) {
+				return errors.New("must be in the future")
+			}
+			return nil
+		})),
+	)
+}
+
+// Apply makes a from the registration form a member chosen by the
+// invitation. The invitation was mailed to the address, so it counts as
+// verified.
+func (i Invitation) Apply(a *Account) error {
+	if !strings.EqualFold(strings.TrimSpace(a.Email), i.Email) {
+		return &InvitationEmailMismatchErr{}
+	}
+	a.Email = i.Email
+	a.EmailVerified = true
+	a.Role = i.Role
+	a.OrganizationID = i.OrganizationID
+	if i.Department != "" {
+		a.Department = i.Department
+	}
+	return nil
+}
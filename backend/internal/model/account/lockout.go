@@ -0,0 +1,32 @@
+package account
+
+import (
+	"strings"
+	"time"
+)
+
+// UsernameSubject and IPSubject are keys failed logins are counted by.
+func UsernameSubject(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func IPSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// LockoutDelay is zero below the threshold and doubles with every failure
+// over it, up to max.
+func LockoutDelay(failures, threshold int, base, max time.Duration) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+
+	delay := base
+	for i := threshold; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -1,41 +1,147 @@
 package account
 
 import (
+	"errors"
 	"fmt"
 	"github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
-	"golang.org/x/crypto/bcrypt"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/password"
+	"strings"
+	"time"
+)
+
+// Where the account signs in, only accounts of the same source are synced
+// from ldap, so a directory entry can't take over a local account.
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+	AuthSourceOIDC  = "oidc"
 )
 
 type Account struct {
-	ID           int    `json:"-" db:"id"`
-	Name         string `json:"name" binding:"required"`
-	Username     string `json:"username" binding:"required"`
-	Email        string `json:"email" binding:"required"`
-	Password     string `json:"-"`
-	PasswordHash string `json:"password" binding:"required" db:"password_hash"`
+	ID            int        `json:"-" db:"id"`
+	Name          string     `json:"name" binding:"required"`
+	Username      string     `json:"username" binding:"required"`
+	Email         string     `json:"email" binding:"required"`
+	Department    string     `json:"department" db:"department"`
+	Password      string     `json:"-"`
+	PasswordHash  string     `json:"password" binding:"required" db:"password_hash"`
+	TOTPEnabled   bool       `json:"-" db:"totp_enabled"`
+	EmailVerified bool       `json:"-" db:"email_verified"`
+	EmailPublic   bool       `json:"-" db:"email_public"`
+	Role          string     `json:"-" db:"role"`
+	Deactivated   *time.Time `json:"-" db:"deactivated"`
+	// OrganizationID is the tenant, it is chosen by the email domain on registration.
+	OrganizationID int `json:"-" db:"organization_id"`
+	// Clearance is the highest classification of reports the account can see.
+	Clearance string `json:"-" db:"clearance"`
+	// AuthSource is one of the AuthSource constants.
+	AuthSource string `json:"-" db:"auth_source"`
 }
 
-func (a *Account) CheckPassword(password string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(a.PasswordHash), []byte(password))
+func (a *Account) CheckPassword(p string) error {
+	err := password.Verify(a.PasswordHash, p)
 	if err != nil {
+		if !errors.Is(err, password.ErrMismatch) {
+			logging.GetLogger().Error(err)
+		}
 		return &PasswordDoesNotMatchErr{}
 	}
 	return nil
 }
 
-func (a *Account) Validate() error {
+// HasPassword is false for accounts signing in through ldap or sso and for
+// the ones whose password was reset by an admin.
+func (a *Account) HasPassword() bool {
+	return a.PasswordHash != password.Unusable
+}
+
+// NeedsRehash is true when the hash was made with outdated settings, the
+// password should be hashed again while it is known after a login.
+func (a *Account) NeedsRehash() bool {
+	return password.NeedsRehash(a.PasswordHash)
+}
+
+func (a *Account) Validate(policy session.Password) error {
+	return validation.ValidateStruct(
+		a,
+		validation.Field(&a.Email, emailRules()...),
+		validation.Field(&a.Password, passwordRules(policy)...),
+	)
+}
+
+// ValidateInvited is Validate without the domain restriction, an admin has
+// already vouched for the email by inviting it.
+func (a *Account) ValidateInvited(policy session.Password) error {
 	return validation.ValidateStruct(
 		a,
 		validation.Field(&a.Email, validation.Required, is.Email),
-		validation.Field(&a.Password, validation.Required, validation.Length(6, 100)),
+		validation.Field(&a.Password, passwordRules(policy)...),
 	)
 }
 
-func GeneratePasswordHash(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+func ValidatePassword(p string, policy session.Password) error {
+	return validation.Errors{"password": validation.Validate(p, passwordRules(policy)...)}.Filter()
+}
+
+func GeneratePasswordHash(p string) (string, error) {
+	hash, err := password.Hash(p)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password due to error %w", err)
 	}
-	return string(hash), nil
+	return hash, nil
+}
+
+func emailRules() []validation.Rule {
+	return []validation.Rule{validation.Required, is.Email, validation.By(allowedDomain)}
+}
+
+// passwordRules also keep bcrypt passwords within the bytes it hashes, the
+// rest would be ignored and any suffix would match.
+func passwordRules(policy session.Password) []validation.Rule {
+	rules := []validation.Rule{
+		validation.Required,
+		validation.RuneLength(policy.MinLength, policy.MaxLength),
+	}
+	if password.Truncates(policy.Algorithm) {
+		rules = append(rules, validation.By(fitsBcrypt))
+	}
+	return append(rules, validation.By(notBreached(policy.BreachedList)))
+}
+
+func allowedDomain(value interface{}) error {
+	email, _ := value.(string)
+	domains := session.GetConfig().Registration.AllowedDomains
+	if len(domains) == 0 {
+		return nil
+	}
+
+	at := strings.LastIndex(email, "@")
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range domains {
+		if domain == strings.ToLower(strings.TrimPrefix(d, "@")) {
+			return nil
+		}
+	}
+	return fmt.Errorf("registration is allowed only with emails in %s", strings.Join(domains, ", "))
+}
+
+func fitsBcrypt(value interface{}) error {
+	p, _ := value.(string)
+	if len(p) > password.BcryptMaxBytes {
+		return fmt.Errorf("must be no longer than %d bytes", password.BcryptMaxBytes)
+	}
+	return nil
+}
+
+func notBreached(list string) validation.RuleFunc {
+	return func(value interface{}) error {
+		p, _ := value.(string)
+		if password.IsBreached(p, list) {
+			return errors.New("password is too common, it appears in leaked password lists")
+		}
+		return nil
+	}
 }
@@ -0,0 +1,64 @@
+package account
+
+import (
+	"reports_system/internal/session"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidatePasswordKeepsBcryptPasswordsWhole(t *testing.T) {
+	// 40 cyrillic letters are within max_length but take 80 bytes
+	long := strings.Repeat("пароль", 7)[:80]
+	tests := []struct {
+		name      string
+		algorithm string
+		password  string
+		valid     bool
+	}{
+		{"bcrypt, fits", "bcrypt", strings.Repeat("a", 72), true},
+		{"bcrypt, too many bytes", "bcrypt", long, false},
+		{"default algorithm, too many bytes", "", long, false},
+		{"argon2id hashes it whole", "argon2id", long, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := session.Password{Algorithm: tt.algorithm, MinLength: 8, MaxLength: 128}
+			err := ValidatePassword(tt.password, policy)
+			if (err == nil) != tt.valid {
+				t.Fatalf("err = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidatePasswordFollowsPolicy(t *testing.T) {
+	policy := session.Password{Algorithm: "argon2id", MinLength: 12, MaxLength: 16}
+	for p, valid := range map[string]bool{
+		"short-pass":        false,
+		"long-enough-pass":  true,
+		"far-too-long-pass": false,
+	} {
+		if err := ValidatePassword(p, policy); (err == nil) != valid {
+			t.Errorf("%q: err = %v, want valid %v", p, err, valid)
+		}
+	}
+}
+
+func TestAPIKeyValidateUsesGivenLimits(t *testing.T) {
+	now := time.Now()
+	cfg := session.APIKeys{DefaultTTL: time.Hour, MaxTTL: 24 * time.Hour}
+
+	k := APIKey{Name: "ci", Scopes: []string{ScopeRead}}
+	if err := k.Validate(now, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !k.Expires.Equal(now.Add(cfg.DefaultTTL)) {
+		t.Errorf("expires = %v, want the default ttl", k.Expires)
+	}
+
+	k = APIKey{Name: "ci", Scopes: []string{ScopeRead}, Expires: now.Add(48 * time.Hour)}
+	if err := k.Validate(now, cfg); err == nil {
+		t.Error("a key beyond max ttl is valid")
+	}
+}
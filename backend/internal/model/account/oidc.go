@@ -0,0 +1,72 @@
+package account
+
+import (
+	"reports_system/internal/session"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OIDCLogin is a single sign-on attempt between the redirect to the identity
+// provider and the callback. Only the hash of the state is stored.
+type OIDCLogin struct {
+	StateHash string    `db:"state_hash"`
+	Nonce     string    `db:"nonce"`
+	Verifier  string    `db:"code_verifier"`
+	Expires   time.Time `db:"expires"`
+}
+
+// Identity links an account to a subject of an identity provider.
+type Identity struct {
+	Issuer  string
+	Subject string
+}
+
+// MapGroups returns the department and role of the first matching entry of
+// mapping for each, empty when nothing matches.
+func MapGroups(groups []string, mapping []session.OIDCGroup) (department, role string) {
+	member := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		member[strings.ToLower(g)] = true
+	}
+
+	for _, m := range mapping {
+		if !member[strings.ToLower(m.Group)] {
+			continue
+		}
+		if department == "" {
+			department = m.Department
+		}
+		if role == "" {
+			role = m.Role
+		}
+	}
+	return department, role
+}
+
+// RolesFromGroups is true when the mapping assigns roles, then the identity
+// provider decides the role and people removed from a group lose it.
+func RolesFromGroups(mapping []session.OIDCGroup) bool {
+	for _, m := range mapping {
+		if m.Role != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ProvisionedUsername picks the username for an account created on the first
+// single sign-on, attempt counts collisions with existing usernames.
+func ProvisionedUsername(preferred, email string, attempt int) string {
+	username := preferred
+	if username == "" {
+		username = strings.SplitN(email, "@", 2)[0]
+	}
+	if username == "" {
+		username = "user"
+	}
+	if attempt > 0 {
+		username = username + "-" + strconv.Itoa(attempt+1)
+	}
+	return username
+}
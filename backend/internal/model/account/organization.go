@@ -0,0 +1,24 @@
+package account
+
+import "strings"
+
+// DefaultOrganization gets the accounts whose email domain isn't claimed by
+// any other organization.
+const DefaultOrganization = "default"
+
+// Organization is a tenant. Accounts, their reports, labels and departments
+// are never visible to accounts of other organizations.
+type Organization struct {
+	ID      int      `json:"-" db:"id"`
+	Slug    string   `json:"slug" db:"slug"`
+	Name    string   `json:"name" db:"name"`
+	Domains []string `json:"-" db:"-"`
+}
+
+// NormalizeDomains lowercases the domains and drops a leading @, so they
+// compare equal to the domain part of an email.
+func (o *Organization) NormalizeDomains() {
+	for i, d := range o.Domains {
+		o.Domains[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+	}
+}
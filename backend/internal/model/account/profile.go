@@ -0,0 +1,59 @@
+package account
+
+import (
+	"github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// What happens to reports of a deleted account.
+const (
+	ReportsTransfer = "transfer"
+	ReportsDelete   = "delete"
+)
+
+// Deletion is a request to delete the account. Accounts with a password
+// confirm it with Password, accounts signing in through ldap or sso have
+// none and confirm with Token mailed to them instead.
+type Deletion struct {
+	Password   string
+	Token      string
+	Reports    string
+	TransferTo string
+}
+
+// ProfileUpdate holds fields changed by the user, nil ones are kept as is.
+type ProfileUpdate struct {
+	Name        *string
+	Username    *string
+	Email       *string
+	EmailPublic *bool
+}
+
+// Apply changes the account and tells whether the email has changed, in that
+// case it has to be verified again.
+func (a *Account) Apply(u ProfileUpdate) bool {
+	if u.Name != nil {
+		a.Name = *u.Name
+	}
+	if u.Username != nil {
+		a.Username = *u.Username
+	}
+	if u.EmailPublic != nil {
+		a.EmailPublic = *u.EmailPublic
+	}
+	if u.Email == nil || *u.Email == a.Email {
+		return false
+	}
+
+	a.Email = *u.Email
+	a.EmailVerified = false
+	return true
+}
+
+func (a *Account) ValidateProfile() error {
+	return validation.ValidateStruct(
+		a,
+		validation.Field(&a.Name, validation.Required, validation.RuneLength(1, 255)),
+		validation.Field(&a.Username, validation.Required, validation.RuneLength(1, 255)),
+		validation.Field(&a.Email, emailRules()...),
+	)
+}
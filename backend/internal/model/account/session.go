@@ -0,0 +1,45 @@
+package account
+
+import "time"
+
+const (
+	maxUserAgentLen = 512
+)
+
+// Session is a single login of an account. Access tokens carry its id, so
+// revoking the session makes every token issued for it invalid at once.
+type Session struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    int        `json:"-" db:"users_id"`
+	UserAgent string     `json:"userAgent" db:"user_agent"`
+	IP        string     `json:"ip" db:"ip"`
+	Created   time.Time  `json:"created" db:"created"`
+	LastSeen  time.Time  `json:"lastSeen" db:"last_seen"`
+	Expires   time.Time  `json:"expires" db:"expires"`
+	Revoked   *time.Time `json:"-" db:"revoked"`
+	// ImpersonatorID is the admin who started the session on behalf of the user.
+	ImpersonatorID *int `json:"-" db:"impersonator_id"`
+	// OrganizationID of the user goes into the access tokens of the session.
+	OrganizationID int `json:"-" db:"organization_id"`
+}
+
+// Client describes where a login came from.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+func NewClient(userAgent, ip string) Client {
+	if r := []rune(userAgent); len(r) > maxUserAgentLen {
+		userAgent = string(r[:maxUserAgentLen])
+	}
+	return Client{UserAgent: userAgent, IP: ip}
+}
+
+// Tokens of a finished login. Only Challenge is set when the account still
+// has to pass the second factor.
+type Tokens struct {
+	Access    string
+	Refresh   string
+	Challenge string
+}
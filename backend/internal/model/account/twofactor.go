@@ -0,0 +1,62 @@
+package account
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+)
+
+const (
+	RecoveryCodesCount = 10
+	recoveryCodeSize   = 5
+	// MaxChallengeAttempts limits guessing of the second factor within one login.
+	MaxChallengeAttempts = 5
+)
+
+// TOTP is the second factor of an account. Secret is set on enrollment and
+// Enabled once the user confirmed it with a valid code.
+type TOTP struct {
+	Secret      *string `db:"totp_secret"`
+	Enabled     bool    `db:"totp_enabled"`
+	LastCounter *int64  `db:"totp_last_counter"`
+}
+
+// Challenge is a login that passed the password check and waits for the second factor.
+type Challenge struct {
+	UserID  int
+	Client  Client
+	Expires time.Time
+}
+
+// Enrollment is what the user needs to add the account to an authenticator app.
+type Enrollment struct {
+	Secret string
+	URI    string
+}
+
+// GenerateRecoveryCodes returns codes looking like abcd-efgh.
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode makes codes typed with spaces, without a dash or in
+// upper case match the stored ones.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
@@ -0,0 +1,5 @@
+package attachment
+
+type GetAllAttachmentsDTO struct {
+	Attachments []Attachment `json:"attachments"`
+}
@@ -0,0 +1,25 @@
+package attachment
+
+type CanNotCreateAttachmentErr struct{}
+
+func (a *CanNotCreateAttachmentErr) Error() string {
+	return "can't create attachment"
+}
+
+type AttachmentNotFoundErr struct{}
+
+func (a *AttachmentNotFoundErr) Error() string {
+	return "attachment does not exist or does not belong to user"
+}
+
+type AttachmentTooLargeErr struct{}
+
+func (a *AttachmentTooLargeErr) Error() string {
+	return "attachment is too large"
+}
+
+type ContentTypeNotAllowedErr struct{}
+
+func (a *ContentTypeNotAllowedErr) Error() string {
+	return "attachment content type is not allowed"
+}
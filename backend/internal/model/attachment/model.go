@@ -0,0 +1,82 @@
+package attachment
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	SniffLen = 512
+
+	zipContentType = "application/zip"
+)
+
+// Text statuses track the background extraction of attachment contents.
+const (
+	TextPending     = "pending"
+	TextProcessing  = "processing"
+	TextIndexed     = "indexed"
+	TextFailed      = "failed"
+	TextUnsupported = "unsupported"
+)
+
+// zipBasedContentTypes maps office formats, which are sniffed as plain zip.
+var zipBasedContentTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+}
+
+// allowedContentTypes are the sniffed types we accept; everything else
+// (html, scripts, executables) is rejected so downloads can't be abused.
+var allowedContentTypes = []string{
+	"application/pdf",
+	"application/zip",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.",
+	"application/vnd.oasis.opendocument.",
+	"application/octet-stream",
+	"text/plain",
+	"text/csv",
+	"image/",
+}
+
+type Attachment struct {
+	ID          int       `json:"id" db:"id"`
+	ReportID    int       `json:"reportId" db:"reports_id"`
+	Name        string    `json:"name" db:"name"`
+	ContentType string    `json:"contentType" db:"content_type"`
+	Size        int64     `json:"size" db:"size"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	TextStatus  string    `json:"textStatus" db:"text_status"`
+	Created     time.Time `json:"created" db:"created"`
+}
+
+// SniffContentType detects the content type from the first bytes of the
+// file instead of trusting the client. Office documents are zip archives,
+// so for them the file extension narrows the type down.
+func (a *Attachment) SniffContentType(head []byte) {
+	contentType := http.DetectContentType(head)
+	if strings.HasPrefix(contentType, zipContentType) {
+		if byExt, ok := zipBasedContentTypes[strings.ToLower(filepath.Ext(a.Name))]; ok {
+			contentType = byExt
+		}
+	}
+	a.ContentType = contentType
+}
+
+func (a *Attachment) HasAllowedContentType() bool {
+	for _, allowed := range allowedContentTypes {
+		if strings.HasPrefix(a.ContentType, allowed) {
+			return true
+		}
+	}
+	return false
+}
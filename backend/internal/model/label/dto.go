@@ -1,11 +1,25 @@
 package label
 
 type CreateLabelDTO struct {
-	Name string `json:"name" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	ParentID    *int   `json:"parentId,omitempty"`
+	Color       string `json:"color,omitempty" example:"#1e90ff"`
+	Description string `json:"description,omitempty"`
+	Icon        string `json:"icon,omitempty" example:"folder"`
 }
 
+// UpdateLabelDTO fields left out keep their values, an empty color,
+// description or icon removes it.
 type UpdateLabelDTO struct {
-	Name string `json:"name" db:"name"`
+	Name        *string `json:"name"`
+	Color       *string `json:"color" example:"#1e90ff"`
+	Description *string `json:"description"`
+	Icon        *string `json:"icon" example:"folder"`
+}
+
+// MoveLabelDTO puts the label under another one, or to the top without ParentID.
+type MoveLabelDTO struct {
+	ParentID *int `json:"parentId"`
 }
 
 type GetAllLabelsDTO struct {
@@ -11,3 +11,21 @@ type LabelNotFoundErr struct{}
 func (a *LabelNotFoundErr) Error() string {
 	return "label does not exist or does not belong to user"
 }
+
+type LabelCycleErr struct{}
+
+func (a *LabelCycleErr) Error() string {
+	return "label can't be moved under itself or its descendant"
+}
+
+type LabelExistsErr struct{}
+
+func (a *LabelExistsErr) Error() string {
+	return "label with this name already exists with other settings, update it instead"
+}
+
+type LabelNameTakenErr struct{}
+
+func (a *LabelNameTakenErr) Error() string {
+	return "label with this name already exists under the same parent"
+}
@@ -1,6 +1,79 @@
 package label
 
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-ozzo/ozzo-validation/v4"
+)
+
+var (
+	colorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+	// icons are keys of the frontend icon set, e.g. "folder" or "calendar-check"
+	iconPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// Label may be nested into a parent label, filtering by a label also finds
+// reports tagged with any label below it.
 type Label struct {
-	ID   int    `json:"id" db:"id"`
-	Name string `json:"name" db:"name" binding:"required"`
+	ID          int    `json:"id" db:"id"`
+	Name        string `json:"name" db:"name" binding:"required"`
+	ParentID    *int   `json:"parentId,omitempty" db:"parent_id"`
+	Color       string `json:"color,omitempty" db:"color"`
+	Description string `json:"description,omitempty" db:"description"`
+	Icon        string `json:"icon,omitempty" db:"icon"`
+}
+
+// Update holds fields changed by the user, nil ones are kept as is.
+type Update struct {
+	Name        *string
+	Color       *string
+	Description *string
+	Icon        *string
+}
+
+func (t *Label) Apply(u Update) {
+	if u.Name != nil {
+		t.Name = *u.Name
+	}
+	if u.Color != nil {
+		t.Color = *u.Color
+	}
+	if u.Description != nil {
+		t.Description = *u.Description
+	}
+	if u.Icon != nil {
+		t.Icon = *u.Icon
+	}
+}
+
+// SameAs tells whether t, as given on creation, describes the existing label
+// e: its parent and every setting given in t have to match.
+func (t Label) SameAs(e Label) bool {
+	return t.SameParent(e) &&
+		(t.Color == "" || t.Color == e.Color) &&
+		(t.Description == "" || t.Description == e.Description) &&
+		(t.Icon == "" || t.Icon == e.Icon)
+}
+
+// SameParent tells whether both labels are at the top level or under one
+// parent. Label names are unique only among such siblings.
+func (t Label) SameParent(e Label) bool {
+	return t.ParentID == nil && e.ParentID == nil ||
+		t.ParentID != nil && e.ParentID != nil && *t.ParentID == *e.ParentID
+}
+
+// Validate checks the label and brings the color to lower case, the way it is
+// stored.
+func (t *Label) Validate() error {
+	t.Color = strings.ToLower(strings.TrimSpace(t.Color))
+	t.Icon = strings.TrimSpace(t.Icon)
+
+	return validation.ValidateStruct(
+		t,
+		validation.Field(&t.Name, validation.Required, validation.RuneLength(1, 255)),
+		validation.Field(&t.Color, validation.Match(colorPattern).Error("must be a hex color like #1e90ff")),
+		validation.Field(&t.Description, validation.RuneLength(0, 1000)),
+		validation.Field(&t.Icon, validation.Length(0, 64), validation.Match(iconPattern)),
+	)
 }
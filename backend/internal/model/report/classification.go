@@ -0,0 +1,45 @@
+package report
+
+// Classification levels from the lowest, a report is visible only to
+// accounts cleared for its level or above.
+const (
+	ClassificationPublic       = "public"
+	ClassificationInternal     = "internal"
+	ClassificationConfidential = "confidential"
+	ClassificationRestricted   = "restricted"
+)
+
+var Classifications = []string{
+	ClassificationPublic,
+	ClassificationInternal,
+	ClassificationConfidential,
+	ClassificationRestricted,
+}
+
+// ClassificationLevel is the position of the level, -1 for unknown ones.
+func ClassificationLevel(c string) int {
+	for i, known := range Classifications {
+		if c == known {
+			return i
+		}
+	}
+	return -1
+}
+
+// Classify sets the default classification and checks that the author is
+// cleared for it, nobody can file a report they couldn't read afterwards.
+func (n *Report) Classify(clearance string) error {
+	if n.Classification == "" {
+		n.Classification = ClassificationInternal
+		if ClassificationLevel(clearance) < ClassificationLevel(n.Classification) {
+			n.Classification = clearance
+		}
+	}
+	if ClassificationLevel(n.Classification) < 0 {
+		return &UnknownClassificationErr{}
+	}
+	if ClassificationLevel(n.Classification) > ClassificationLevel(clearance) {
+		return &AboveClearanceErr{}
+	}
+	return nil
+}
@@ -1,16 +1,30 @@
 package report
 
 type CreateReportDTO struct {
-	Header string `json:"header" binding:"required"`
-	Body   string `json:"body"`
+	Header         string `json:"header" binding:"required"`
+	Body           string `json:"body"`
+	Classification string `json:"classification" enums:"public,internal,confidential,restricted"`
+}
+
+type CreateReportFromTemplateDTO struct {
+	Header         string `json:"header"`
+	Body           string `json:"body"`
+	Classification string `json:"classification" enums:"public,internal,confidential,restricted"`
 }
 
 type UpdateReportDTO struct {
-	ID     int
-	Header string `json:"header"`
-	Body   string `json:"body"`
+	ID             int
+	Header         string `json:"header"`
+	Body           string `json:"body"`
+	Classification string `json:"classification" enums:"public,internal,confidential,restricted"`
 }
 
 type GetAllReportsDTO struct {
 	Reports []Report `json:"reports"`
 }
+
+type FinalizedReportDTO struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Number string `json:"number"`
+}
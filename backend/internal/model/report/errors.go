@@ -1,5 +1,7 @@
 package report
 
+import "strings"
+
 type CanNotCreateReportErr struct{}
 
 func (a *CanNotCreateReportErr) Error() string {
@@ -17,3 +19,33 @@ type ReportNotFoundErr struct{}
 func (a *ReportNotFoundErr) Error() string {
 	return "report does not exist or does not belong to user"
 }
+
+type UnsupportedExportFormatErr struct{}
+
+func (a *UnsupportedExportFormatErr) Error() string {
+	return "unsupported export format, expected csv or xlsx"
+}
+
+type AlreadyFinalizedErr struct{}
+
+func (a *AlreadyFinalizedErr) Error() string {
+	return "report is already finalized"
+}
+
+type DepartmentRequiredErr struct{}
+
+func (a *DepartmentRequiredErr) Error() string {
+	return "account has no department, protocol number can't be allocated"
+}
+
+type UnknownClassificationErr struct{}
+
+func (a *UnknownClassificationErr) Error() string {
+	return "unknown classification, known are " + strings.Join(Classifications, ", ")
+}
+
+type AboveClearanceErr struct{}
+
+func (a *AboveClearanceErr) Error() string {
+	return "classification is above the clearance of the account"
+}
@@ -2,12 +2,17 @@ package report
 
 import (
 	"reports_system/internal/model/label"
-	"strings"
 	"time"
 )
 
 const (
 	shortBodyLen = 255
+
+	MatchInReport     = "report"
+	MatchInAttachment = "attachment"
+
+	StatusDraft     = "draft"
+	StatusFinalized = "finalized"
 )
 
 type Report struct {
@@ -17,6 +22,25 @@ type Report struct {
 	ShortBody string        `json:"shortBody" db:"short_body"`
 	Labels    []label.Label `json:"labels" db:"labels"` // []label.Label
 	Edited    time.Time     `json:"edited"`
+	Status    string        `json:"status" db:"status"`
+	Number    string        `json:"number,omitempty" db:"number"`
+	Matches   []SearchMatch `json:"matches,omitempty" db:"-"`
+	// Classification is one of Classifications.
+	Classification string `json:"classification" db:"classification"`
+}
+
+// SearchMatch tells where a full-text search hit was found: in the report
+// itself or in one of its attachments.
+type SearchMatch struct {
+	Source         string `json:"source"`
+	AttachmentID   int    `json:"attachmentId,omitempty"`
+	AttachmentName string `json:"attachmentName,omitempty"`
+}
+
+// RegistryRow is a single line of the reports registry export.
+type RegistryRow struct {
+	Report
+	Department string `json:"department" db:"department"`
 }
 
 func (n *Report) GenerateShortBody() {
@@ -27,19 +51,23 @@ func (n *Report) GenerateShortBody() {
 	}
 }
 
-func (n *Report) HasEveryLabel(labelNames []string) bool {
-	for _, tn := range labelNames {
-		if !n.HasSpecificLabel(tn) {
+// HasLabelFromEach tells whether the report has one of the labels of every
+// group of label ids.
+func (n *Report) HasLabelFromEach(groups [][]int) bool {
+	for _, ids := range groups {
+		if !n.hasAnyLabel(ids) {
 			return false
 		}
 	}
 	return true
 }
 
-func (n *Report) HasSpecificLabel(labelName string) bool {
+func (n *Report) hasAnyLabel(ids []int) bool {
 	for _, t := range n.Labels {
-		if strings.Contains(strings.ToLower(t.Name), strings.ToLower(labelName)) {
-			return true
+		for _, id := range ids {
+			if t.ID == id {
+				return true
+			}
 		}
 	}
 	return false
@@ -0,0 +1,19 @@
+package template
+
+type CreateTemplateDTO struct {
+	Name          string   `json:"name" binding:"required"`
+	HeaderPattern string   `json:"headerPattern" binding:"required"`
+	BodySkeleton  string   `json:"bodySkeleton"`
+	DefaultLabels []string `json:"defaultLabels"`
+}
+
+type UpdateTemplateDTO struct {
+	Name          string   `json:"name"`
+	HeaderPattern string   `json:"headerPattern"`
+	BodySkeleton  string   `json:"bodySkeleton"`
+	DefaultLabels []string `json:"defaultLabels"`
+}
+
+type GetAllTemplatesDTO struct {
+	Templates []Template `json:"templates"`
+}
@@ -0,0 +1,13 @@
+package template
+
+type CanNotCreateTemplateErr struct{}
+
+func (a *CanNotCreateTemplateErr) Error() string {
+	return "can't create template"
+}
+
+type TemplateNotFoundErr struct{}
+
+func (a *TemplateNotFoundErr) Error() string {
+	return "template does not exist or does not belong to user"
+}
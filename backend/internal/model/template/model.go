@@ -0,0 +1,59 @@
+package template
+
+import (
+	"github.com/go-ozzo/ozzo-validation/v4"
+	"reports_system/internal/model/report"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DatePlaceholder       = "{{date}}"
+	YearPlaceholder       = "{{year}}"
+	DepartmentPlaceholder = "{{department}}"
+	AuthorPlaceholder     = "{{author}}"
+
+	dateLayout = "02.01.2006"
+)
+
+type Template struct {
+	ID            int      `json:"id" db:"id"`
+	Name          string   `json:"name" db:"name"`
+	HeaderPattern string   `json:"headerPattern" db:"header_pattern"`
+	BodySkeleton  string   `json:"bodySkeleton" db:"body_skeleton"`
+	DefaultLabels []string `json:"defaultLabels" db:"default_labels"`
+}
+
+// Values are substituted for placeholders when a report is created from a template.
+type Values struct {
+	Date       time.Time
+	Department string
+	Author     string
+}
+
+func (t *Template) Validate() error {
+	return validation.ValidateStruct(
+		t,
+		validation.Field(&t.Name, validation.Required, validation.Length(1, 255)),
+		validation.Field(&t.HeaderPattern, validation.Required, validation.Length(1, 255)),
+	)
+}
+
+// Instantiate fills the placeholders and returns a new report draft.
+func (t *Template) Instantiate(v Values) report.Report {
+	replacer := strings.NewReplacer(
+		DatePlaceholder, v.Date.Format(dateLayout),
+		YearPlaceholder, strconv.Itoa(v.Date.Year()),
+		DepartmentPlaceholder, v.Department,
+		AuthorPlaceholder, v.Author,
+	)
+
+	n := report.Report{
+		Header: replacer.Replace(t.HeaderPattern),
+		Body:   replacer.Replace(t.BodySkeleton),
+	}
+	n.GenerateShortBody()
+
+	return n
+}
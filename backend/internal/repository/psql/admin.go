@@ -0,0 +1,179 @@
+package psql
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/password"
+)
+
+const adminAuditTable = "admin_audit"
+
+type AdminPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewAdminPostgres(client *psqlclient.Client, logger logging.Logger) *AdminPostgres {
+	return &AdminPostgres{db: client.DB, logger: logger}
+}
+
+// GetAll is like the directory search, but includes deactivated accounts and
+// orders them by username.
+func (r *AdminPostgres) GetAll(q account.DirectoryQuery) ([]account.Account, error) {
+	query := fmt.Sprintf(
+		`SELECT id, name, username, email, department, email_verified, email_public, totp_enabled, role, deactivated,
+					organization_id, clearance
+				FROM %s
+				WHERE organization_id = $5
+					AND ($1 = '' OR name ILIKE '%%' || $2 || '%%' OR username ILIKE $2 || '%%' OR email ILIKE $2 || '%%')
+					AND ($3 = '' OR department = $3)
+				ORDER BY username
+				LIMIT $4`,
+		usersTable,
+	)
+
+	accounts := make([]account.Account, 0)
+	err := r.db.Select(&accounts, query, q.Text, account.LikePattern(q.Text), q.Department, q.Limit, q.OrganizationID)
+	if err != nil {
+		r.logger.Info(err)
+		return nil, &account.CanNotGetErr{}
+	}
+	return accounts, nil
+}
+
+// SetDeactivated also revokes all sessions of a deactivated account.
+func (r *AdminPostgres) SetDeactivated(userID int, deactivated bool) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`UPDATE %s SET deactivated = NULL WHERE id = $1`, usersTable)
+	if deactivated {
+		query = fmt.Sprintf(`UPDATE %s SET deactivated = COALESCE(deactivated, now()) WHERE id = $1`, usersTable)
+	}
+	if err = r.exec(tx, query, userID); err != nil {
+		return err
+	}
+
+	if deactivated {
+		if err = r.revokeSessions(tx, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *AdminPostgres) SetRole(userID int, role string) error {
+	query := fmt.Sprintf(`UPDATE %s SET role = $2 WHERE id = $1`, usersTable)
+
+	res, err := r.db.Exec(query, userID, role)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &account.AccountNotFoundErr{}
+	}
+	return nil
+}
+
+func (r *AdminPostgres) SetClearance(userID int, clearance string) error {
+	query := fmt.Sprintf(`UPDATE %s SET clearance = $2 WHERE id = $1`, usersTable)
+
+	res, err := r.db.Exec(query, userID, clearance)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &account.AccountNotFoundErr{}
+	}
+	return nil
+}
+
+// ForcePasswordReset makes the current password stop working, signs the user
+// out everywhere and lifts the login lockout of the username.
+func (r *AdminPostgres) ForcePasswordReset(userID int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`UPDATE %s SET password_hash = $2 WHERE id = $1`, usersTable)
+	if err = r.exec(tx, query, userID, password.Unusable); err != nil {
+		return err
+	}
+
+	unlockQuery := fmt.Sprintf(
+		`DELETE FROM %s WHERE subject = (SELECT 'user:' || lower(username) FROM %s WHERE id = $1)`,
+		loginAttemptsTable, usersTable)
+	if _, err = tx.Exec(unlockQuery, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	if err = r.revokeSessions(tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Audit files the entry under the organization of the target account.
+func (r *AdminPostgres) Audit(entry account.AuditEntry) error {
+	query := fmt.Sprintf(
+		`INSERT INTO %s (admin_id, action, target_id, details, organization_id)
+				VALUES ($1, $2, $3, $4, COALESCE((SELECT organization_id FROM %s WHERE id = $3), NULLIF($5, 0)))`,
+		adminAuditTable, usersTable)
+
+	_, err := r.db.Exec(query, entry.AdminID, entry.Action, entry.TargetID, entry.Details, entry.OrganizationID)
+	if err != nil {
+		r.logger.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (r *AdminPostgres) GetAudit(organizationID, limit int) ([]account.AuditEntry, error) {
+	entries := make([]account.AuditEntry, 0)
+
+	query := fmt.Sprintf(
+		`SELECT id, admin_id, action, target_id, details, created FROM %s
+				WHERE organization_id = $1 ORDER BY created DESC LIMIT $2`,
+		adminAuditTable)
+	if err := r.db.Select(&entries, query, organizationID, limit); err != nil {
+		r.logger.Info(err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *AdminPostgres) exec(tx *sqlx.Tx, query string, args ...interface{}) error {
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &account.AccountNotFoundErr{}
+	}
+	return nil
+}
+
+func (r *AdminPostgres) revokeSessions(tx *sqlx.Tx, userID int) error {
+	query := fmt.Sprintf(
+		`UPDATE %s SET revoked = now() WHERE users_id = $1 AND revoked IS NULL`,
+		sessionsTable)
+	if _, err := tx.Exec(query, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+)
+
+const (
+	apiKeysTable = "api_keys"
+)
+
+type APIKeyPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewAPIKeyPostgres(client *psqlclient.Client, logger logging.Logger) *APIKeyPostgres {
+	return &APIKeyPostgres{db: client.DB, logger: logger}
+}
+
+func (r *APIKeyPostgres) Create(k *account.APIKey, keyHash string) error {
+	query := fmt.Sprintf(
+		`INSERT INTO %s (users_id, name, prefix, key_hash, scopes, expires) VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING id, created`,
+		apiKeysTable)
+
+	row := r.db.QueryRow(query, k.UserID, k.Name, k.Prefix, keyHash, pq.Array(k.Scopes), k.Expires)
+	if err := row.Scan(&k.ID, &k.Created); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
+
+// GetAll returns keys that still work, newest first.
+func (r *APIKeyPostgres) GetAll(userID int) ([]account.APIKey, error) {
+	query := fmt.Sprintf(
+		`SELECT id, users_id, name, prefix, scopes, created, expires, last_used FROM %s
+				WHERE users_id = $1 AND revoked IS NULL AND expires > now()
+				ORDER BY created DESC`,
+		apiKeysTable)
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		r.logger.Info(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]account.APIKey, 0)
+	for rows.Next() {
+		var k account.APIKey
+		err = rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), &k.Created, &k.Expires, &k.LastUsed)
+		if err != nil {
+			r.logger.Info(err)
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *APIKeyPostgres) Revoke(userID, keyID int) error {
+	query := fmt.Sprintf(
+		`UPDATE %s SET revoked = now() WHERE id = $1 AND users_id = $2 AND revoked IS NULL`,
+		apiKeysTable)
+
+	res, err := r.db.Exec(query, keyID, userID)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &account.APIKeyNotFoundErr{}
+	}
+	return nil
+}
+
+// State finds a working key by its hash. Like sessions, last_used is
+// written at most once a minute to keep requests cheap.
+func (r *APIKeyPostgres) State(keyHash string) (account.APIKeyState, error) {
+	var state account.APIKeyState
+
+	query := fmt.Sprintf(
+		`WITH touched AS (
+					UPDATE %[1]s SET last_used = now()
+					WHERE key_hash = $1 AND revoked IS NULL AND expires > now()
+						AND (last_used IS NULL OR last_used < now() - interval '1 minute')
+				)
+				SELECT k.id, k.users_id, u.organization_id, k.scopes, u.role, u.deactivated IS NOT NULL
+				FROM %[1]s k JOIN %[2]s u ON u.id = k.users_id
+				WHERE k.key_hash = $1 AND k.revoked IS NULL AND k.expires > now()`,
+		apiKeysTable, usersTable)
+
+	row := r.db.QueryRow(query, keyHash)
+	err := row.Scan(&state.KeyID, &state.UserID, &state.OrganizationID, pq.Array(&state.Scopes), &state.Role, &state.Deactivated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return state, &account.InvalidAPIKeyErr{}
+		}
+		r.logger.Info(err)
+		return state, err
+	}
+	return state, nil
+}
@@ -0,0 +1,164 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"reports_system/internal/model/attachment"
+	"reports_system/internal/model/report"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/envelope"
+	"reports_system/pkg/logging"
+	"time"
+)
+
+const (
+	attachmentsTable = "attachments"
+)
+
+// AttachmentPostgres keeps no text of the files, only their blind index terms.
+type AttachmentPostgres struct {
+	db     *sqlx.DB
+	keys   *envelope.Keyring
+	logger logging.Logger
+}
+
+func NewAttachmentPostgres(client *psqlclient.Client, keys *envelope.Keyring, logger logging.Logger) *AttachmentPostgres {
+	return &AttachmentPostgres{db: client.DB, keys: keys, logger: logger}
+}
+
+func (r *AttachmentPostgres) Create(a *attachment.Attachment) error {
+	query := fmt.Sprintf(
+		`INSERT INTO %s (reports_id, name, content_type, size, storage_key)
+				SELECT n.id, $2, $3, $4, $5 FROM %s n WHERE n.id = $1 AND n.status <> $6
+				RETURNING id, text_status, created`,
+		attachmentsTable, reportsTable)
+
+	row := r.db.QueryRow(query, a.ReportID, a.Name, a.ContentType, a.Size, a.StorageKey, report.StatusFinalized)
+	if err := row.Scan(&a.ID, &a.TextStatus, &a.Created); err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &report.AlreadyFinalizedErr{}
+		}
+		return &attachment.CanNotCreateAttachmentErr{}
+	}
+	r.logger.Infof("Attachment with id %v created for report %v", a.ID, a.ReportID)
+
+	return nil
+}
+
+func (r *AttachmentPostgres) GetAll(userID, reportID int) ([]attachment.Attachment, error) {
+	attachments := make([]attachment.Attachment, 0)
+
+	query := fmt.Sprintf(
+		`SELECT a.id, a.reports_id, a.name, a.content_type, a.size, a.storage_key, a.text_status, a.created FROM %s a
+				JOIN %s un ON a.reports_id = un.reports_id
+				JOIN %s n ON n.id = un.reports_id
+				WHERE un.users_id = $1 AND un.reports_id = $2 AND %s
+				ORDER BY a.created`,
+		attachmentsTable, usersReportsTable, reportsTable, readableBy("n", 1))
+
+	err := r.db.Select(&attachments, query, userID, reportID)
+	if err != nil {
+		r.logger.Info(err)
+	}
+	return attachments, err
+}
+
+func (r *AttachmentPostgres) GetOne(userID, reportID, attachmentID int) (attachment.Attachment, error) {
+	var a attachment.Attachment
+
+	query := fmt.Sprintf(
+		`SELECT a.id, a.reports_id, a.name, a.content_type, a.size, a.storage_key, a.text_status, a.created FROM %s a
+				JOIN %s un ON a.reports_id = un.reports_id
+				JOIN %s n ON n.id = un.reports_id
+				WHERE un.users_id = $1 AND un.reports_id = $2 AND a.id = $3 AND %s`,
+		attachmentsTable, usersReportsTable, reportsTable, readableBy("n", 1))
+
+	err := r.db.Get(&a, query, userID, reportID, attachmentID)
+	if err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return a, &attachment.AttachmentNotFoundErr{}
+		}
+	}
+	return a, err
+}
+
+func (r *AttachmentPostgres) Delete(userID, reportID, attachmentID int) error {
+	query := fmt.Sprintf(
+		`DELETE FROM %s a USING %s un, %s n WHERE
+				a.reports_id = un.reports_id AND n.id = un.reports_id
+				AND un.users_id = $1 AND un.reports_id = $2 AND a.id = $3 AND n.status <> $4 AND %s`,
+		attachmentsTable, usersReportsTable, reportsTable, readableBy("n", 1))
+
+	res, err := r.db.Exec(query, userID, reportID, attachmentID, report.StatusFinalized)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return finalizedOr(r.db, reportID, &attachment.AttachmentNotFoundErr{})
+	}
+
+	return nil
+}
+
+// ClaimForIndexing marks the oldest pending attachment as being processed.
+// Attachments stuck in processing longer than staleAfter are picked up again,
+// so a crashed replica doesn't leave them unindexed forever.
+func (r *AttachmentPostgres) ClaimForIndexing(staleAfter time.Duration) (attachment.Attachment, error) {
+	var a attachment.Attachment
+
+	query := fmt.Sprintf(
+		`UPDATE %[1]s SET text_status = $1, text_claimed = now() WHERE id = (
+					SELECT id FROM %[1]s
+					WHERE text_status = $2 OR (text_status = $1 AND text_claimed < $3)
+					ORDER BY id
+					FOR UPDATE SKIP LOCKED
+					LIMIT 1
+				)
+				RETURNING id, reports_id, name, content_type, size, storage_key, text_status, created`,
+		attachmentsTable)
+
+	err := r.db.Get(&a, query, attachment.TextProcessing, attachment.TextPending, time.Now().Add(-staleAfter))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return a, &attachment.AttachmentNotFoundErr{}
+		}
+		r.logger.Info(err)
+	}
+	return a, err
+}
+
+func (r *AttachmentPostgres) SaveText(attachmentID int, status, text string) error {
+	query := fmt.Sprintf(
+		`UPDATE %s SET text_status = $2, search_terms = $3, text_claimed = NULL WHERE id = $1`,
+		attachmentsTable)
+
+	_, err := r.db.Exec(query, attachmentID, status, pq.Array(r.keys.Terms(text)))
+	if err != nil {
+		r.logger.Info(err)
+	}
+	return err
+}
+
+// GetAllAfter pages through attachments of all users by id.
+func (r *AttachmentPostgres) GetAllAfter(afterID, limit int) ([]attachment.Attachment, error) {
+	attachments := make([]attachment.Attachment, 0)
+
+	query := fmt.Sprintf(
+		`SELECT id, reports_id, name, content_type, size, storage_key, text_status, created FROM %s
+				WHERE id > $1
+				ORDER BY id
+				LIMIT $2`,
+		attachmentsTable)
+
+	err := r.db.Select(&attachments, query, afterID, limit)
+	if err != nil {
+		r.logger.Info(err)
+	}
+	return attachments, err
+}
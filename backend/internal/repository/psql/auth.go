@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"reports_system/internal/model/account"
 	"reports_system/pkg/client/psqlclient"
 	"reports_system/pkg/logging"
@@ -12,6 +13,8 @@ import (
 
 const (
 	usersTable = "users"
+
+	uniqueViolation = "23505"
 )
 
 type AuthPostgres struct {
@@ -26,15 +29,19 @@ func NewAuthPostgres(client *psqlclient.Client, logger logging.Logger) *AuthPost
 	}
 }
 
+// CreateAccount registers an account by itself, such accounts start without a
+// department.
 func (r *AuthPostgres) CreateAccount(u *account.Account) error {
 	query := fmt.Sprintf(
-		"INSERT INTO %s (name, username, email, password_hash) VALUES ($1, $2, $3, $4) RETURNING id",
+		`INSERT INTO %s (name, username, email, password_hash, organization_id)
+				VALUES ($1, $2, $3, $4, organization_for_email($3)) RETURNING id, organization_id`,
 		usersTable,
 	)
 
 	r.logger.Info("Creating accounts")
+	u.Department = ""
 	row := r.db.QueryRow(query, u.Name, u.Username, u.Email, u.PasswordHash)
-	if err := row.Scan(&u.ID); err != nil {
+	if err := row.Scan(&u.ID, &u.OrganizationID); err != nil {
 		r.logger.Info(err)
 		return &account.CanNotCreateAccountErr{}
 	}
@@ -43,7 +50,9 @@ func (r *AuthPostgres) CreateAccount(u *account.Account) error {
 
 func (r *AuthPostgres) AuthorizeAccount(u *account.Account) error {
 	query := fmt.Sprintf(
-		"SELECT id, name, username, password_hash, email FROM %s WHERE username=$1",
+		`SELECT id, name, username, password_hash, email, department, totp_enabled, email_verified, role, deactivated,
+					organization_id, clearance, auth_source
+				FROM %s WHERE username=$1`,
 		usersTable,
 	)
 
@@ -60,9 +69,120 @@ func (r *AuthPostgres) AuthorizeAccount(u *account.Account) error {
 	return nil
 }
 
+func (r *AuthPostgres) GetAllByEmail(email string) ([]account.Account, error) {
+	query := fmt.Sprintf(
+		`SELECT id, name, username, email, department, email_verified, email_public, organization_id
+				FROM %s WHERE lower(email)=lower($1)`,
+		usersTable,
+	)
+
+	accounts := make([]account.Account, 0)
+	if err := r.db.Select(&accounts, query, email); err != nil {
+		r.logger.Info(err)
+		return nil, &account.CanNotGetErr{}
+	}
+	return accounts, nil
+}
+
+// GetCredentials is GetOne including the password hash.
+func (r *AuthPostgres) GetCredentials(userID int) (account.Account, error) {
+	query := fmt.Sprintf(
+		`SELECT id, name, username, password_hash, email, department, role, deactivated, organization_id, clearance
+				FROM %s WHERE id=$1`,
+		usersTable,
+	)
+
+	var a account.Account
+
+	err := r.db.Get(&a, query, userID)
+	if err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return a, &account.AccountNotFoundErr{}
+		}
+		return a, &account.CanNotGetErr{}
+	}
+	return a, nil
+}
+
+func (r *AuthPostgres) UpdatePasswordHash(userID int, hash string) error {
+	query := fmt.Sprintf("UPDATE %s SET password_hash=$2 WHERE id=$1", usersTable)
+
+	if _, err := r.db.Exec(query, userID, hash); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
+
+func (r *AuthPostgres) GetOneByUsername(username string) (account.Account, error) {
+	query := fmt.Sprintf(
+		`SELECT id, name, username, email, department, email_verified, email_public, role, deactivated, organization_id,
+					clearance
+				FROM %s WHERE username=$1`,
+		usersTable,
+	)
+
+	var a account.Account
+
+	err := r.db.Get(&a, query, username)
+	if err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return a, &account.AccountNotFoundErr{}
+		}
+		return a, &account.CanNotGetErr{}
+	}
+	return a, nil
+}
+
+func (r *AuthPostgres) UpdateProfile(a account.Account) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET name=$2, username=$3, email=$4, email_verified=$5, email_public=$6 WHERE id=$1",
+		usersTable,
+	)
+
+	_, err := r.db.Exec(query, a.ID, a.Name, a.Username, a.Email, a.EmailVerified, a.EmailPublic)
+	if err != nil {
+		r.logger.Info(err)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return &account.UsernameTakenErr{}
+		}
+		return err
+	}
+	return nil
+}
+
+// Search is served by trigram indexes, they cover both prefix and substring
+// ILIKE patterns. Closest names go first. Hidden emails are not searched, or
+// guessing their prefixes would reveal them, and deactivated accounts are left out.
+func (r *AuthPostgres) Search(q account.DirectoryQuery) ([]account.Account, error) {
+	query := fmt.Sprintf(
+		`SELECT id, name, username, email, department, email_verified, email_public, organization_id FROM %s
+				WHERE organization_id = $5 AND deactivated IS NULL
+					AND ($1 = '' OR name ILIKE '%%' || $2 || '%%' OR username ILIKE $2 || '%%'
+						OR (email_public AND email ILIKE $2 || '%%'))
+					AND ($3 = '' OR department = $3)
+				ORDER BY similarity(name, $1) DESC, username
+				LIMIT $4`,
+		usersTable,
+	)
+
+	accounts := make([]account.Account, 0)
+	err := r.db.Select(&accounts, query, q.Text, account.LikePattern(q.Text), q.Department, q.Limit, q.OrganizationID)
+	if err != nil {
+		r.logger.Info(err)
+		return nil, &account.CanNotGetErr{}
+	}
+	return accounts, nil
+}
+
 func (r *AuthPostgres) GetOne(userID int) (account.Account, error) {
 	query := fmt.Sprintf(
-		"SELECT id, name, username, email FROM %s WHERE id=$1",
+		`SELECT id, name, username, email, department, email_verified, email_public, role, deactivated, organization_id,
+					clearance, auth_source
+				FROM %s WHERE id=$1`,
 		usersTable,
 	)
 
@@ -80,3 +200,33 @@ func (r *AuthPostgres) GetOne(userID int) (account.Account, error) {
 
 	return a, nil
 }
+
+// SyncExternal creates or updates the account of a user known to an external
+// directory. The directory vouches for the email, the department is kept when
+// the directory has none. The organization is chosen once, when the account
+// is created.
+func (r *AuthPostgres) SyncExternal(a *account.Account) error {
+	query := fmt.Sprintf(
+		`INSERT INTO %[1]s (name, username, email, department, password_hash, email_verified, organization_id, auth_source)
+				VALUES ($1, $2, $3, $4, $5, true, organization_for_email($3), $6)
+				ON CONFLICT (username) DO UPDATE SET
+					name = EXCLUDED.name,
+					email = EXCLUDED.email,
+					department = COALESCE(NULLIF(EXCLUDED.department, ''), %[1]s.department),
+					email_verified = true
+				WHERE %[1]s.auth_source = EXCLUDED.auth_source
+				RETURNING id, name, username, password_hash, email, department, totp_enabled, email_verified, role, deactivated,
+					organization_id, clearance, auth_source`,
+		usersTable,
+	)
+
+	err := r.db.Get(a, query, a.Name, a.Username, a.Email, a.Department, a.PasswordHash, a.AuthSource)
+	if err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &account.AuthSourceConflictErr{}
+		}
+		return &account.CanNotLoginErr{}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package psql
+
+import (
+	"testing"
+
+	"reports_system/internal/model/account"
+)
+
+func TestDirectoryHidesPrivateEmailsAndDeactivatedAccounts(t *testing.T) {
+	c := testClient(t)
+	accounts := NewAuthPostgres(c, testLogger())
+	a := newTenant(t, c, "zeta")
+
+	search := func(text string) int {
+		t.Helper()
+		q := account.DirectoryQuery{OrganizationID: a.OrganizationID, Text: text}
+		q.Normalize()
+		found, err := accounts.Search(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(found)
+	}
+
+	if n := search(a.Email); n != 0 {
+		t.Errorf("a hidden email was found %v times", n)
+	}
+	if _, err := c.DB.Exec(`UPDATE users SET email_public = true WHERE id = $1`, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := search(a.Email); n != 1 {
+		t.Errorf("a public email was found %v times", n)
+	}
+
+	if err := NewAdminPostgres(c, testLogger()).SetDeactivated(a.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := search(a.Username); n != 0 {
+		t.Errorf("a deactivated account was found %v times", n)
+	}
+}
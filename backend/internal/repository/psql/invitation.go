@@ -0,0 +1,153 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+)
+
+const (
+	invitationsTable = "invitations"
+
+	// pendingInvitation is the condition of invitations that can still be accepted.
+	pendingInvitation = `accepted IS NULL AND revoked IS NULL AND expires > now()`
+)
+
+type InvitationPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewInvitationPostgres(client *psqlclient.Client, logger logging.Logger) *InvitationPostgres {
+	return &InvitationPostgres{db: client.DB, logger: logger}
+}
+
+// Create revokes pending invitations of the same email in the organization,
+// only the latest link works.
+func (r *InvitationPostgres) Create(i *account.Invitation, tokenHash string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	revokeQuery := fmt.Sprintf(
+		`UPDATE %s SET revoked = now() WHERE organization_id = $1 AND lower(email) = lower($2) AND %s`,
+		invitationsTable, pendingInvitation)
+	if _, err = tx.Exec(revokeQuery, i.OrganizationID, i.Email); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	createQuery := fmt.Sprintf(
+		`INSERT INTO %s (organization_id, email, role, department, token_hash, created_by, expires)
+				VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created`,
+		invitationsTable)
+	row := tx.QueryRow(createQuery, i.OrganizationID, i.Email, i.Role, i.Department, tokenHash, i.CreatedBy, i.Expires)
+	if err = row.Scan(&i.ID, &i.Created); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetAllPending returns invitations of the organization that can still be
+// accepted, newest first.
+func (r *InvitationPostgres) GetAllPending(organizationID int) ([]account.Invitation, error) {
+	invitations := make([]account.Invitation, 0)
+
+	query := fmt.Sprintf(
+		`SELECT id, organization_id, email, role, department, created_by, created, expires FROM %s
+				WHERE organization_id = $1 AND %s
+				ORDER BY created DESC`,
+		invitationsTable, pendingInvitation)
+	if err := r.db.Select(&invitations, query, organizationID); err != nil {
+		r.logger.Info(err)
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (r *InvitationPostgres) Revoke(organizationID, invitationID int) error {
+	query := fmt.Sprintf(
+		`UPDATE %s SET revoked = now() WHERE id = $1 AND organization_id = $2 AND %s`,
+		invitationsTable, pendingInvitation)
+
+	res, err := r.db.Exec(query, invitationID, organizationID)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &account.InvitationNotFoundErr{}
+	}
+	return nil
+}
+
+func (r *InvitationPostgres) GetPending(tokenHash string) (account.Invitation, error) {
+	var i account.Invitation
+
+	query := fmt.Sprintf(
+		`SELECT id, organization_id, email, role, department, created_by, created, expires FROM %s
+				WHERE token_hash = $1 AND %s`,
+		invitationsTable, pendingInvitation)
+	if err := r.db.Get(&i, query, tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return i, &account.InvalidInvitationErr{}
+		}
+		r.logger.Info(err)
+		return i, err
+	}
+	return i, nil
+}
+
+// Accept creates the account and uses up the invitation in one transaction,
+// so an invitation makes one account at most.
+func (r *InvitationPostgres) Accept(invitationID int, a *account.Account) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	lockQuery := fmt.Sprintf(`SELECT id FROM %s WHERE id = $1 AND %s FOR UPDATE`, invitationsTable, pendingInvitation)
+	if err = tx.Get(&id, lockQuery, invitationID); err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &account.InvalidInvitationErr{}
+		}
+		return err
+	}
+
+	createQuery := fmt.Sprintf(
+		`INSERT INTO %s (name, username, email, department, password_hash, email_verified, role, organization_id)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		usersTable)
+	err = tx.Get(&a.ID, createQuery,
+		a.Name, a.Username, a.Email, a.Department, a.PasswordHash, a.EmailVerified, a.Role, a.OrganizationID)
+	if err != nil {
+		r.logger.Info(err)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return &account.UsernameTakenErr{}
+		}
+		return &account.CanNotCreateAccountErr{}
+	}
+
+	acceptQuery := fmt.Sprintf(`UPDATE %s SET accepted = now(), accepted_by = $2 WHERE id = $1`, invitationsTable)
+	if _, err = tx.Exec(acceptQuery, invitationID, a.ID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	return tx.Commit()
+}
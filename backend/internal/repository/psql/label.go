@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"reports_system/internal/model/account"
 	"reports_system/internal/model/label"
 	"reports_system/internal/model/report"
 	"reports_system/pkg/client/psqlclient"
@@ -34,12 +35,13 @@ func (r *LabelPostgres) Create(userID, reportID int, t *label.Label) error {
 	}
 
 	createLabelQuery := fmt.Sprintf(
-		`INSERT INTO %s AS t (name) VALUES ($1) RETURNING id`,
-		labelsTable)
+		`INSERT INTO %s AS t (name, organization_id, parent_id, color, description, icon)
+				VALUES ($1, (SELECT organization_id FROM %s WHERE id = $2), $3, $4, $5, $6) RETURNING id`,
+		labelsTable, usersTable)
 
 	r.logger.Infof("Label with id %v created", t.ID)
 
-	row := r.db.QueryRow(createLabelQuery, t.Name)
+	row := r.db.QueryRow(createLabelQuery, t.Name, userID, t.ParentID, t.Color, t.Description, t.Icon)
 	err = row.Scan(&t.ID)
 
 	if err != nil {
@@ -71,15 +73,23 @@ func (r *LabelPostgres) Create(userID, reportID int, t *label.Label) error {
 	return tx.Commit()
 }
 
+// Assign links a label and a report only within one organization, only if
+// the user is cleared for the report and only while it isn't finalized.
 func (r *LabelPostgres) Assign(labelID, reportID, userID int) error {
 	r.logger.Infof("Assigning label with id %v to report with id with id %v", labelID, reportID)
 	assignLabelQuery := fmt.Sprintf(
-		`INSERT INTO %s (reports_id, labels_id) VALUES ($1, $2)`, reportsLabelsTable)
-	_, err := r.db.Exec(assignLabelQuery, reportID, labelID)
+		`INSERT INTO %s (reports_id, labels_id)
+				SELECT n.id, t.id FROM %s n JOIN %s t ON t.organization_id = n.organization_id
+				WHERE n.id = $1 AND t.id = $2 AND n.status <> $4 AND %s`,
+		reportsLabelsTable, reportsTable, labelsTable, readableBy("n", 3))
+	res, err := r.db.Exec(assignLabelQuery, reportID, labelID, userID, report.StatusFinalized)
 	if err != nil {
 		r.logger.Info(err)
 		return err
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return finalizedOr(r.db, reportID, &label.LabelNotFoundErr{})
+	}
 
 	return nil
 }
@@ -88,9 +98,9 @@ func (r *LabelPostgres) GetAll(userID int) ([]label.Label, error) {
 	var labels []label.Label
 	labels = make([]label.Label, 0)
 
-	query := fmt.Sprintf(`SELECT labels_id AS id, name FROM
+	query := fmt.Sprintf(`SELECT labels_id AS id, name, parent_id, color, description, icon FROM
 								%s t INNER JOIN %s ut ON ut.labels_id = t.id  WHERE
-								ut.users_id = $1`, labelsTable, usersLabelsTable)
+								ut.users_id = $1 AND %s`, labelsTable, usersLabelsTable, sameOrganization("t", 1))
 
 	err := r.db.Select(&labels, query, userID)
 	if err != nil {
@@ -99,15 +109,17 @@ func (r *LabelPostgres) GetAll(userID int) ([]label.Label, error) {
 	return labels, err
 }
 
+// GetAllByReport tells nothing about reports the user isn't cleared for.
 func (r *LabelPostgres) GetAllByReport(userID, reportID int) ([]label.Label, error) {
 	var labels []label.Label
 	labels = make([]label.Label, 0)
 
-	query := fmt.Sprintf(`SELECT t.id AS id, name FROM %s t
+	query := fmt.Sprintf(`SELECT t.id AS id, name, t.parent_id, t.color, t.description, t.icon FROM %s t
     							INNER JOIN %s ut ON ut.labels_id = t.id
     							INNER JOIN %s nt on t.id = nt.labels_id
-    							WHERE users_id = $1 AND reports_id = $2`,
-		labelsTable, usersLabelsTable, reportsLabelsTable)
+    							INNER JOIN %s n on n.id = nt.reports_id
+    							WHERE users_id = $1 AND reports_id = $2 AND %s AND %s`,
+		labelsTable, usersLabelsTable, reportsLabelsTable, reportsTable, sameOrganization("t", 1), readableBy("n", 1))
 
 	err := r.db.Select(&labels, query, userID, reportID)
 	if err != nil {
@@ -116,14 +128,15 @@ func (r *LabelPostgres) GetAllByReport(userID, reportID int) ([]label.Label, err
 	return labels, err
 }
 
+// GetOne finds the label whether or not any report uses it, a parent label
+// may have no reports of its own.
 func (r *LabelPostgres) GetOne(userID, labelID int) (label.Label, error) {
 	var t label.Label
 
-	query := fmt.Sprintf(`SELECT t.id AS id, name FROM %s t
+	query := fmt.Sprintf(`SELECT t.id AS id, name, t.parent_id, t.color, t.description, t.icon FROM %s t
     							INNER JOIN %s ut ON ut.labels_id = t.id
-    							INNER JOIN %s nt on t.id = nt.labels_id
-    							WHERE users_id = $1 AND t.id = $2`,
-		labelsTable, usersLabelsTable, reportsLabelsTable)
+    							WHERE users_id = $1 AND t.id = $2 AND %s`,
+		labelsTable, usersLabelsTable, sameOrganization("t", 1))
 
 	err := r.db.Get(&t, query, userID, labelID)
 	if err != nil {
@@ -138,8 +151,8 @@ func (r *LabelPostgres) GetOne(userID, labelID int) (label.Label, error) {
 func (r *LabelPostgres) Delete(userID, labelID int) error {
 	query := fmt.Sprintf(
 		`DELETE FROM %s t USING %s ut WHERE 
-              t.id = ut.labels_id AND ut.users_id = $1 AND ut.labels_id = $2`,
-		labelsTable, usersLabelsTable)
+              t.id = ut.labels_id AND ut.users_id = $1 AND ut.labels_id = $2 AND %s`,
+		labelsTable, usersLabelsTable, sameOrganization("t", 1))
 	_, err := r.db.Exec(query, userID, labelID)
 
 	return err
@@ -148,21 +161,86 @@ func (r *LabelPostgres) Delete(userID, labelID int) error {
 func (r *LabelPostgres) Update(userID, labelID int, t label.Label) error {
 	query := fmt.Sprintf(
 		`UPDATE %s t SET 
-                name=$1 FROM
+                name=$1, color=$4, description=$5, icon=$6 FROM
                 %s ut WHERE t.id = ut.labels_id AND 
-				ut.labels_id = $3 AND ut.users_id = $4`,
-		labelsTable, usersLabelsTable)
-	_, err := r.db.Exec(query, t.Name, labelID, userID)
+				ut.labels_id = $2 AND ut.users_id = $3 AND %s`,
+		labelsTable, usersLabelsTable, sameOrganization("t", 3))
+	_, err := r.db.Exec(query, t.Name, labelID, userID, t.Color, t.Description, t.Icon)
 
 	return err
 }
 
+// Detach unlinks a label from a report that isn't finalized yet.
 func (r *LabelPostgres) Detach(userID, labelID, reportID int) error {
 	query := fmt.Sprintf(
-		`DELETE FROM %s USING %s ut WHERE
-            	labels_reports.labels_id = ut.labels_id AND ut.users_id = $1 AND ut.labels_id = $2 AND reports_id = $3`,
-		reportsLabelsTable, usersLabelsTable)
-	_, err := r.db.Exec(query, userID, labelID, reportID)
+		`DELETE FROM %s USING %s ut, %s t, %s n WHERE
+            	labels_reports.labels_id = ut.labels_id AND ut.users_id = $1 AND ut.labels_id = $2 AND reports_id = $3
+            	AND t.id = ut.labels_id AND n.id = labels_reports.reports_id AND n.status <> $4 AND %s`,
+		reportsLabelsTable, usersLabelsTable, labelsTable, reportsTable, sameOrganization("t", 1))
+	res, err := r.db.Exec(query, userID, labelID, reportID, report.StatusFinalized)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return finalizedOr(r.db, reportID, &label.LabelNotFoundErr{})
+	}
 
-	return err
+	return nil
+}
+
+// Move puts the label under parentID, or to the top when it is nil. Both labels
+// have to belong to the user, and the parent can't be the label itself or lie
+// in its subtree, that would make a cycle.
+func (r *LabelPostgres) Move(userID, labelID int, parentID *int) error {
+	query := fmt.Sprintf(
+		`WITH RECURSIVE subtree AS (
+					SELECT id FROM %[1]s WHERE id = $2
+				UNION
+					SELECT c.id FROM %[1]s c JOIN subtree s ON c.parent_id = s.id
+				)
+				UPDATE %[1]s t SET parent_id = $3 FROM %[2]s ut
+				WHERE t.id = ut.labels_id AND ut.users_id = $1 AND ut.labels_id = $2 AND %[3]s
+					AND ($3::INT IS NULL OR (
+						$3 NOT IN (SELECT id FROM subtree) AND
+						EXISTS (SELECT 1 FROM %[1]s p JOIN %[2]s up ON up.labels_id = p.id
+							WHERE p.id = $3 AND up.users_id = $1 AND p.organization_id = t.organization_id)
+					))`,
+		labelsTable, usersLabelsTable, sameOrganization("t", 1))
+
+	res, err := r.db.Exec(query, userID, labelID, parentID)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &label.LabelCycleErr{}
+	}
+	return nil
+}
+
+// GetSubtreeIDs returns ids of the user's labels that match labelName the
+// way report filters do, together with the ids of all their descendants.
+// Ids keep apart labels of the same name that live under different parents.
+func (r *LabelPostgres) GetSubtreeIDs(userID int, labelName string) ([]int, error) {
+	ids := make([]int, 0)
+
+	query := fmt.Sprintf(
+		`WITH RECURSIVE subtree AS (
+					SELECT t.id FROM %[1]s t
+					JOIN %[2]s ut ON ut.labels_id = t.id
+					WHERE ut.users_id = $1 AND t.name ILIKE '%%' || $2 || '%%' AND %[3]s
+				UNION
+					SELECT c.id FROM %[1]s c
+					JOIN subtree s ON c.parent_id = s.id
+					JOIN %[2]s uc ON uc.labels_id = c.id AND uc.users_id = $1
+				)
+				SELECT id FROM subtree`,
+		labelsTable, usersLabelsTable, sameOrganization("t", 1))
+
+	err := r.db.Select(&ids, query, userID, account.LikePattern(labelName))
+	if err != nil {
+		r.logger.Info(err)
+	}
+	return ids, err
 }
@@ -0,0 +1,89 @@
+package psql
+
+import (
+	"sort"
+	"testing"
+
+	"reports_system/internal/model/label"
+	"reports_system/internal/model/report"
+	"reports_system/pkg/envelope"
+)
+
+func TestSubtreeKeepsApartLabelsOfTheSameName(t *testing.T) {
+	c := testClient(t)
+	logger := testLogger()
+
+	keys, err := envelope.NewKeyring("test", "test", map[string][]byte{"test": make([]byte, envelope.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := NewReportPostgres(c, keys, logger)
+	labels := NewLabelPostgres(c, logger)
+	a := newTenant(t, c, "epsilon")
+
+	n := report.Report{Header: "Макет", Body: "текст", ShortBody: "текст", Classification: report.ClassificationPublic}
+	if err = reports.Create(a.ID, &n); err != nil {
+		t.Fatal(err)
+	}
+	alpha := label.Label{Name: "alpha"}
+	beta := label.Label{Name: "beta"}
+	for _, l := range []*label.Label{&alpha, &beta} {
+		if err = labels.Create(a.ID, n.ID, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alphaDesign := label.Label{Name: "design", ParentID: &alpha.ID}
+	betaDesign := label.Label{Name: "design", ParentID: &beta.ID}
+	for _, l := range []*label.Label{&alphaDesign, &betaDesign} {
+		if err = labels.Create(a.ID, n.ID, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := labels.GetSubtreeIDs(a.ID, "alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(got)
+	if len(got) != 2 || got[0] != alpha.ID || got[1] != alphaDesign.ID {
+		t.Errorf("subtree of alpha is %v, want [%d %d]", got, alpha.ID, alphaDesign.ID)
+	}
+
+	if err = labels.Assign(betaDesign.ID, n.ID, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	n.Labels, err = labels.GetAllByReport(a.ID, n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.HasLabelFromEach([][]int{got}) {
+		t.Errorf("report tagged with beta/design is found by alpha: %+v", n.Labels)
+	}
+}
+
+func TestUnusedParentLabelIsFound(t *testing.T) {
+	c := testClient(t)
+	logger := testLogger()
+
+	keys, err := envelope.NewKeyring("test", "test", map[string][]byte{"test": make([]byte, envelope.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := NewReportPostgres(c, keys, logger)
+	labels := NewLabelPostgres(c, logger)
+	a := newTenant(t, c, "zeta")
+
+	n := report.Report{Header: "План", Body: "текст", ShortBody: "текст", Classification: report.ClassificationPublic}
+	if err = reports.Create(a.ID, &n); err != nil {
+		t.Fatal(err)
+	}
+	parent := label.Label{Name: "project"}
+	if err = labels.Create(a.ID, n.ID, &parent); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := labels.GetOne(a.ID, parent.ID)
+	if err != nil || got.ID != parent.ID {
+		t.Errorf("label on no report is %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,82 @@
+package psql
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+	"time"
+)
+
+const (
+	loginAttemptsTable = "login_attempts"
+)
+
+// LoginAttemptPostgres keeps failed logins in postgres, so every backend
+// behind the balancer sees the same counters.
+type LoginAttemptPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewLoginAttemptPostgres(client *psqlclient.Client, logger logging.Logger) *LoginAttemptPostgres {
+	return &LoginAttemptPostgres{db: client.DB, logger: logger}
+}
+
+// LockedUntil returns the latest lock of the subjects, zero time if none is locked.
+func (r *LoginAttemptPostgres) LockedUntil(subjects ...string) (time.Time, error) {
+	var until sql.NullTime
+
+	query := fmt.Sprintf(
+		`SELECT max(locked_until) FROM %s WHERE subject = ANY($1) AND locked_until > now()`,
+		loginAttemptsTable)
+	if err := r.db.Get(&until, query, pq.Array(subjects)); err != nil {
+		r.logger.Info(err)
+		return time.Time{}, err
+	}
+	return until.Time, nil
+}
+
+// Fail counts a failed login and returns the number of failures in a row.
+// Failures older than window are forgotten.
+func (r *LoginAttemptPostgres) Fail(subject string, window time.Duration) (int, error) {
+	var failures int
+
+	query := fmt.Sprintf(
+		`INSERT INTO %[1]s (subject, failures, last_failure) VALUES ($1, 1, now())
+				ON CONFLICT (subject) DO UPDATE SET
+					failures = CASE
+						WHEN %[1]s.last_failure < now() - $2 * interval '1 second' THEN 1
+						ELSE %[1]s.failures + 1
+					END,
+					last_failure = now()
+				RETURNING failures`,
+		loginAttemptsTable)
+	if err := r.db.Get(&failures, query, subject, window.Seconds()); err != nil {
+		r.logger.Info(err)
+		return 0, err
+	}
+	return failures, nil
+}
+
+func (r *LoginAttemptPostgres) Lock(subject string, until time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET locked_until = $2 WHERE subject = $1`, loginAttemptsTable)
+
+	if _, err := r.db.Exec(query, subject, until); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
+
+func (r *LoginAttemptPostgres) Reset(subject string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE subject = $1`, loginAttemptsTable)
+
+	if _, err := r.db.Exec(query, subject); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"reports_system/internal/model/report"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+)
+
+const (
+	protocolCountersTable = "protocol_counters"
+)
+
+type NumberingPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewNumberingPostgres(client *psqlclient.Client, logger logging.Logger) *NumberingPostgres {
+	return &NumberingPostgres{db: client.DB, logger: logger}
+}
+
+// Finalize allocates the next protocol number of the department and year
+// within the organization and finalizes the report in the same transaction. The counter row stays locked
+// until commit, so concurrent backends queue up on it, and a rollback returns
+// the number back, which keeps the sequence free of gaps.
+func (r *NumberingPostgres) Finalize(
+	userID, reportID, organizationID int,
+	department string,
+	year int,
+	format func(seq int) string,
+) (string, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var status string
+	lockReportQuery := fmt.Sprintf(
+		`SELECT n.status FROM %s n JOIN %s un ON n.id = un.reports_id
+				WHERE un.users_id = $1 AND un.reports_id = $2 AND n.organization_id = $3 AND %s
+				FOR UPDATE OF n`,
+		reportsTable, usersReportsTable, readableBy("n", 1))
+	err = tx.Get(&status, lockReportQuery, userID, reportID, organizationID)
+	if err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", &report.ReportNotFoundErr{}
+		}
+		return "", err
+	}
+	if status == report.StatusFinalized {
+		return "", &report.AlreadyFinalizedErr{}
+	}
+
+	var seq int
+	nextNumberQuery := fmt.Sprintf(
+		`INSERT INTO %[1]s (organization_id, department, year, last_value) VALUES ($1, $2, $3, 1)
+				ON CONFLICT (organization_id, department, year) DO UPDATE SET last_value = %[1]s.last_value + 1
+				RETURNING last_value`,
+		protocolCountersTable)
+	err = tx.Get(&seq, nextNumberQuery, organizationID, department, year)
+	if err != nil {
+		r.logger.Info(err)
+		return "", err
+	}
+
+	number := format(seq)
+	finalizeQuery := fmt.Sprintf(
+		`UPDATE %s SET status = $2, number = $3, number_department = $4, number_year = $5, number_seq = $6,
+				finalized = now() WHERE id = $1`,
+		reportsTable)
+	_, err = tx.Exec(finalizeQuery, reportID, report.StatusFinalized, number, department, year, seq)
+	if err != nil {
+		r.logger.Info(err)
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Info(err)
+		return "", err
+	}
+	r.logger.Infof("Report %v finalized with number %v", reportID, number)
+
+	return number, nil
+}
@@ -0,0 +1,135 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+)
+
+const (
+	oidcLoginsTable     = "oidc_logins"
+	userIdentitiesTable = "user_identities"
+)
+
+type OIDCPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewOIDCPostgres(client *psqlclient.Client, logger logging.Logger) *OIDCPostgres {
+	return &OIDCPostgres{db: client.DB, logger: logger}
+}
+
+// CreateLogin also forgets expired logins, they are never consumed when the
+// user abandons the identity provider page.
+func (r *OIDCPostgres) CreateLogin(l account.OIDCLogin) error {
+	cleanupQuery := fmt.Sprintf(`DELETE FROM %s WHERE expires < now()`, oidcLoginsTable)
+	if _, err := r.db.Exec(cleanupQuery); err != nil {
+		r.logger.Info(err)
+	}
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s (state_hash, nonce, code_verifier, expires) VALUES ($1, $2, $3, $4)`,
+		oidcLoginsTable)
+	if _, err := r.db.Exec(query, l.StateHash, l.Nonce, l.Verifier, l.Expires); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeLogin returns the login once, a replayed callback gets InvalidSSOStateErr.
+func (r *OIDCPostgres) ConsumeLogin(stateHash string) (account.OIDCLogin, error) {
+	var l account.OIDCLogin
+
+	query := fmt.Sprintf(
+		`DELETE FROM %s WHERE state_hash = $1 RETURNING state_hash, nonce, code_verifier, expires`,
+		oidcLoginsTable)
+	if err := r.db.Get(&l, query, stateHash); err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return l, &account.InvalidSSOStateErr{}
+		}
+		return l, err
+	}
+	return l, nil
+}
+
+func (r *OIDCPostgres) GetUserID(id account.Identity) (int, error) {
+	var userID int
+
+	query := fmt.Sprintf(`SELECT users_id FROM %s WHERE issuer = $1 AND subject = $2`, userIdentitiesTable)
+	if err := r.db.Get(&userID, query, id.Issuer, id.Subject); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, &account.AccountNotFoundErr{}
+		}
+		r.logger.Info(err)
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *OIDCPostgres) Link(id account.Identity, userID int) error {
+	query := fmt.Sprintf(
+		`INSERT INTO %s (issuer, subject, users_id) VALUES ($1, $2, $3)`,
+		userIdentitiesTable)
+	if _, err := r.db.Exec(query, id.Issuer, id.Subject, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
+
+// Provision creates the account together with its identity. It returns
+// UsernameTakenErr when the username is used, the caller tries another one.
+func (r *OIDCPostgres) Provision(a *account.Account, id account.Identity) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	createQuery := fmt.Sprintf(
+		`INSERT INTO %s (name, username, email, department, password_hash, email_verified, role, organization_id, auth_source)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, organization_for_email($3), $8) RETURNING id, organization_id`,
+		usersTable)
+	row := tx.QueryRow(createQuery, a.Name, a.Username, a.Email, a.Department, a.PasswordHash, a.EmailVerified, a.Role,
+		a.AuthSource)
+	err = row.Scan(&a.ID, &a.OrganizationID)
+	if err != nil {
+		r.logger.Info(err)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return &account.UsernameTakenErr{}
+		}
+		return &account.CanNotCreateAccountErr{}
+	}
+
+	linkQuery := fmt.Sprintf(
+		`INSERT INTO %s (issuer, subject, users_id) VALUES ($1, $2, $3)`,
+		userIdentitiesTable)
+	if _, err = tx.Exec(linkQuery, id.Issuer, id.Subject, a.ID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Sync stores what the identity provider decides about the account.
+func (r *OIDCPostgres) Sync(a account.Account) error {
+	query := fmt.Sprintf(
+		`UPDATE %s SET name = $2, department = $3, role = $4 WHERE id = $1`,
+		usersTable)
+	if _, err := r.db.Exec(query, a.ID, a.Name, a.Department, a.Role); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+)
+
+const organizationsTable = "organizations"
+
+// sameOrganization is the condition that keeps rows of the alias within the
+// organization of the user passed as query argument arg. Ownership alone
+// already hides rows of others, this keeps a broken link from leaking data
+// across organizations.
+func sameOrganization(alias string, arg int) string {
+	return fmt.Sprintf(`%s.organization_id = (SELECT organization_id FROM %s WHERE id = $%d)`, alias, usersTable, arg)
+}
+
+type OrganizationPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewOrganizationPostgres(client *psqlclient.Client, logger logging.Logger) *OrganizationPostgres {
+	return &OrganizationPostgres{db: client.DB, logger: logger}
+}
+
+// Sync creates the organization or updates its name and domains by slug.
+func (r *OrganizationPostgres) Sync(o *account.Organization) error {
+	query := fmt.Sprintf(
+		`INSERT INTO %s (slug, name, domains) VALUES ($1, $2, $3)
+				ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name, domains = EXCLUDED.domains
+				RETURNING id`,
+		organizationsTable)
+
+	if err := r.db.Get(&o.ID, query, o.Slug, o.Name, pq.Array(o.Domains)); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
+
+func (r *OrganizationPostgres) GetOne(organizationID int) (account.Organization, error) {
+	var o account.Organization
+
+	query := fmt.Sprintf(`SELECT id, slug, name, domains FROM %s WHERE id = $1`, organizationsTable)
+	row := r.db.QueryRow(query, organizationID)
+	if err := row.Scan(&o.ID, &o.Slug, &o.Name, pq.Array(&o.Domains)); err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return o, &account.OrganizationNotFoundErr{}
+		}
+		return o, err
+	}
+	return o, nil
+}
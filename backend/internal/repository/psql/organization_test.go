@@ -0,0 +1,225 @@
+package psql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"reports_system/internal/model/account"
+	"reports_system/internal/model/attachment"
+	"reports_system/internal/model/label"
+	"reports_system/internal/model/report"
+	"reports_system/internal/model/template"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/envelope"
+)
+
+// newTenant creates an organization with one account in it.
+func newTenant(t *testing.T, c *psqlclient.Client, name string) account.Account {
+	t.Helper()
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	o := account.Organization{
+		Slug:    name + suffix,
+		Name:    name,
+		Domains: []string{name + suffix + ".test"},
+	}
+	if err := NewOrganizationPostgres(c, testLogger()).Sync(&o); err != nil {
+		t.Fatal(err)
+	}
+
+	a := account.Account{
+		Name:         name,
+		Username:     name + suffix,
+		Email:        name + "@" + o.Domains[0],
+		PasswordHash: "!",
+	}
+	if err := NewAuthPostgres(c, testLogger()).CreateAccount(&a); err != nil {
+		t.Fatal(err)
+	}
+	if a.OrganizationID != o.ID {
+		t.Fatalf("account of %v got organization %v, want %v", name, a.OrganizationID, o.ID)
+	}
+	return a
+}
+
+// share links the row to the account the way a bug or a stale row would, the
+// organization checks have to hide it anyway.
+func share(t *testing.T, c *psqlclient.Client, table, column string, userID, id int) {
+	t.Helper()
+
+	query := fmt.Sprintf(`INSERT INTO %s (users_id, %s) VALUES ($1, $2)`, table, column)
+	if _, err := c.DB.Exec(query, userID, id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrganizationsAreIsolated(t *testing.T) {
+	c := testClient(t)
+	logger := testLogger()
+
+	keys, err := envelope.NewKeyring("test", "test", map[string][]byte{"test": make([]byte, envelope.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTenant(t, c, "alpha")
+	b := newTenant(t, c, "beta")
+
+	reports := NewReportPostgres(c, keys, logger)
+	labels := NewLabelPostgres(c, logger)
+	attachments := NewAttachmentPostgres(c, keys, logger)
+	templates := NewTemplatePostgres(c, logger)
+
+	n := report.Report{
+		Header:         "Протокол заседания",
+		Body:           "совершенно секретный текст",
+		ShortBody:      "совершенно секретный",
+		Classification: report.ClassificationPublic,
+	}
+	if err = reports.Create(a.ID, &n); err != nil {
+		t.Fatal(err)
+	}
+	share(t, c, usersReportsTable, "reports_id", b.ID, n.ID)
+
+	t.Run("reports", func(t *testing.T) {
+		if _, err := reports.GetOne(b.ID, n.ID); !errors.Is(err, &report.ReportNotFoundErr{}) {
+			t.Errorf("GetOne of a foreign report returned %v", err)
+		}
+		all, err := reports.GetAll(b.ID)
+		if err != nil || len(all) != 0 {
+			t.Errorf("GetAll returned %v, %v", all, err)
+		}
+		found, err := reports.Search(b.ID, "секретный")
+		if err != nil || len(found) != 0 {
+			t.Errorf("Search returned %v, %v", found, err)
+		}
+		changed := n
+		changed.Header = "Чужой заголовок"
+		if err := reports.Update(b.ID, changed); err == nil {
+			t.Error("a foreign report was updated")
+		}
+		if err := reports.Delete(b.ID, n.ID); err == nil {
+			t.Error("a foreign report was deleted")
+		}
+		if got, err := reports.GetOne(a.ID, n.ID); err != nil || got.Header != n.Header {
+			t.Errorf("the author got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		own := label.Label{Name: "alpha"}
+		if err := labels.Create(a.ID, n.ID, &own); err != nil {
+			t.Fatal(err)
+		}
+		if err := labels.Assign(own.ID, n.ID, a.ID); err != nil {
+			t.Fatal(err)
+		}
+		foreign := label.Label{Name: "beta"}
+		if err := labels.Create(b.ID, 0, &foreign); err != nil {
+			t.Fatal(err)
+		}
+		if err := labels.Assign(foreign.ID, n.ID, b.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := labels.GetAllByReport(a.ID, n.ID)
+		if err != nil || len(got) != 1 || got[0].ID != own.ID {
+			t.Errorf("labels of the report are %v, %v", got, err)
+		}
+		if got, err := labels.GetAllByReport(b.ID, n.ID); err != nil || len(got) != 0 {
+			t.Errorf("labels of a foreign report are %v, %v", got, err)
+		}
+		if _, err := labels.GetOne(b.ID, own.ID); err == nil {
+			t.Error("a foreign label was found")
+		}
+	})
+
+	t.Run("attachments", func(t *testing.T) {
+		att := attachment.Attachment{
+			ReportID:    n.ID,
+			Name:        "protocol.txt",
+			ContentType: "text/plain",
+			Size:        1,
+			StorageKey:  fmt.Sprintf("test/%v", time.Now().UnixNano()),
+		}
+		if err := attachments.Create(&att); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := attachments.GetOne(b.ID, n.ID, att.ID); !errors.Is(err, &attachment.AttachmentNotFoundErr{}) {
+			t.Errorf("GetOne of a foreign attachment returned %v", err)
+		}
+		if got, err := attachments.GetAll(b.ID, n.ID); err != nil || len(got) != 0 {
+			t.Errorf("attachments of a foreign report are %v, %v", got, err)
+		}
+		if _, err := attachments.GetOne(a.ID, n.ID, att.ID); err != nil {
+			t.Errorf("the author can't get the attachment: %v", err)
+		}
+	})
+
+	t.Run("templates", func(t *testing.T) {
+		tpl := template.Template{Name: "Протокол", HeaderPattern: "Протокол {date}", DefaultLabels: []string{}}
+		if err := templates.Create(a.ID, &tpl); err != nil {
+			t.Fatal(err)
+		}
+		share(t, c, usersTemplatesTable, "templates_id", b.ID, tpl.ID)
+
+		if _, err := templates.GetOne(b.ID, tpl.ID); !errors.Is(err, &template.TemplateNotFoundErr{}) {
+			t.Errorf("GetOne of a foreign template returned %v", err)
+		}
+		if got, err := templates.GetAll(b.ID); err != nil || len(got) != 0 {
+			t.Errorf("templates of another organization are %v, %v", got, err)
+		}
+		if _, err := templates.GetOne(a.ID, tpl.ID); err != nil {
+			t.Errorf("the author can't get the template: %v", err)
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		accounts := NewAuthPostgres(c, logger)
+
+		q := account.DirectoryQuery{OrganizationID: b.OrganizationID, Text: a.Username}
+		q.Normalize()
+		if got, err := accounts.Search(q); err != nil || len(got) != 0 {
+			t.Errorf("directory of another organization found %v, %v", got, err)
+		}
+		q.OrganizationID = a.OrganizationID
+		if got, err := accounts.Search(q); err != nil || len(got) != 1 {
+			t.Errorf("directory of the organization found %v, %v", got, err)
+		}
+	})
+
+	t.Run("audit", func(t *testing.T) {
+		admin := NewAdminPostgres(c, logger)
+
+		if err := admin.Audit(account.AuditEntry{Action: account.AuditDeactivate, TargetID: &a.ID}); err != nil {
+			t.Fatal(err)
+		}
+		foreign, err := admin.GetAudit(b.OrganizationID, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range foreign {
+			if e.TargetID != nil && *e.TargetID == a.ID {
+				t.Errorf("audit of another organization shows %+v", e)
+			}
+		}
+		own, err := admin.GetAudit(a.OrganizationID, 100)
+		if err != nil || len(own) == 0 {
+			t.Errorf("audit of the organization is %v, %v", own, err)
+		}
+	})
+
+	t.Run("transfer", func(t *testing.T) {
+		profiles := NewProfilePostgres(c, logger)
+
+		if err := profiles.DeleteTransferring(a.ID, b.ID); !errors.Is(err, &account.TransferTargetNotFoundErr{}) {
+			t.Errorf("transfer to another organization returned %v", err)
+		}
+		if _, err := NewAuthPostgres(c, logger).GetOne(a.ID); err != nil {
+			t.Errorf("the account is gone after a refused transfer: %v", err)
+		}
+	})
+}
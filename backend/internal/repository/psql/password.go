@@ -0,0 +1,128 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+	"time"
+)
+
+const (
+	passwordResetsTable = "password_resets"
+)
+
+type PasswordPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewPasswordPostgres(client *psqlclient.Client, logger logging.Logger) *PasswordPostgres {
+	return &PasswordPostgres{db: client.DB, logger: logger}
+}
+
+// Change sets a new password hash, signs out every other session of the user
+// and revokes the API keys, they could have been made with the old password.
+func (r *PasswordPostgres) Change(userID int, passwordHash, keepSessionID string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = r.setPassword(tx, userID, passwordHash, keepSessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateReset stores a new reset token, older unused tokens of the user stop working.
+func (r *PasswordPostgres) CreateReset(userID int, tokenHash string, expires time.Time) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	expireQuery := fmt.Sprintf(
+		`UPDATE %s SET used = now() WHERE users_id = $1 AND used IS NULL`,
+		passwordResetsTable)
+	if _, err = tx.Exec(expireQuery, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	createQuery := fmt.Sprintf(
+		`INSERT INTO %s (users_id, token_hash, expires) VALUES ($1, $2, $3)`,
+		passwordResetsTable)
+	if _, err = tx.Exec(createQuery, userID, tokenHash, expires); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reset consumes the token and sets a new password, all sessions and API keys
+// of the user are revoked.
+func (r *PasswordPostgres) Reset(tokenHash, passwordHash string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+
+	useQuery := fmt.Sprintf(
+		`UPDATE %s SET used = now() WHERE token_hash = $1 AND used IS NULL AND expires > now()
+				RETURNING users_id`,
+		passwordResetsTable)
+	if err = tx.Get(&userID, useQuery, tokenHash); err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &account.InvalidResetTokenErr{}
+		}
+		return err
+	}
+
+	if err = r.setPassword(tx, userID, passwordHash, ""); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PasswordPostgres) setPassword(tx *sqlx.Tx, userID int, passwordHash, keepSessionID string) error {
+	updateQuery := fmt.Sprintf(`UPDATE %s SET password_hash = $2 WHERE id = $1`, usersTable)
+	res, err := tx.Exec(updateQuery, userID, passwordHash)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &account.AccountNotFoundErr{}
+	}
+
+	revokeQuery := fmt.Sprintf(
+		`UPDATE %s SET revoked = now() WHERE users_id = $1 AND id <> $2 AND revoked IS NULL`,
+		sessionsTable)
+	if _, err = tx.Exec(revokeQuery, userID, keepSessionID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	revokeKeysQuery := fmt.Sprintf(
+		`UPDATE %s SET revoked = now() WHERE users_id = $1 AND revoked IS NULL`,
+		apiKeysTable)
+	if _, err = tx.Exec(revokeKeysQuery, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package psql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"reports_system/internal/model/account"
+)
+
+func TestNewPasswordRevokesAPIKeys(t *testing.T) {
+	c := testClient(t)
+	passwords := NewPasswordPostgres(c, testLogger())
+	keys := NewAPIKeyPostgres(c, testLogger())
+
+	for name, change := range map[string]func(userID int, hash string) error{
+		"change": func(userID int, hash string) error {
+			return passwords.Change(userID, hash, "")
+		},
+		"reset": func(userID int, hash string) error {
+			token := "reset-" + time.Now().Format(time.RFC3339Nano)
+			if err := passwords.CreateReset(userID, token, time.Now().Add(time.Hour)); err != nil {
+				return err
+			}
+			return passwords.Reset(token, hash)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := newTenant(t, c, "iota")
+			keyHash := "key-" + name + time.Now().Format(time.RFC3339Nano)
+			k := account.APIKey{UserID: a.ID, Name: "ci", Scopes: []string{account.ScopeRead}, Expires: time.Now().Add(time.Hour)}
+			if err := keys.Create(&k, keyHash); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := keys.State(keyHash); err != nil {
+				t.Fatalf("the new key doesn't work: %v", err)
+			}
+
+			if err := change(a.ID, "!"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := keys.State(keyHash); !errors.Is(err, &account.InvalidAPIKeyErr{}) {
+				t.Errorf("the key still works after the password %s: %v", name, err)
+			}
+		})
+	}
+}
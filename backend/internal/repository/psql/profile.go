@@ -0,0 +1,201 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"reports_system/internal/model/account"
+	"reports_system/internal/model/report"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+	"time"
+)
+
+const (
+	accountDeletionsTable = "account_deletions"
+)
+
+// ProfilePostgres deletes accounts. Ownership rows go away by cascade, but
+// reports, labels and templates themselves have to be removed or handed over.
+// Everything here is keyed by the account being deleted, the only other
+// account involved is the one reports are handed over to, and it has to be of
+// the same organization.
+type ProfilePostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewProfilePostgres(client *psqlclient.Client, logger logging.Logger) *ProfilePostgres {
+	return &ProfilePostgres{db: client.DB, logger: logger}
+}
+
+// CreateDeletion stores a new deletion token, older unused tokens of the user stop working.
+func (r *ProfilePostgres) CreateDeletion(userID int, tokenHash string, expires time.Time) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	expireQuery := fmt.Sprintf(
+		`UPDATE %s SET used = now() WHERE users_id = $1 AND used IS NULL`,
+		accountDeletionsTable)
+	if _, err = tx.Exec(expireQuery, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	createQuery := fmt.Sprintf(
+		`INSERT INTO %s (users_id, token_hash, expires) VALUES ($1, $2, $3)`,
+		accountDeletionsTable)
+	if _, err = tx.Exec(createQuery, userID, tokenHash, expires); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseDeletion consumes the deletion token, it works only for the account it
+// was mailed to.
+func (r *ProfilePostgres) UseDeletion(userID int, tokenHash string) error {
+	var id int
+
+	useQuery := fmt.Sprintf(
+		`UPDATE %s SET used = now() WHERE users_id = $1 AND token_hash = $2 AND used IS NULL AND expires > now()
+				RETURNING id`,
+		accountDeletionsTable)
+	if err := r.db.Get(&id, useQuery, userID, tokenHash); err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &account.InvalidDeletionTokenErr{}
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteTransferring hands reports and labels of the user over to targetID
+// and deletes the account with its templates. Reports never leave the
+// organization, a target of another one is reported as not found.
+func (r *ProfilePostgres) DeleteTransferring(userID, targetID int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var found bool
+	targetQuery := fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %[1]s t JOIN %[1]s u ON u.organization_id = t.organization_id
+					WHERE u.id = $1 AND t.id = $2 AND t.id <> u.id)`,
+		usersTable)
+	if err = tx.Get(&found, targetQuery, userID, targetID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if !found {
+		return &account.TransferTargetNotFoundErr{}
+	}
+
+	queries := []string{
+		fmt.Sprintf(`UPDATE %s SET users_id = $2 WHERE users_id = $1`, usersReportsTable),
+		fmt.Sprintf(`UPDATE %s SET users_id = $2 WHERE users_id = $1`, usersLabelsTable),
+	}
+	for _, query := range queries {
+		if _, err = tx.Exec(query, userID, targetID); err != nil {
+			r.logger.Info(err)
+			return err
+		}
+	}
+
+	if err = r.deleteAccount(tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// HasFinalized tells whether any report of the user is finalized.
+func (r *ProfilePostgres) HasFinalized(userID int) (bool, error) {
+	return hasFinalized(r.db, userID)
+}
+
+// DeleteWithData deletes the account with everything it owns and returns
+// storage keys of attachments, blobs have to be removed by the caller.
+// Finalized reports are never deleted, they have to be transferred.
+func (r *ProfilePostgres) DeleteWithData(userID int) ([]string, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// a report finalized after the check in the service is caught here
+	finalized, err := hasFinalized(tx, userID)
+	if err != nil {
+		r.logger.Info(err)
+		return nil, err
+	}
+	if finalized {
+		return nil, &account.FinalizedReportsErr{}
+	}
+
+	keys := make([]string, 0)
+
+	keysQuery := fmt.Sprintf(
+		`SELECT a.storage_key FROM %s a JOIN %s un ON un.reports_id = a.reports_id WHERE un.users_id = $1`,
+		attachmentsTable, usersReportsTable)
+	if err = tx.Select(&keys, keysQuery, userID); err != nil {
+		r.logger.Info(err)
+		return nil, err
+	}
+
+	queries := []string{
+		fmt.Sprintf(
+			`DELETE FROM %s n USING %s un WHERE n.id = un.reports_id AND un.users_id = $1`,
+			reportsTable, usersReportsTable),
+		fmt.Sprintf(
+			`DELETE FROM %s t USING %s ut WHERE t.id = ut.labels_id AND ut.users_id = $1`,
+			labelsTable, usersLabelsTable),
+	}
+	for _, query := range queries {
+		if _, err = tx.Exec(query, userID); err != nil {
+			r.logger.Info(err)
+			return nil, err
+		}
+	}
+
+	if err = r.deleteAccount(tx, userID); err != nil {
+		return nil, err
+	}
+	return keys, tx.Commit()
+}
+
+func hasFinalized(q sqlx.Queryer, userID int) (bool, error) {
+	var finalized bool
+	query := fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %s n JOIN %s un ON un.reports_id = n.id WHERE un.users_id = $1 AND n.status = $2)`,
+		reportsTable, usersReportsTable)
+	err := sqlx.Get(q, &finalized, query, userID, report.StatusFinalized)
+	return finalized, err
+}
+
+func (r *ProfilePostgres) deleteAccount(tx *sqlx.Tx, userID int) error {
+	queries := []string{
+		fmt.Sprintf(
+			`DELETE FROM %s t USING %s ut WHERE t.id = ut.templates_id AND ut.users_id = $1`,
+			templatesTable, usersTemplatesTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, usersTable),
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, userID); err != nil {
+			r.logger.Info(err)
+			return err
+		}
+	}
+	return nil
+}
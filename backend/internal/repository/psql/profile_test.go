@@ -0,0 +1,40 @@
+package psql
+
+import (
+	"errors"
+	"testing"
+
+	"reports_system/internal/model/account"
+	"reports_system/internal/model/report"
+	"reports_system/pkg/envelope"
+)
+
+func TestDeleteWithDataKeepsFinalizedReports(t *testing.T) {
+	c := testClient(t)
+
+	keys, err := envelope.NewKeyring("test", "test", map[string][]byte{"test": make([]byte, envelope.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := NewReportPostgres(c, keys, testLogger())
+	profiles := NewProfilePostgres(c, testLogger())
+	a := newTenant(t, c, "epsilon")
+
+	n := report.Report{Header: "Протокол", Body: "текст", ShortBody: "текст", Classification: report.ClassificationPublic}
+	if err = reports.Create(a.ID, &n); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.DB.Exec(`UPDATE reports SET status = $1 WHERE id = $2`, report.StatusFinalized, n.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if finalized, err := profiles.HasFinalized(a.ID); err != nil || !finalized {
+		t.Fatalf("HasFinalized returned %v, %v", finalized, err)
+	}
+	if _, err = profiles.DeleteWithData(a.ID); !errors.Is(err, &account.FinalizedReportsErr{}) {
+		t.Fatalf("DeleteWithData returned %v, want FinalizedReportsErr", err)
+	}
+	if got, err := reports.GetOne(a.ID, n.ID); err != nil || got.ID != n.ID {
+		t.Errorf("the finalized report is %+v, %v", got, err)
+	}
+}
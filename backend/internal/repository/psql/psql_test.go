@@ -0,0 +1,53 @@
+package psql
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+)
+
+// testClient connects to the database given by TEST_POSTGRES_DSN and brings
+// it up to the latest migration. The database is meant to be thrown away,
+// tests leave their rows behind. Without the variable the test is skipped.
+func testClient(t *testing.T) *psqlclient.Client {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := postgres.WithInstance(db.DB, &postgres.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../../etc/migrations", "postgres", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Up(); err != nil && err != migrate.ErrNoChange {
+		t.Fatal(err)
+	}
+
+	return &psqlclient.Client{DB: db}
+}
+
+func testLogger() logging.Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	return logging.Logger{Entry: logrus.NewEntry(l)}
+}
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"reports_system/internal/model/label"
 	"reports_system/internal/model/report"
 	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/envelope"
 	"reports_system/pkg/logging"
 	"time"
 )
@@ -17,19 +20,71 @@ const (
 	usersReportsTable = "users_reports"
 )
 
+// ReportPostgres keeps the header, the body and the short body of reports
+// sealed, they are opened on the way out, so callers always see the plain
+// text. Searching relies on the blind index terms of the header and the body,
+// stored next to the body.
 type ReportPostgres struct {
 	db     *sqlx.DB
+	keys   *envelope.Keyring
 	logger logging.Logger
 }
 
-func NewReportPostgres(client *psqlclient.Client, logger logging.Logger) *ReportPostgres {
+// readableBy is the condition that keeps rows of the reports alias to the ones
+// the user passed as query argument arg may know about: of their organization
+// and classified no higher than their clearance. Other reports behave as if
+// they didn't exist.
+func readableBy(alias string, arg int) string {
+	return fmt.Sprintf(
+		`EXISTS (SELECT 1 FROM %[3]s WHERE id = $%[2]d
+					AND organization_id = %[1]s.organization_id AND clearance >= %[1]s.classification)`,
+		alias, arg, usersTable)
+}
+
+func NewReportPostgres(client *psqlclient.Client, keys *envelope.Keyring, logger logging.Logger) *ReportPostgres {
 	return &ReportPostgres{
 		db:     client.DB,
+		keys:   keys,
 		logger: logger,
 	}
 }
 
+func (r *ReportPostgres) seal(n report.Report) (header, body, shortBody string, err error) {
+	if header, err = r.keys.SealString(n.Header); err != nil {
+		return "", "", "", err
+	}
+	if body, err = r.keys.SealString(n.Body); err != nil {
+		return "", "", "", err
+	}
+	shortBody, err = r.keys.SealString(n.ShortBody)
+	return header, body, shortBody, err
+}
+
+// terms are the blind index terms of the report, its header and body are
+// searched together.
+func (r *ReportPostgres) terms(header, body string) []string {
+	return r.keys.Terms(header + "\n" + body)
+}
+
+func (r *ReportPostgres) open(texts ...*string) error {
+	for _, text := range texts {
+		plain, err := r.keys.OpenString(*text)
+		if err != nil {
+			r.logger.Error(err)
+			return err
+		}
+		*text = plain
+	}
+	return nil
+}
+
 func (r *ReportPostgres) Create(userID int, n *report.Report) error {
+	header, body, shortBody, err := r.seal(*n)
+	if err != nil {
+		r.logger.Error(err)
+		return &report.CanNotCreateReportErr{}
+	}
+
 	tx, err := r.db.Begin()
 	if err != nil {
 		r.logger.Info(err)
@@ -37,9 +92,9 @@ func (r *ReportPostgres) Create(userID int, n *report.Report) error {
 	}
 
 	createReportQuery := fmt.Sprintf(`
-	INSERT INTO %s (header, short_body, edited)
-	VALUES ($1, $2, $3) RETURNING id`, reportsTable)
-	row := tx.QueryRow(createReportQuery, n.Header, n.ShortBody, time.Now())
+	INSERT INTO %s (header, short_body, edited, organization_id, classification)
+	VALUES ($1, $2, $3, (SELECT organization_id FROM %s WHERE id = $4), $5) RETURNING id`, reportsTable, usersTable)
+	row := tx.QueryRow(createReportQuery, header, shortBody, time.Now(), userID, n.Classification)
 	if err := row.Scan(&n.ID); err != nil {
 		tx.Rollback()
 		r.logger.Error(err)
@@ -47,8 +102,9 @@ func (r *ReportPostgres) Create(userID int, n *report.Report) error {
 	}
 	println("HERE1")
 
-	createReportBodyQuery := fmt.Sprintf("INSERT INTO %s (id, body) VALUES ($1, $2)", reportsBodyTable)
-	_, err = tx.Exec(createReportBodyQuery, n.ID, n.Body)
+	createReportBodyQuery := fmt.Sprintf(
+		"INSERT INTO %s (id, body, search_terms) VALUES ($1, $2, $3)", reportsBodyTable)
+	_, err = tx.Exec(createReportBodyQuery, n.ID, body, pq.Array(r.terms(n.Header, n.Body)))
 	if err != nil {
 		tx.Rollback()
 		r.logger.Error(err)
@@ -74,11 +130,13 @@ func (r *ReportPostgres) GetAll(userID int) ([]report.Report, error) {
 	reports = make([]report.Report, 0)
 
 	getReportsQuery := fmt.Sprintf(
-		`SELECT n.id, n.header, n.short_body, n.edited FROM %s n
+		`SELECT n.id, n.header, n.short_body, n.edited, n.status, COALESCE(n.number, '') AS number, n.classification
+				FROM %s n
     			JOIN %s un ON n.id = un.reports_id
-    			WHERE un.users_id = $1`,
+    			WHERE un.users_id = $1 AND %s`,
 		reportsTable,
 		usersReportsTable,
+		readableBy("n", 1),
 	)
 
 	err := r.db.Select(&reports, getReportsQuery, userID)
@@ -87,9 +145,173 @@ func (r *ReportPostgres) GetAll(userID int) ([]report.Report, error) {
 		return reports, err
 	}
 
+	for i := range reports {
+		if err = r.open(&reports[i].Header, &reports[i].ShortBody); err != nil {
+			return reports, err
+		}
+	}
+
 	return reports, err
 }
 
+// StreamRegistry walks over the user's reports straight from the db cursor,
+// so the registry export never holds the whole list in memory.
+func (r *ReportPostgres) StreamRegistry(userID int, fn func(row report.RegistryRow) error) error {
+	query := fmt.Sprintf(
+		`SELECT n.id, COALESCE(n.number, ''), n.header, COALESCE(n.short_body, ''), COALESCE(n.edited, 'epoch'), u.department,
+				n.classification,
+				COALESCE(array_agg(t.id ORDER BY t.name) FILTER (WHERE t.id IS NOT NULL), '{}'),
+				COALESCE(array_agg(t.name ORDER BY t.name) FILTER (WHERE t.id IS NOT NULL), '{}')
+				FROM %s n
+				JOIN %s un ON n.id = un.reports_id
+				JOIN %s u ON u.id = un.users_id AND u.organization_id = n.organization_id AND u.clearance >= n.classification
+				LEFT JOIN %s nt ON nt.reports_id = n.id
+				LEFT JOIN %s t ON t.id = nt.labels_id
+				WHERE un.users_id = $1
+				GROUP BY n.id, u.department
+				ORDER BY n.edited DESC`,
+		reportsTable,
+		usersReportsTable,
+		usersTable,
+		reportsLabelsTable,
+		labelsTable,
+	)
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			row        report.RegistryRow
+			labelIDs   []int64
+			labelNames []string
+		)
+		err = rows.Scan(
+			&row.ID,
+			&row.Number,
+			&row.Header,
+			&row.ShortBody,
+			&row.Edited,
+			&row.Department,
+			&row.Classification,
+			pq.Array(&labelIDs),
+			pq.Array(&labelNames),
+		)
+		if err != nil {
+			r.logger.Info(err)
+			return err
+		}
+		if err = r.open(&row.Header, &row.ShortBody); err != nil {
+			return err
+		}
+
+		row.Labels = make([]label.Label, 0, len(labelNames))
+		for i, name := range labelNames {
+			row.Labels = append(row.Labels, label.Label{ID: int(labelIDs[i]), Name: name})
+		}
+
+		if err = fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Search finds reports whose header, body or attached documents contain every
+// word of the query, and records for each report where exactly the match was
+// found.
+func (r *ReportPostgres) Search(userID int, query string) ([]report.Report, error) {
+	reports := make([]report.Report, 0)
+
+	terms := r.keys.Terms(query)
+	if len(terms) == 0 {
+		return reports, nil
+	}
+
+	searchQuery := fmt.Sprintf(
+		`WITH report_hits AS (
+					SELECT n.id FROM %[1]s n
+					JOIN %[2]s nb ON nb.id = n.id
+					JOIN %[3]s un ON un.reports_id = n.id
+					WHERE un.users_id = $1 AND nb.search_terms @> $2
+				), attachment_hits AS (
+					SELECT a.reports_id, a.id, a.name FROM %[4]s a
+					JOIN %[3]s un ON un.reports_id = a.reports_id
+					WHERE un.users_id = $1 AND a.search_terms @> $2
+				)
+				SELECT n.id, n.header, COALESCE(n.short_body, ''), COALESCE(n.edited, 'epoch'), n.status, COALESCE(n.number, ''),
+					n.classification,
+					n.id IN (SELECT id FROM report_hits),
+					COALESCE(array_agg(ah.id ORDER BY ah.id) FILTER (WHERE ah.id IS NOT NULL), '{}'),
+					COALESCE(array_agg(ah.name ORDER BY ah.id) FILTER (WHERE ah.id IS NOT NULL), '{}')
+				FROM %[1]s n
+				LEFT JOIN attachment_hits ah ON ah.reports_id = n.id
+				WHERE n.id IN (SELECT id FROM report_hits UNION SELECT reports_id FROM attachment_hits) AND %[5]s
+				GROUP BY n.id
+				ORDER BY n.edited DESC`,
+		reportsTable,
+		reportsBodyTable,
+		usersReportsTable,
+		attachmentsTable,
+		readableBy("n", 1),
+	)
+
+	rows, err := r.db.Query(searchQuery, userID, pq.Array(terms))
+	if err != nil {
+		r.logger.Info(err)
+		return reports, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			n               report.Report
+			inReport        bool
+			attachmentIDs   []int64
+			attachmentNames []string
+		)
+		err = rows.Scan(
+			&n.ID,
+			&n.Header,
+			&n.ShortBody,
+			&n.Edited,
+			&n.Status,
+			&n.Number,
+			&n.Classification,
+			&inReport,
+			pq.Array(&attachmentIDs),
+			pq.Array(&attachmentNames),
+		)
+		if err != nil {
+			r.logger.Info(err)
+			return reports, err
+		}
+		if err = r.open(&n.Header, &n.ShortBody); err != nil {
+			return reports, err
+		}
+
+		if inReport {
+			n.Matches = append(n.Matches, report.SearchMatch{Source: report.MatchInReport})
+		}
+		for i := range attachmentIDs {
+			n.Matches = append(n.Matches, report.SearchMatch{
+				Source:         report.MatchInAttachment,
+				AttachmentID:   int(attachmentIDs[i]),
+				AttachmentName: attachmentNames[i],
+			})
+		}
+
+		reports = append(reports, n)
+	}
+
+	return reports, rows.Err()
+}
+
 func (r *ReportPostgres) GetOne(userID, reportID int) (report.Report, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -98,11 +320,12 @@ func (r *ReportPostgres) GetOne(userID, reportID int) (report.Report, error) {
 	var n report.Report
 
 	selectReportQuery := fmt.Sprintf(
-		`SELECT n.id, n.header, n.short_body, n.edited FROM
-				%s n JOIN %s un ON n.id = un.reports_id
-				WHERE un.users_id = $1 AND un.reports_id = $2`,
+		`SELECT n.id, n.header, n.short_body, n.edited, n.status, COALESCE(n.number, '') AS number, n.classification
+				FROM %s n JOIN %s un ON n.id = un.reports_id
+				WHERE un.users_id = $1 AND un.reports_id = $2 AND %s`,
 		reportsTable,
 		usersReportsTable,
+		readableBy("n", 1),
 	)
 
 	err = r.db.Get(&n, selectReportQuery, userID, reportID)
@@ -130,6 +353,10 @@ func (r *ReportPostgres) GetOne(userID, reportID int) (report.Report, error) {
 			return report.Report{}, &report.ReportNotFoundErr{}
 		}
 	}
+	if err = r.open(&n.Header, &n.ShortBody, &n.Body); err != nil {
+		tx.Rollback()
+		return report.Report{}, err
+	}
 
 	return n, tx.Commit()
 }
@@ -137,52 +364,187 @@ func (r *ReportPostgres) GetOne(userID, reportID int) (report.Report, error) {
 func (r *ReportPostgres) Delete(userID, reportID int) error {
 	query := fmt.Sprintf(
 		`DELETE FROM %s n USING %s un WHERE 
-              n.id = un.reports_id AND un.users_id = $1 AND un.reports_id = $2`,
-		reportsTable, usersReportsTable)
-	_, err := r.db.Exec(query, userID, reportID)
+              n.id = un.reports_id AND un.users_id = $1 AND un.reports_id = $2 AND n.status <> $3 AND %s`,
+		reportsTable, usersReportsTable, readableBy("n", 1))
+	res, err := r.db.Exec(query, userID, reportID, report.StatusFinalized)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return r.notChangedErr(err, userID, reportID)
+	}
 
-	return err
+	return nil
 }
 
 func (r *ReportPostgres) Update(userID int, n report.Report) error {
+	header, body, shortBody, err := r.seal(n)
+	if err != nil {
+		r.logger.Error(err)
+		return err
+	}
+
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
 	reportQuery := fmt.Sprintf(
 		`UPDATE %s n SET 
-                header=$1, short_body=$2, edited=$4 FROM
+                header=$1, short_body=$2, edited=$3, classification=$6 FROM
                 %s un WHERE n.id = un.reports_id AND 
-				un.reports_id = $5 AND un.users_id = $6`,
-		reportsTable, usersReportsTable)
-	_, err = r.db.Exec(
+				un.reports_id = $4 AND un.users_id = $5 AND n.status <> $7 AND %s`,
+		reportsTable, usersReportsTable, readableBy("n", 5))
+	res, err := tx.Exec(
 		reportQuery,
-		n.Header,
-		n.ShortBody,
+		header,
+		shortBody,
 		time.Now().UTC().Format(time.RFC3339),
 		n.ID,
 		userID,
+		n.Classification,
+		report.StatusFinalized,
 	)
 	if err != nil {
 		tx.Rollback()
 		r.logger.Info(err)
-		if errors.Is(err, sql.ErrNoRows) {
-			return &report.ReportNotFoundErr{}
-		}
 		return err
 	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		tx.Rollback()
+		return r.notChangedErr(err, userID, n.ID)
+	}
 
 	bodyQuery := fmt.Sprintf(
-		`UPDATE %s nb SET body=$2 WHERE nb.id = $1`,
+		`UPDATE %s nb SET body=$2, search_terms=$3 WHERE nb.id = $1`,
 		reportsBodyTable)
-	_, err = r.db.Exec(bodyQuery, n.ID, n.Body)
+	_, err = tx.Exec(bodyQuery, n.ID, body, pq.Array(r.terms(n.Header, n.Body)))
 	if err != nil {
 		tx.Rollback()
 		r.logger.Info(err)
-		if errors.Is(err, sql.ErrNoRows) {
-			return &report.ReportNotFoundErr{}
-		}
+		return err
 	}
 
 	return tx.Commit()
 }
+
+// notChangedErr explains why an update or a delete of a report touched no
+// rows: the report is either finalized or not visible to the user.
+func (r *ReportPostgres) notChangedErr(err error, userID, reportID int) error {
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	n, err := r.GetOne(userID, reportID)
+	if err != nil {
+		return err
+	}
+	if n.Status == report.StatusFinalized {
+		return &report.AlreadyFinalizedErr{}
+	}
+	return &report.ReportNotFoundErr{}
+}
+
+// finalizedOr explains a change to something of the report that touched no
+// rows: either the report is finalized, or it is notFound.
+func finalizedOr(db *sqlx.DB, reportID int, notFound error) error {
+	var finalized bool
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND status = $2)`, reportsTable)
+	if err := db.Get(&finalized, query, reportID, report.StatusFinalized); err != nil {
+		return err
+	}
+	if finalized {
+		return &report.AlreadyFinalizedErr{}
+	}
+	return notFound
+}
+
+// RewrapKeys goes through up to limit reports after afterID and re-wraps the
+// data keys of their texts with the current master key, texts stored before
+// the encryption get sealed and reports without search terms get indexed. It
+// returns the last report id it went through, 0 once there are no reports
+// left, and how many reports were rewritten. A text changed meanwhile is left
+// alone, it's already sealed with the current key and indexed.
+func (r *ReportPostgres) RewrapKeys(afterID, limit int) (lastID, rewrapped int, err error) {
+	var rows []struct {
+		ID        int    `db:"id"`
+		Header    string `db:"header"`
+		ShortBody string `db:"short_body"`
+		Body      string `db:"body"`
+		Indexed   bool   `db:"indexed"`
+	}
+
+	query := fmt.Sprintf(
+		`SELECT n.id, n.header, COALESCE(n.short_body, '') AS short_body, COALESCE(nb.body, '') AS body,
+					cardinality(nb.search_terms) > 0 AS indexed
+				FROM %s n JOIN %s nb ON nb.id = n.id
+				WHERE n.id > $1
+				ORDER BY n.id
+				LIMIT $2`,
+		reportsTable, reportsBodyTable)
+	if err = r.db.Select(&rows, query, afterID, limit); err != nil {
+		r.logger.Info(err)
+		return 0, 0, err
+	}
+
+	headerQuery := fmt.Sprintf(`UPDATE %s SET header = $2 WHERE id = $1 AND header = $3`, reportsTable)
+	shortBodyQuery := fmt.Sprintf(`UPDATE %s SET short_body = $2 WHERE id = $1 AND short_body = $3`, reportsTable)
+	bodyQuery := fmt.Sprintf(`UPDATE %s SET body = $2 WHERE id = $1 AND body = $3`, reportsBodyTable)
+	termsQuery := fmt.Sprintf(
+		`UPDATE %[1]s nb SET search_terms = $2 FROM %[2]s n
+				WHERE nb.id = $1 AND n.id = nb.id AND nb.body = $3 AND n.header = $4 AND cardinality(nb.search_terms) = 0`,
+		reportsBodyTable, reportsTable)
+
+	for _, row := range rows {
+		lastID = row.ID
+
+		changed := false
+		for _, text := range []struct {
+			name  string
+			query string
+			value *string
+		}{
+			{"header", headerQuery, &row.Header},
+			{"short body", shortBodyQuery, &row.ShortBody},
+			{"body", bodyQuery, &row.Body},
+		} {
+			sealed, textChanged, err := r.keys.RewrapString(*text.value)
+			if err != nil {
+				r.logger.Errorf("failed to rewrap %v of report %v due to error %v", text.name, row.ID, err)
+				return lastID, rewrapped, err
+			}
+			if !textChanged {
+				continue
+			}
+			if _, err = r.db.Exec(text.query, row.ID, sealed, *text.value); err != nil {
+				r.logger.Info(err)
+				return lastID, rewrapped, err
+			}
+			*text.value = sealed
+			changed = true
+		}
+
+		if !row.Indexed {
+			header, body := row.Header, row.Body
+			if err = r.open(&header, &body); err != nil {
+				return lastID, rewrapped, err
+			}
+			// the terms go in only while nothing else has changed the report,
+			// an update indexes it anyway
+			if terms := r.terms(header, body); len(terms) > 0 {
+				if _, err = r.db.Exec(termsQuery, row.ID, pq.Array(terms), row.Body, row.Header); err != nil {
+					r.logger.Info(err)
+					return lastID, rewrapped, err
+				}
+				changed = true
+			}
+		}
+
+		if changed {
+			rewrapped++
+		}
+	}
+
+	return lastID, rewrapped, nil
+}
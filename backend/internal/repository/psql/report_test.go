@@ -0,0 +1,125 @@
+package psql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"reports_system/internal/model/attachment"
+	"reports_system/internal/model/label"
+	"reports_system/internal/model/report"
+	"reports_system/pkg/envelope"
+)
+
+func TestReportsAreSealedAndSearchable(t *testing.T) {
+	c := testClient(t)
+
+	keys, err := envelope.NewKeyring("test", "test", map[string][]byte{"test": make([]byte, envelope.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := NewReportPostgres(c, keys, testLogger())
+	a := newTenant(t, c, "gamma")
+
+	n := report.Report{
+		Header:         "Протокол заседания кафедры",
+		Body:           "Обсуждались отчёты аспирантов",
+		ShortBody:      "Обсуждались отчёты",
+		Classification: report.ClassificationPublic,
+	}
+	if err = reports.Create(a.ID, &n); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored struct {
+		Header string `db:"header"`
+		Body   string `db:"body"`
+	}
+	err = c.DB.Get(&stored,
+		`SELECT n.header, nb.body FROM reports n JOIN reports_body nb ON nb.id = n.id WHERE n.id = $1`, n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored.Header, "Протокол") || strings.Contains(stored.Body, "аспирантов") {
+		t.Fatalf("report is stored in the clear: %+v", stored)
+	}
+
+	for query, want := range map[string]int{
+		"протоколы заседаний": 1,
+		"отчет аспиранта":     1,
+		"приказ":              0,
+		"":                    0,
+	} {
+		found, err := reports.Search(a.ID, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != want {
+			t.Errorf("search for %q found %v reports, want %v", query, len(found), want)
+		}
+		for _, f := range found {
+			if f.Header != n.Header {
+				t.Errorf("search for %q returned header %q", query, f.Header)
+			}
+		}
+	}
+}
+
+func TestFinalizedReportIsFrozen(t *testing.T) {
+	c := testClient(t)
+	logger := testLogger()
+
+	keys, err := envelope.NewKeyring("test", "test", map[string][]byte{"test": make([]byte, envelope.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := NewReportPostgres(c, keys, logger)
+	labels := NewLabelPostgres(c, logger)
+	attachments := NewAttachmentPostgres(c, keys, logger)
+	a := newTenant(t, c, "delta")
+
+	n := report.Report{Header: "Протокол", Body: "текст", ShortBody: "текст", Classification: report.ClassificationPublic}
+	if err = reports.Create(a.ID, &n); err != nil {
+		t.Fatal(err)
+	}
+	assigned := label.Label{Name: "кафедра"}
+	spare := label.Label{Name: "семинар"}
+	for _, l := range []*label.Label{&assigned, &spare} {
+		if err = labels.Create(a.ID, n.ID, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = labels.Assign(assigned.ID, n.ID, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	file := attachment.Attachment{ReportID: n.ID, Name: "notes.txt", ContentType: "text/plain", Size: 1, StorageKey: "reports/frozen"}
+	if err = attachments.Create(&file); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = c.DB.Exec(`UPDATE reports SET status = $1 WHERE id = $2`, report.StatusFinalized, n.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	finalized := &report.AlreadyFinalizedErr{}
+	if err = labels.Assign(spare.ID, n.ID, a.ID); !errors.Is(err, finalized) {
+		t.Errorf("Assign returned %v", err)
+	}
+	if err = labels.Detach(a.ID, assigned.ID, n.ID); !errors.Is(err, finalized) {
+		t.Errorf("Detach returned %v", err)
+	}
+	late := attachment.Attachment{ReportID: n.ID, Name: "late.txt", ContentType: "text/plain", Size: 1, StorageKey: "reports/late"}
+	if err = attachments.Create(&late); !errors.Is(err, finalized) {
+		t.Errorf("attachment Create returned %v", err)
+	}
+	if err = attachments.Delete(a.ID, n.ID, file.ID); !errors.Is(err, finalized) {
+		t.Errorf("attachment Delete returned %v", err)
+	}
+
+	if got, err := labels.GetAllByReport(a.ID, n.ID); err != nil || len(got) != 1 || got[0].ID != assigned.ID {
+		t.Errorf("labels of the finalized report are %+v, %v", got, err)
+	}
+	if got, err := attachments.GetAll(a.ID, n.ID); err != nil || len(got) != 1 {
+		t.Errorf("attachments of the finalized report are %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,211 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+	"time"
+)
+
+const (
+	sessionsTable      = "sessions"
+	refreshTokensTable = "refresh_tokens"
+)
+
+type SessionPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewSessionPostgres(client *psqlclient.Client, logger logging.Logger) *SessionPostgres {
+	return &SessionPostgres{db: client.DB, logger: logger}
+}
+
+// Create stores a new session together with its first refresh token.
+func (r *SessionPostgres) Create(s *account.Session, refreshHash string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return &account.CanNotLoginErr{}
+	}
+	defer tx.Rollback()
+
+	createSessionQuery := fmt.Sprintf(
+		`INSERT INTO %s (id, users_id, user_agent, ip, expires, impersonator_id) VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING created, last_seen, (SELECT organization_id FROM %s WHERE id = $2)`,
+		sessionsTable, usersTable)
+	row := tx.QueryRow(createSessionQuery, s.ID, s.UserID, s.UserAgent, s.IP, s.Expires, s.ImpersonatorID)
+	if err = row.Scan(&s.Created, &s.LastSeen, &s.OrganizationID); err != nil {
+		r.logger.Error(err)
+		return &account.CanNotLoginErr{}
+	}
+
+	createTokenQuery := fmt.Sprintf(
+		`INSERT INTO %s (sessions_id, token_hash, expires) VALUES ($1, $2, $3)`,
+		refreshTokensTable)
+	if _, err = tx.Exec(createTokenQuery, s.ID, refreshHash, s.Expires); err != nil {
+		r.logger.Error(err)
+		return &account.CanNotLoginErr{}
+	}
+
+	return tx.Commit()
+}
+
+// Rotate exchanges a refresh token for a new one. A token that was already
+// used means it leaked, so the whole session is revoked in that case.
+// Impersonation sessions are never refreshed, they end when they expire.
+func (r *SessionPostgres) Rotate(oldHash, newHash string, expires time.Time) (account.Session, error) {
+	var s account.Session
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return s, err
+	}
+	defer tx.Rollback()
+
+	var (
+		tokenID  int
+		used     *time.Time
+		tokenExp time.Time
+	)
+	selectTokenQuery := fmt.Sprintf(
+		`SELECT rt.id, rt.used, rt.expires, s.id, s.users_id, s.created, s.expires, s.revoked, s.impersonator_id,
+					u.organization_id
+				FROM %s rt JOIN %s s ON s.id = rt.sessions_id JOIN %s u ON u.id = s.users_id
+				WHERE rt.token_hash = $1
+				FOR UPDATE OF rt, s`,
+		refreshTokensTable, sessionsTable, usersTable)
+	row := tx.QueryRow(selectTokenQuery, oldHash)
+	err = row.Scan(&tokenID, &used, &tokenExp, &s.ID, &s.UserID, &s.Created, &s.Expires, &s.Revoked, &s.ImpersonatorID,
+		&s.OrganizationID)
+	if err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return s, &account.InvalidRefreshTokenErr{}
+		}
+		return s, err
+	}
+
+	if s.Revoked != nil {
+		return s, &account.SessionRevokedErr{}
+	}
+	if s.ImpersonatorID != nil {
+		return s, &account.InvalidRefreshTokenErr{}
+	}
+
+	if used != nil {
+		r.logger.Warnf("Refresh token of session %v was reused, revoking the session", s.ID)
+		if err = r.revoke(tx, s.ID); err != nil {
+			return s, err
+		}
+		if err = tx.Commit(); err != nil {
+			return s, err
+		}
+		return s, &account.InvalidRefreshTokenErr{}
+	}
+
+	if time.Now().After(tokenExp) {
+		return s, &account.InvalidRefreshTokenErr{}
+	}
+
+	useTokenQuery := fmt.Sprintf(`UPDATE %s SET used = now() WHERE id = $1`, refreshTokensTable)
+	if _, err = tx.Exec(useTokenQuery, tokenID); err != nil {
+		r.logger.Error(err)
+		return s, err
+	}
+
+	createTokenQuery := fmt.Sprintf(
+		`INSERT INTO %s (sessions_id, token_hash, expires) VALUES ($1, $2, $3)`,
+		refreshTokensTable)
+	if _, err = tx.Exec(createTokenQuery, s.ID, newHash, expires); err != nil {
+		r.logger.Error(err)
+		return s, err
+	}
+
+	extendSessionQuery := fmt.Sprintf(`UPDATE %s SET expires = $2, last_seen = now() WHERE id = $1`, sessionsTable)
+	if _, err = tx.Exec(extendSessionQuery, s.ID, expires); err != nil {
+		r.logger.Error(err)
+		return s, err
+	}
+	s.Expires = expires
+
+	return s, tx.Commit()
+}
+
+// State also bumps last_seen of an active session, at most once a minute
+// to avoid a write on every single request. Unknown sessions are inactive.
+// The owner of the session is returned to be compared with the token.
+func (r *SessionPostgres) State(sessionID string) (account.SessionState, error) {
+	var state account.SessionState
+
+	query := fmt.Sprintf(
+		`WITH touched AS (
+					UPDATE %[1]s SET last_seen = now()
+					WHERE id = $1 AND revoked IS NULL AND expires > now() AND last_seen < now() - interval '1 minute'
+				)
+				SELECT s.users_id, s.revoked IS NULL AND s.expires > now() AS active,
+					u.deactivated IS NOT NULL AS deactivated, u.role, s.impersonator_id, u.organization_id
+				FROM %[1]s s JOIN %[2]s u ON u.id = s.users_id
+				WHERE s.id = $1`,
+		sessionsTable, usersTable)
+	err := r.db.Get(&state, query, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return state, nil
+		}
+		r.logger.Info(err)
+	}
+	return state, err
+}
+
+func (r *SessionPostgres) GetAllActive(userID int) ([]account.Session, error) {
+	sessions := make([]account.Session, 0)
+
+	query := fmt.Sprintf(
+		`SELECT id, users_id, user_agent, ip, created, last_seen, expires, revoked, impersonator_id FROM %s
+				WHERE users_id = $1 AND revoked IS NULL AND expires > now()
+				ORDER BY last_seen DESC`,
+		sessionsTable)
+
+	err := r.db.Select(&sessions, query, userID)
+	if err != nil {
+		r.logger.Info(err)
+	}
+	return sessions, err
+}
+
+func (r *SessionPostgres) RevokeForUser(userID int, sessionID string) error {
+	query := fmt.Sprintf(
+		`UPDATE %s SET revoked = now() WHERE id = $1 AND users_id = $2 AND revoked IS NULL`,
+		sessionsTable)
+
+	res, err := r.db.Exec(query, sessionID, userID)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &account.SessionNotFoundErr{}
+	}
+	return nil
+}
+
+func (r *SessionPostgres) Revoke(sessionID string) error {
+	return r.revoke(r.db, sessionID)
+}
+
+func (r *SessionPostgres) revoke(db sqlx.Execer, sessionID string) error {
+	query := fmt.Sprintf(
+		`UPDATE %s SET revoked = now() WHERE id = $1 AND revoked IS NULL`,
+		sessionsTable)
+	_, err := db.Exec(query, sessionID)
+	if err != nil {
+		r.logger.Info(err)
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package psql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"reports_system/internal/model/account"
+)
+
+func TestImpersonationSessionIsNotRefreshed(t *testing.T) {
+	c := testClient(t)
+	sessions := NewSessionPostgres(c, testLogger())
+	admin := newTenant(t, c, "eta")
+	user := newTenant(t, c, "theta")
+
+	for _, tt := range []struct {
+		name           string
+		impersonatorID *int
+		wantErr        error
+	}{
+		{"own session", nil, nil},
+		{"impersonation", &admin.ID, &account.InvalidRefreshTokenErr{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := account.Session{
+				ID:             tt.name + time.Now().Format(time.RFC3339Nano),
+				UserID:         user.ID,
+				Expires:        time.Now().Add(time.Hour),
+				ImpersonatorID: tt.impersonatorID,
+			}
+			if err := sessions.Create(&s, s.ID+"-refresh"); err != nil {
+				t.Fatal(err)
+			}
+			_, err := sessions.Rotate(s.ID+"-refresh", s.ID+"-next", time.Now().Add(24*time.Hour))
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rotate returned %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"reports_system/internal/model/template"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+)
+
+const (
+	templatesTable      = "templates"
+	usersTemplatesTable = "users_templates"
+)
+
+// TemplatePostgres keeps templates private to their author. Templates are
+// reached only through the ownership rows of the user and, like labels, only
+// within the organization of the user.
+type TemplatePostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewTemplatePostgres(client *psqlclient.Client, logger logging.Logger) *TemplatePostgres {
+	return &TemplatePostgres{db: client.DB, logger: logger}
+}
+
+func (r *TemplatePostgres) Create(userID int, t *template.Template) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Info(err)
+		return &template.CanNotCreateTemplateErr{}
+	}
+
+	createTemplateQuery := fmt.Sprintf(
+		`INSERT INTO %s (name, header_pattern, body_skeleton, default_labels, organization_id)
+				VALUES ($1, $2, $3, $4, (SELECT organization_id FROM %s WHERE id = $5)) RETURNING id`,
+		templatesTable, usersTable)
+	row := tx.QueryRow(createTemplateQuery, t.Name, t.HeaderPattern, t.BodySkeleton, pq.Array(t.DefaultLabels), userID)
+	if err := row.Scan(&t.ID); err != nil {
+		tx.Rollback()
+		r.logger.Error(err)
+		return &template.CanNotCreateTemplateErr{}
+	}
+
+	createUsersTemplateQuery := fmt.Sprintf(
+		"INSERT INTO %s (users_id, templates_id) VALUES ($1, $2)", usersTemplatesTable)
+	_, err = tx.Exec(createUsersTemplateQuery, userID, t.ID)
+	if err != nil {
+		tx.Rollback()
+		r.logger.Error(err)
+		return &template.CanNotCreateTemplateErr{}
+	}
+
+	return tx.Commit()
+}
+
+func (r *TemplatePostgres) GetAll(userID int) ([]template.Template, error) {
+	templates := make([]template.Template, 0)
+
+	query := fmt.Sprintf(
+		`SELECT t.id, t.name, t.header_pattern, t.body_skeleton, t.default_labels FROM %s t
+				JOIN %s ut ON t.id = ut.templates_id
+				WHERE ut.users_id = $1 AND %s
+				ORDER BY t.name`,
+		templatesTable, usersTemplatesTable, sameOrganization("t", 1))
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		r.logger.Info(err)
+		return templates, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t template.Template
+		err = rows.Scan(&t.ID, &t.Name, &t.HeaderPattern, &t.BodySkeleton, pq.Array(&t.DefaultLabels))
+		if err != nil {
+			r.logger.Info(err)
+			return templates, err
+		}
+		templates = append(templates, t)
+	}
+
+	return templates, rows.Err()
+}
+
+func (r *TemplatePostgres) GetOne(userID, templateID int) (template.Template, error) {
+	var t template.Template
+
+	query := fmt.Sprintf(
+		`SELECT t.id, t.name, t.header_pattern, t.body_skeleton, t.default_labels FROM %s t
+				JOIN %s ut ON t.id = ut.templates_id
+				WHERE ut.users_id = $1 AND ut.templates_id = $2 AND %s`,
+		templatesTable, usersTemplatesTable, sameOrganization("t", 1))
+
+	row := r.db.QueryRow(query, userID, templateID)
+	err := row.Scan(&t.ID, &t.Name, &t.HeaderPattern, &t.BodySkeleton, pq.Array(&t.DefaultLabels))
+	if err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return t, &template.TemplateNotFoundErr{}
+		}
+	}
+	return t, err
+}
+
+func (r *TemplatePostgres) Update(userID int, t template.Template) error {
+	query := fmt.Sprintf(
+		`UPDATE %s t SET
+				name=$1, header_pattern=$2, body_skeleton=$3, default_labels=$4 FROM
+				%s ut WHERE t.id = ut.templates_id AND
+				ut.templates_id = $5 AND ut.users_id = $6 AND %s`,
+		templatesTable, usersTemplatesTable, sameOrganization("t", 6))
+
+	_, err := r.db.Exec(query, t.Name, t.HeaderPattern, t.BodySkeleton, pq.Array(t.DefaultLabels), t.ID, userID)
+	if err != nil {
+		r.logger.Info(err)
+	}
+	return err
+}
+
+func (r *TemplatePostgres) Delete(userID, templateID int) error {
+	query := fmt.Sprintf(
+		`DELETE FROM %s t USING %s ut WHERE
+				t.id = ut.templates_id AND ut.users_id = $1 AND ut.templates_id = $2 AND %s`,
+		templatesTable, usersTemplatesTable, sameOrganization("t", 1))
+
+	_, err := r.db.Exec(query, userID, templateID)
+
+	return err
+}
@@ -0,0 +1,208 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+)
+
+const (
+	recoveryCodesTable   = "recovery_codes"
+	loginChallengesTable = "login_challenges"
+)
+
+// TwoFactorPostgres touches only the second factor of one account, the
+// authenticated one or the one a login challenge was issued to, and finds
+// challenges by the hash of a random token. Nothing here is looked up across
+// accounts, so there is no organization to check.
+type TwoFactorPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewTwoFactorPostgres(client *psqlclient.Client, logger logging.Logger) *TwoFactorPostgres {
+	return &TwoFactorPostgres{db: client.DB, logger: logger}
+}
+
+func (r *TwoFactorPostgres) GetTOTP(userID int) (account.TOTP, error) {
+	var t account.TOTP
+
+	query := fmt.Sprintf(
+		`SELECT totp_secret, totp_enabled, totp_last_counter FROM %s WHERE id = $1`,
+		usersTable)
+	if err := r.db.Get(&t, query, userID); err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return t, &account.AccountNotFoundErr{}
+		}
+		return t, err
+	}
+	return t, nil
+}
+
+// SetPendingSecret starts the enrollment, the secret isn't used for logins until Enable.
+func (r *TwoFactorPostgres) SetPendingSecret(userID int, secret string) error {
+	query := fmt.Sprintf(
+		`UPDATE %s SET totp_secret = $2, totp_last_counter = NULL WHERE id = $1 AND NOT totp_enabled`,
+		usersTable)
+
+	res, err := r.db.Exec(query, userID, secret)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &account.TwoFactorAlreadyEnabledErr{}
+	}
+	return nil
+}
+
+// Enable turns the second factor on and replaces recovery codes of the user.
+func (r *TwoFactorPostgres) Enable(userID int, counter int64, recoveryHashes []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	enableQuery := fmt.Sprintf(
+		`UPDATE %s SET totp_enabled = true, totp_last_counter = $2
+				WHERE id = $1 AND totp_secret IS NOT NULL AND NOT totp_enabled`,
+		usersTable)
+	res, err := tx.Exec(enableQuery, userID, counter)
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &account.TwoFactorAlreadyEnabledErr{}
+	}
+
+	if err = r.replaceRecoveryCodes(tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *TwoFactorPostgres) Disable(userID int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	disableQuery := fmt.Sprintf(
+		`UPDATE %s SET totp_secret = NULL, totp_enabled = false, totp_last_counter = NULL WHERE id = $1`,
+		usersTable)
+	if _, err = tx.Exec(disableQuery, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	if err = r.replaceRecoveryCodes(tx, userID, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseCounter remembers the time step of an accepted code. It returns false when
+// the same or a newer step was already used, i.e. the code is being replayed.
+func (r *TwoFactorPostgres) UseCounter(userID int, counter int64) (bool, error) {
+	query := fmt.Sprintf(
+		`UPDATE %s SET totp_last_counter = $2
+				WHERE id = $1 AND (totp_last_counter IS NULL OR totp_last_counter < $2)`,
+		usersTable)
+
+	res, err := r.db.Exec(query, userID, counter)
+	if err != nil {
+		r.logger.Info(err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *TwoFactorPostgres) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	query := fmt.Sprintf(
+		`UPDATE %s SET used = now() WHERE id = (
+					SELECT id FROM %[1]s WHERE users_id = $1 AND code_hash = $2 AND used IS NULL LIMIT 1
+				)`,
+		recoveryCodesTable)
+
+	res, err := r.db.Exec(query, userID, codeHash)
+	if err != nil {
+		r.logger.Info(err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *TwoFactorPostgres) CreateChallenge(tokenHash string, c account.Challenge) error {
+	query := fmt.Sprintf(
+		`INSERT INTO %s (token_hash, users_id, user_agent, ip, expires) VALUES ($1, $2, $3, $4, $5)`,
+		loginChallengesTable)
+
+	_, err := r.db.Exec(query, tokenHash, c.UserID, c.Client.UserAgent, c.Client.IP, c.Expires)
+	if err != nil {
+		r.logger.Info(err)
+		return &account.CanNotLoginErr{}
+	}
+	return nil
+}
+
+// AttemptChallenge counts an attempt to pass the challenge. Expired challenges
+// and ones that ran out of attempts are reported as ChallengeExpiredErr.
+func (r *TwoFactorPostgres) AttemptChallenge(tokenHash string) (account.Challenge, error) {
+	var c account.Challenge
+
+	query := fmt.Sprintf(
+		`UPDATE %s SET attempts = attempts + 1
+				WHERE token_hash = $1 AND expires > now() AND attempts < $2
+				RETURNING users_id, user_agent, ip, expires`,
+		loginChallengesTable)
+
+	row := r.db.QueryRow(query, tokenHash, account.MaxChallengeAttempts)
+	if err := row.Scan(&c.UserID, &c.Client.UserAgent, &c.Client.IP, &c.Expires); err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c, &account.ChallengeExpiredErr{}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// DeleteChallenge also cleans up expired challenges of everyone.
+func (r *TwoFactorPostgres) DeleteChallenge(tokenHash string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE token_hash = $1 OR expires < now()`, loginChallengesTable)
+
+	if _, err := r.db.Exec(query, tokenHash); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	return nil
+}
+
+func (r *TwoFactorPostgres) replaceRecoveryCodes(tx *sqlx.Tx, userID int, hashes []string) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE users_id = $1`, recoveryCodesTable)
+	if _, err := tx.Exec(deleteQuery, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	insertQuery := fmt.Sprintf(`INSERT INTO %s (users_id, code_hash) VALUES ($1, $2)`, recoveryCodesTable)
+	for _, hash := range hashes {
+		if _, err := tx.Exec(insertQuery, userID, hash); err != nil {
+			r.logger.Info(err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/logging"
+	"time"
+)
+
+const (
+	emailVerificationsTable = "email_verifications"
+)
+
+type VerificationPostgres struct {
+	db     *sqlx.DB
+	logger logging.Logger
+}
+
+func NewVerificationPostgres(client *psqlclient.Client, logger logging.Logger) *VerificationPostgres {
+	return &VerificationPostgres{db: client.DB, logger: logger}
+}
+
+// Create stores a new verification token, older unused tokens of the user stop working.
+func (r *VerificationPostgres) Create(userID int, tokenHash string, expires time.Time) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	expireQuery := fmt.Sprintf(
+		`UPDATE %s SET used = now() WHERE users_id = $1 AND used IS NULL`,
+		emailVerificationsTable)
+	if _, err = tx.Exec(expireQuery, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	createQuery := fmt.Sprintf(
+		`INSERT INTO %s (users_id, token_hash, expires) VALUES ($1, $2, $3)`,
+		emailVerificationsTable)
+	if _, err = tx.Exec(createQuery, userID, tokenHash, expires); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Verify consumes the token and marks the email of its user as verified.
+func (r *VerificationPostgres) Verify(tokenHash string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		r.logger.Info(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+
+	useQuery := fmt.Sprintf(
+		`UPDATE %s SET used = now() WHERE token_hash = $1 AND used IS NULL AND expires > now()
+				RETURNING users_id`,
+		emailVerificationsTable)
+	if err = tx.Get(&userID, useQuery, tokenHash); err != nil {
+		r.logger.Info(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &account.InvalidVerificationTokenErr{}
+		}
+		return err
+	}
+
+	verifyQuery := fmt.Sprintf(`UPDATE %s SET email_verified = true WHERE id = $1`, usersTable)
+	if _, err = tx.Exec(verifyQuery, userID); err != nil {
+		r.logger.Info(err)
+		return err
+	}
+
+	return tx.Commit()
+}
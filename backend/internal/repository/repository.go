@@ -2,25 +2,39 @@ package repository
 
 import (
 	"reports_system/internal/model/account"
+	"reports_system/internal/model/attachment"
 	"reports_system/internal/model/label"
 	"reports_system/internal/model/report"
+	"reports_system/internal/model/template"
 	"reports_system/internal/repository/psql"
 	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/envelope"
 	"reports_system/pkg/logging"
+	"time"
 )
 
 type Account interface {
 	CreateAccount(a *account.Account) error
 	AuthorizeAccount(a *account.Account) error
+	GetAllByEmail(email string) ([]account.Account, error)
+	GetCredentials(userID int) (account.Account, error)
+	UpdatePasswordHash(userID int, hash string) error
+	GetOneByUsername(username string) (account.Account, error)
+	UpdateProfile(a account.Account) error
+	Search(q account.DirectoryQuery) ([]account.Account, error)
 	GetOne(userID int) (account.Account, error)
+	SyncExternal(a *account.Account) error
 }
 
 type Report interface {
 	Create(userID int, report *report.Report) error
 	GetAll(userID int) ([]report.Report, error)
+	StreamRegistry(userID int, fn func(row report.RegistryRow) error) error
+	Search(userID int, query string) ([]report.Report, error)
 	GetOne(userID, reportID int) (report.Report, error)
 	Delete(userID, reportID int) error
 	Update(userID int, n report.Report) error
+	RewrapKeys(afterID, limit int) (lastID, rewrapped int, err error)
 }
 
 type Label interface {
@@ -32,18 +46,156 @@ type Label interface {
 	Detach(userID, labelID, reportID int) error
 	Assign(labelID, reportID, userID int) error
 	Update(userID, labelID int, t label.Label) error
+	Move(userID, labelID int, parentID *int) error
+	GetSubtreeIDs(userID int, labelName string) ([]int, error)
+}
+
+type Attachment interface {
+	Create(a *attachment.Attachment) error
+	GetAll(userID, reportID int) ([]attachment.Attachment, error)
+	GetOne(userID, reportID, attachmentID int) (attachment.Attachment, error)
+	Delete(userID, reportID, attachmentID int) error
+	ClaimForIndexing(staleAfter time.Duration) (attachment.Attachment, error)
+	SaveText(attachmentID int, status, text string) error
+	GetAllAfter(afterID, limit int) ([]attachment.Attachment, error)
+}
+
+type Template interface {
+	Create(userID int, t *template.Template) error
+	GetAll(userID int) ([]template.Template, error)
+	GetOne(userID, templateID int) (template.Template, error)
+	Update(userID int, t template.Template) error
+	Delete(userID, templateID int) error
+}
+
+type Session interface {
+	Create(s *account.Session, refreshHash string) error
+	Rotate(oldHash, newHash string, expires time.Time) (account.Session, error)
+	State(sessionID string) (account.SessionState, error)
+	GetAllActive(userID int) ([]account.Session, error)
+	RevokeForUser(userID int, sessionID string) error
+	Revoke(sessionID string) error
+}
+
+type Password interface {
+	Change(userID int, passwordHash, keepSessionID string) error
+	CreateReset(userID int, tokenHash string, expires time.Time) error
+	Reset(tokenHash, passwordHash string) error
+}
+
+type TwoFactor interface {
+	GetTOTP(userID int) (account.TOTP, error)
+	SetPendingSecret(userID int, secret string) error
+	Enable(userID int, counter int64, recoveryHashes []string) error
+	Disable(userID int) error
+	UseCounter(userID int, counter int64) (bool, error)
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+	CreateChallenge(tokenHash string, c account.Challenge) error
+	AttemptChallenge(tokenHash string) (account.Challenge, error)
+	DeleteChallenge(tokenHash string) error
+}
+
+type LoginAttempt interface {
+	LockedUntil(subjects ...string) (time.Time, error)
+	Fail(subject string, window time.Duration) (int, error)
+	Lock(subject string, until time.Time) error
+	Reset(subject string) error
+}
+
+type Verification interface {
+	Create(userID int, tokenHash string, expires time.Time) error
+	Verify(tokenHash string) error
+}
+
+type Profile interface {
+	CreateDeletion(userID int, tokenHash string, expires time.Time) error
+	UseDeletion(userID int, tokenHash string) error
+	DeleteTransferring(userID, targetID int) error
+	HasFinalized(userID int) (bool, error)
+	DeleteWithData(userID int) ([]string, error)
+}
+
+type Admin interface {
+	GetAll(q account.DirectoryQuery) ([]account.Account, error)
+	SetDeactivated(userID int, deactivated bool) error
+	SetRole(userID int, role string) error
+	SetClearance(userID int, clearance string) error
+	ForcePasswordReset(userID int) error
+	Audit(entry account.AuditEntry) error
+	GetAudit(organizationID, limit int) ([]account.AuditEntry, error)
+}
+
+type OIDC interface {
+	CreateLogin(l account.OIDCLogin) error
+	ConsumeLogin(stateHash string) (account.OIDCLogin, error)
+	GetUserID(id account.Identity) (int, error)
+	Link(id account.Identity, userID int) error
+	Provision(a *account.Account, id account.Identity) error
+	Sync(a account.Account) error
+}
+
+type APIKey interface {
+	Create(k *account.APIKey, keyHash string) error
+	GetAll(userID int) ([]account.APIKey, error)
+	Revoke(userID, keyID int) error
+	State(keyHash string) (account.APIKeyState, error)
+}
+
+type Numbering interface {
+	Finalize(userID, reportID, organizationID int, department string, year int, format func(seq int) string) (string, error)
+}
+
+type Organization interface {
+	Sync(o *account.Organization) error
+	GetOne(organizationID int) (account.Organization, error)
+}
+
+type Invitation interface {
+	Create(i *account.Invitation, tokenHash string) error
+	GetAllPending(organizationID int) ([]account.Invitation, error)
+	Revoke(organizationID, invitationID int) error
+	GetPending(tokenHash string) (account.Invitation, error)
+	Accept(invitationID int, a *account.Account) error
 }
 
 type Repository struct {
 	Account
 	Report
 	Label
+	Attachment
+	Template
+	Numbering
+	Session
+	Password
+	TwoFactor
+	LoginAttempt
+	Verification
+	Profile
+	Admin
+	OIDC
+	APIKey
+	Organization
+	Invitation
 }
 
-func New(client *psqlclient.Client, logger logging.Logger) *Repository {
+func New(client *psqlclient.Client, keys *envelope.Keyring, logger logging.Logger) *Repository {
 	return &Repository{
-		Account: psql.NewAuthPostgres(client, logger),
-		Report:  psql.NewReportPostgres(client, logger),
-		Label:   psql.NewLabelPostgres(client, logger),
+		Account:      psql.NewAuthPostgres(client, logger),
+		Report:       psql.NewReportPostgres(client, keys, logger),
+		Label:        psql.NewLabelPostgres(client, logger),
+		Attachment:   psql.NewAttachmentPostgres(client, keys, logger),
+		Template:     psql.NewTemplatePostgres(client, logger),
+		Numbering:    psql.NewNumberingPostgres(client, logger),
+		Session:      psql.NewSessionPostgres(client, logger),
+		Password:     psql.NewPasswordPostgres(client, logger),
+		TwoFactor:    psql.NewTwoFactorPostgres(client, logger),
+		LoginAttempt: psql.NewLoginAttemptPostgres(client, logger),
+		Verification: psql.NewVerificationPostgres(client, logger),
+		Profile:      psql.NewProfilePostgres(client, logger),
+		Admin:        psql.NewAdminPostgres(client, logger),
+		OIDC:         psql.NewOIDCPostgres(client, logger),
+		APIKey:       psql.NewAPIKeyPostgres(client, logger),
+		Organization: psql.NewOrganizationPostgres(client, logger),
+		Invitation:   psql.NewInvitationPostgres(client, logger),
 	}
 }
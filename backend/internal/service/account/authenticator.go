@@ -0,0 +1,105 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+)
+
+const (
+	LocalBackend = "local"
+	LDAPBackend  = "ldap"
+)
+
+// Authenticator checks the credentials of a login and returns the local
+// account they belong to. Unknown usernames are AccountNotFoundErr and wrong
+// passwords PasswordDoesNotMatchErr, other errors mean the check failed.
+type Authenticator interface {
+	Authenticate(username, password string) (account.Account, error)
+}
+
+// NewAuthenticator chains the configured backends in order.
+func NewAuthenticator(repository repository.Account, cfg session.Auth, logger logging.Logger) (Authenticator, error) {
+	chain := make(Chain, 0, len(cfg.Backends))
+	for _, backend := range cfg.Backends {
+		switch backend {
+		case LocalBackend:
+			chain = append(chain, NewLocalAuthenticator(repository, logger))
+		case LDAPBackend:
+			chain = append(chain, NewLDAPAuthenticator(repository, cfg.LDAP, logger))
+		default:
+			return nil, fmt.Errorf("unknown authentication backend %q", backend)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no authentication backends configured")
+	}
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+// Chain asks the next authenticator only when the previous one doesn't know
+// the username, a wrong password is final.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(username, password string) (account.Account, error) {
+	for _, a := range c {
+		found, err := a.Authenticate(username, password)
+		if errors.Is(err, &account.AccountNotFoundErr{}) {
+			continue
+		}
+		return found, err
+	}
+	return account.Account{}, &account.AccountNotFoundErr{}
+}
+
+// LocalAuthenticator checks the password hash stored in the database. Accounts
+// of other sources and the ones without a password are left to the next
+// authenticator of the chain.
+type LocalAuthenticator struct {
+	repository repository.Account
+	logger     logging.Logger
+}
+
+func NewLocalAuthenticator(repository repository.Account, logger logging.Logger) *LocalAuthenticator {
+	return &LocalAuthenticator{repository: repository, logger: logger}
+}
+
+func (l *LocalAuthenticator) Authenticate(username, password string) (account.Account, error) {
+	a := account.Account{Username: username, Password: password}
+
+	if err := l.repository.AuthorizeAccount(&a); err != nil {
+		return a, err
+	}
+	if a.AuthSource != account.AuthSourceLocal || !a.HasPassword() {
+		return account.Account{}, &account.AccountNotFoundErr{}
+	}
+	if err := a.CheckPassword(password); err != nil {
+		return a, err
+	}
+	if a.NeedsRehash() {
+		l.rehash(&a)
+	}
+	return a, nil
+}
+
+// rehash upgrades the stored hash to the configured algorithm. Failures are
+// only logged, the old hash keeps working.
+func (l *LocalAuthenticator) rehash(a *account.Account) {
+	hash, err := account.GeneratePasswordHash(a.Password)
+	if err != nil {
+		l.logger.Error(err)
+		return
+	}
+	if err = l.repository.UpdatePasswordHash(a.ID, hash); err != nil {
+		l.logger.Error(err)
+		return
+	}
+	a.PasswordHash = hash
+	l.logger.Infof("password hash of account %v upgraded", a.ID)
+}
@@ -0,0 +1,45 @@
+package account
+
+import (
+	"fmt"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/mail"
+	"reports_system/pkg/securetoken"
+	"time"
+)
+
+// SendInvitation mails the invitation link, the token is not stored anywhere.
+func (s *Service) SendInvitation(i account.Invitation, token string) error {
+	return s.mailer.Send(mail.Message{
+		To:      i.Email,
+		Subject: "Invitation to the reports system",
+		Body: fmt.Sprintf(
+			"Hello!\r\n\r\n"+
+				"You are invited to join the reports system.\r\n"+
+				"Follow the link to register, it is valid until %s:\r\n\r\n%s\r\n\r\n"+
+				"If you don't know what it is about, just ignore this email.\r\n",
+			i.Expires.Format(time.RFC1123), linkWithToken(s.registrationCfg.InviteURL, token),
+		),
+	})
+}
+
+// acceptInvitation registers the account as the invitation says. The email
+// needs no verification, the link has been delivered to it.
+func (s *Service) acceptInvitation(a *account.Account, token string) error {
+	i, err := s.invitationsRepository.GetPending(securetoken.Hash(token))
+	if err != nil {
+		return err
+	}
+	if err = i.Apply(a); err != nil {
+		return err
+	}
+	if err = a.ValidateInvited(s.passwordCfg); err != nil {
+		return err
+	}
+
+	a.PasswordHash, err = account.GeneratePasswordHash(a.Password)
+	if err != nil {
+		return err
+	}
+	return s.invitationsRepository.Accept(i.ID, a)
+}
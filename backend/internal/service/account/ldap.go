@@ -0,0 +1,131 @@
+package account
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/password"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPAuthenticator binds as the user found in the directory, e.g. Active
+// Directory. The local account is created on the first login and its name,
+// email and department follow the directory on every login.
+type LDAPAuthenticator struct {
+	repository repository.Account
+	cfg        session.LDAP
+	logger     logging.Logger
+}
+
+func NewLDAPAuthenticator(repository repository.Account, cfg session.LDAP, logger logging.Logger) *LDAPAuthenticator {
+	return &LDAPAuthenticator{repository: repository, cfg: cfg, logger: logger}
+}
+
+func (l *LDAPAuthenticator) Authenticate(username, pass string) (account.Account, error) {
+	// an empty password would be an unauthenticated bind, which succeeds
+	if username == "" || pass == "" {
+		return account.Account{}, &account.PasswordDoesNotMatchErr{}
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return account.Account{}, fmt.Errorf("failed to connect to ldap due to error %w", err)
+	}
+	defer conn.Close()
+
+	entry, err := l.find(conn, username)
+	if err != nil {
+		return account.Account{}, err
+	}
+
+	if err = conn.Bind(entry.DN, pass); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return account.Account{}, &account.PasswordDoesNotMatchErr{}
+		}
+		return account.Account{}, fmt.Errorf("failed to bind as %s due to error %w", entry.DN, err)
+	}
+
+	a := account.Account{
+		Name:         entry.GetAttributeValue(l.cfg.NameAttribute),
+		Username:     entry.GetAttributeValue(l.cfg.UsernameAttribute),
+		Email:        entry.GetAttributeValue(l.cfg.EmailAttribute),
+		Department:   entry.GetAttributeValue(l.cfg.DepartmentAttribute),
+		PasswordHash: password.Unusable,
+		AuthSource:   account.AuthSourceLDAP,
+	}
+	if a.Username == "" {
+		a.Username = username
+	}
+	if a.Name == "" {
+		a.Name = a.Username
+	}
+
+	if err = l.repository.SyncExternal(&a); err != nil {
+		if errors.Is(err, &account.AuthSourceConflictErr{}) {
+			// the username is taken by a local or sso account, the login
+			// fails the same way as with a wrong password
+			l.logger.Warnf("ldap entry %s matches account %q of another source", entry.DN, a.Username)
+			return account.Account{}, &account.PasswordDoesNotMatchErr{}
+		}
+		return a, err
+	}
+	l.logger.Infof("account %v synced from ldap entry %s", a.ID, entry.DN)
+	return a, nil
+}
+
+func (l *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: l.cfg.InsecureSkipVerify}
+
+	conn, err := ldap.DialURL(l.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: l.cfg.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(l.cfg.Timeout)
+
+	if l.cfg.StartTLS {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// find searches the user with the service account, users usually don't know
+// their distinguished name.
+func (l *LDAPAuthenticator) find(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	if l.cfg.BindDN != "" {
+		if err := conn.Bind(l.cfg.BindDN, l.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("failed to bind as service account due to error %w", err)
+		}
+	}
+
+	req := ldap.NewSearchRequest(
+		l.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(l.cfg.Timeout.Seconds()), false,
+		fmt.Sprintf(l.cfg.UserFilter, ldap.EscapeFilter(username)),
+		[]string{l.cfg.UsernameAttribute, l.cfg.NameAttribute, l.cfg.EmailAttribute, l.cfg.DepartmentAttribute},
+		nil,
+	)
+	res, err := conn.Search(req)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("failed to search ldap due to error %w", err)
+	}
+
+	switch {
+	case res == nil || len(res.Entries) == 0:
+		return nil, &account.AccountNotFoundErr{}
+	case len(res.Entries) > 1:
+		return nil, fmt.Errorf("ldap filter matches several entries for %q", username)
+	}
+	return res.Entries[0], nil
+}
@@ -0,0 +1,301 @@
+package account
+
+import (
+	"errors"
+	"io"
+	"net"
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/password"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	serviceDN       = "cn=admin,dc=bmstu,dc=local"
+	servicePassword = "admin"
+)
+
+type ldapEntry struct {
+	dn       string
+	password string
+	attrs    map[string]string
+}
+
+// ldapServer answers just the binds and searches the authenticator sends:
+// entries are matched by the uid of the search filter.
+type ldapServer struct {
+	listener net.Listener
+	entries  []ldapEntry
+}
+
+func startLDAP(t *testing.T, entries ...ldapEntry) *ldapServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ldapServer{listener: listener, entries: entries}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ldapServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		msg, err := ber.ReadPacket(conn)
+		if err != nil || len(msg.Children) < 2 {
+			return
+		}
+		id, _ := msg.Children[0].Value.(int64)
+		op := msg.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, _ := op.Children[1].Value.(string)
+			code := s.bind(dn, op.Children[2].Data.String())
+			conn.Write(response(id, result(ldap.ApplicationBindResponse, code)))
+		case ldap.ApplicationSearchRequest:
+			uid := equalityValue(op.Children[6], "uid")
+			for _, e := range s.entries {
+				if uid != "" && e.attrs["uid"] == uid {
+					conn.Write(response(id, searchEntry(e)))
+				}
+			}
+			conn.Write(response(id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)))
+		default:
+			return
+		}
+	}
+}
+
+func (s *ldapServer) bind(dn, pass string) int {
+	if dn == serviceDN && pass == servicePassword {
+		return ldap.LDAPResultSuccess
+	}
+	for _, e := range s.entries {
+		if e.dn == dn && pass != "" && e.password == pass {
+			return ldap.LDAPResultSuccess
+		}
+	}
+	return ldap.LDAPResultInvalidCredentials
+}
+
+// equalityValue finds (attr=value) anywhere in the filter.
+func equalityValue(filter *ber.Packet, attr string) string {
+	if filter.ClassType == ber.ClassContext && filter.Tag == ldap.FilterEqualityMatch && len(filter.Children) == 2 {
+		if name, _ := filter.Children[0].Value.(string); name == attr {
+			value, _ := filter.Children[1].Value.(string)
+			return value
+		}
+		return ""
+	}
+	for _, child := range filter.Children {
+		if value := equalityValue(child, attr); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func response(id int64, op *ber.Packet) []byte {
+	msg := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "message")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "id"))
+	msg.AppendChild(op)
+	return msg.Bytes()
+}
+
+func result(tag ber.Tag, code int) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matched dn"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "message"))
+	return op
+}
+
+func searchEntry(e ldapEntry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "dn"))
+
+	attrs := ber.NewSequence("attributes")
+	for name, value := range e.attrs {
+		attr := ber.NewSequence("attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		attr.AppendChild(values)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	return op
+}
+
+// accountsStub keeps accounts by username and syncs external ones the way
+// the database does: only over accounts of the same source.
+type accountsStub struct {
+	repository.Account
+	accounts map[string]account.Account
+}
+
+func (r *accountsStub) AuthorizeAccount(u *account.Account) error {
+	a, ok := r.accounts[u.Username]
+	if !ok {
+		return &account.AccountNotFoundErr{}
+	}
+	*u = a
+	return nil
+}
+
+func (r *accountsStub) SyncExternal(a *account.Account) error {
+	existing, ok := r.accounts[a.Username]
+	if !ok {
+		a.ID = len(r.accounts) + 1
+		r.accounts[a.Username] = *a
+		return nil
+	}
+	if existing.AuthSource != a.AuthSource {
+		return &account.AuthSourceConflictErr{}
+	}
+	existing.Name, existing.Email = a.Name, a.Email
+	if a.Department != "" {
+		existing.Department = a.Department
+	}
+	r.accounts[a.Username] = existing
+	*a = existing
+	return nil
+}
+
+func newLDAPConfig(server *ldapServer) session.LDAP {
+	return session.LDAP{
+		URL:                 server.url(),
+		BindDN:              serviceDN,
+		BindPassword:        servicePassword,
+		BaseDN:              "dc=bmstu,dc=local",
+		UserFilter:          "(&(objectClass=inetOrgPerson)(uid=%s))",
+		UsernameAttribute:   "uid",
+		NameAttribute:       "cn",
+		EmailAttribute:      "mail",
+		DepartmentAttribute: "ou",
+		Timeout:             5 * time.Second,
+	}
+}
+
+func discardLogger() logging.Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	return logging.Logger{Entry: logrus.NewEntry(l)}
+}
+
+var ivanov = ldapEntry{
+	dn:       "uid=ivanov,ou=people,dc=bmstu,dc=local",
+	password: "directory-password",
+	attrs:    map[string]string{"uid": "ivanov", "cn": "Иванов Иван", "mail": "ivanov@bmstu.ru", "ou": "ИУ7"},
+}
+
+func TestLDAPAccountLogsInRepeatedlyAfterLocal(t *testing.T) {
+	server := startLDAP(t, ivanov)
+	accounts := &accountsStub{accounts: map[string]account.Account{}}
+	auth, err := NewAuthenticator(accounts, session.Auth{
+		Backends: []string{LocalBackend, LDAPBackend},
+		LDAP:     newLDAPConfig(server),
+	}, discardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := auth.Authenticate("ivanov", "directory-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Name != "Иванов Иван" || first.Department != "ИУ7" || first.AuthSource != account.AuthSourceLDAP {
+		t.Errorf("unexpected synced account %+v", first)
+	}
+
+	second, err := auth.Authenticate("ivanov", "directory-password")
+	if err != nil {
+		t.Fatalf("second login failed: %v", err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("second login got account %v, want %v", second.ID, first.ID)
+	}
+
+	if _, err = auth.Authenticate("ivanov", "wrong"); !errors.Is(err, &account.PasswordDoesNotMatchErr{}) {
+		t.Errorf("wrong password err = %v, want PasswordDoesNotMatchErr", err)
+	}
+}
+
+func TestLDAPUnknownUser(t *testing.T) {
+	server := startLDAP(t, ivanov)
+	auth := NewLDAPAuthenticator(&accountsStub{accounts: map[string]account.Account{}}, newLDAPConfig(server), discardLogger())
+
+	if _, err := auth.Authenticate("sidorov", "password"); !errors.Is(err, &account.AccountNotFoundErr{}) {
+		t.Errorf("err = %v, want AccountNotFoundErr", err)
+	}
+}
+
+func TestLDAPEmptyPasswordIsNotAnonymousBind(t *testing.T) {
+	server := startLDAP(t, ivanov)
+	auth := NewLDAPAuthenticator(&accountsStub{accounts: map[string]account.Account{}}, newLDAPConfig(server), discardLogger())
+
+	if _, err := auth.Authenticate("ivanov", ""); !errors.Is(err, &account.PasswordDoesNotMatchErr{}) {
+		t.Errorf("err = %v, want PasswordDoesNotMatchErr", err)
+	}
+}
+
+func TestLDAPDoesNotTakeOverAccountsOfOtherSources(t *testing.T) {
+	for _, source := range []string{account.AuthSourceLocal, account.AuthSourceOIDC} {
+		t.Run(source, func(t *testing.T) {
+			server := startLDAP(t, ivanov)
+			local := account.Account{
+				ID: 7, Username: "ivanov", Name: "Другой Иванов", Email: "other@bmstu.ru",
+				PasswordHash: "$2a$04$local", AuthSource: source,
+			}
+			accounts := &accountsStub{accounts: map[string]account.Account{"ivanov": local}}
+			auth := NewLDAPAuthenticator(accounts, newLDAPConfig(server), discardLogger())
+
+			if _, err := auth.Authenticate("ivanov", "directory-password"); !errors.Is(err, &account.PasswordDoesNotMatchErr{}) {
+				t.Fatalf("err = %v, want PasswordDoesNotMatchErr", err)
+			}
+			if accounts.accounts["ivanov"] != local {
+				t.Errorf("account was changed by the directory: %+v", accounts.accounts["ivanov"])
+			}
+		})
+	}
+}
+
+func TestLocalAuthenticatorPassesAccountsWithoutPassword(t *testing.T) {
+	accounts := &accountsStub{accounts: map[string]account.Account{
+		"ldap":  {ID: 1, Username: "ldap", PasswordHash: password.Unusable, AuthSource: account.AuthSourceLDAP},
+		"sso":   {ID: 2, Username: "sso", PasswordHash: "$2a$04$hash", AuthSource: account.AuthSourceOIDC},
+		"reset": {ID: 3, Username: "reset", PasswordHash: password.Unusable, AuthSource: account.AuthSourceLocal},
+	}}
+	auth := NewLocalAuthenticator(accounts, discardLogger())
+
+	for username := range accounts.accounts {
+		if _, err := auth.Authenticate(username, "password"); !errors.Is(err, &account.AccountNotFoundErr{}) {
+			t.Errorf("%s: err = %v, want AccountNotFoundErr so the chain goes on", username, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package account
+
+import (
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"time"
+)
+
+// Limiter slows down password guessing. Failures are counted separately for
+// the username, against guessing one account from many addresses, and for
+// the ip, against trying many accounts from one address.
+type Limiter struct {
+	repository repository.LoginAttempt
+	cfg        session.Lockout
+}
+
+func NewLimiter(repository repository.LoginAttempt, cfg session.Lockout) *Limiter {
+	return &Limiter{repository: repository, cfg: cfg}
+}
+
+// Check returns TooManyAttemptsErr while the username or the ip is locked.
+func (l *Limiter) Check(username, ip string) error {
+	until, err := l.repository.LockedUntil(account.UsernameSubject(username), account.IPSubject(ip))
+	if err != nil {
+		return err
+	}
+	if retryAfter := time.Until(until); retryAfter > 0 {
+		return &account.TooManyAttemptsErr{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Fail counts a failure and locks subjects that went over their threshold.
+// Errors are only logged, the caller reports the original login error anyway.
+func (l *Limiter) Fail(username, ip string) {
+	l.fail(account.UsernameSubject(username), l.cfg.UserThreshold)
+	l.fail(account.IPSubject(ip), l.cfg.IPThreshold)
+}
+
+// Succeed forgets failures of the username. Failures of the ip are kept, so
+// logging into an own account doesn't allow to continue guessing others.
+func (l *Limiter) Succeed(username string) {
+	if err := l.repository.Reset(account.UsernameSubject(username)); err != nil {
+		logging.GetLogger().Error(err)
+	}
+}
+
+func (l *Limiter) fail(subject string, threshold int) {
+	logger := logging.GetLogger()
+
+	failures, err := l.repository.Fail(subject, l.cfg.Window)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	delay := account.LockoutDelay(failures, threshold, l.cfg.BaseDelay, l.cfg.MaxDelay)
+	if delay == 0 {
+		return
+	}
+	logger.Infof("locking logins of %s for %v after %d failures", subject, delay, failures)
+	if err = l.repository.Lock(subject, time.Now().Add(delay)); err != nil {
+		logger.Error(err)
+	}
+}
@@ -1,22 +1,85 @@
 package account
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"reports_system/internal/model/account"
 	"reports_system/internal/repository"
+	"reports_system/internal/session"
 	"reports_system/pkg/jwt"
 	"reports_system/pkg/logging"
+	"reports_system/pkg/mail"
+	"reports_system/pkg/securetoken"
+	"time"
 )
 
 type Service struct {
-	repository repository.Account
+	repository              repository.Account
+	authenticator           Authenticator
+	sessionsRepository      repository.Session
+	passwordsRepository     repository.Password
+	twoFactorRepository     repository.TwoFactor
+	verificationsRepository repository.Verification
+	invitationsRepository   repository.Invitation
+	limiter                 *Limiter
+	mailer                  mail.Sender
+	cfg                     session.JWT
+	resetCfg                session.PasswordReset
+	twoFactorCfg            session.TwoFactor
+	registrationCfg         session.Registration
+	passwordCfg             session.Password
+	logger                  logging.Logger
 }
 
-func NewService(repository repository.Account) *Service {
-	return &Service{repository: repository}
+func NewService(
+	repository repository.Account,
+	authenticator Authenticator,
+	sessionsRepository repository.Session,
+	passwordsRepository repository.Password,
+	twoFactorRepository repository.TwoFactor,
+	verificationsRepository repository.Verification,
+	invitationsRepository repository.Invitation,
+	limiter *Limiter,
+	mailer mail.Sender,
+	cfg session.JWT,
+	resetCfg session.PasswordReset,
+	twoFactorCfg session.TwoFactor,
+	registrationCfg session.Registration,
+	passwordCfg session.Password,
+	logger logging.Logger,
+) *Service {
+	return &Service{
+		repository:              repository,
+		authenticator:           authenticator,
+		sessionsRepository:      sessionsRepository,
+		passwordsRepository:     passwordsRepository,
+		twoFactorRepository:     twoFactorRepository,
+		verificationsRepository: verificationsRepository,
+		invitationsRepository:   invitationsRepository,
+		limiter:                 limiter,
+		mailer:                  mailer,
+		cfg:                     cfg,
+		resetCfg:                resetCfg,
+		twoFactorCfg:            twoFactorCfg,
+		registrationCfg:         registrationCfg,
+		passwordCfg:             passwordCfg,
+		logger:                  logger,
+	}
 }
 
-func (s *Service) CreateAccount(a *account.Account) error {
-	err := a.Validate()
+// CreateAccount registers an account. With an invite token the account joins
+// the organization of the invitation with its role and department.
+func (s *Service) CreateAccount(a *account.Account, invite string) error {
+	if invite != "" {
+		return s.acceptInvitation(a, invite)
+	}
+
+	err := a.Validate(s.passwordCfg)
+	if err != nil {
+		return err
+	}
+	a.PasswordHash, err = account.GeneratePasswordHash(a.Password)
 	if err != nil {
 		return err
 	}
@@ -24,29 +87,237 @@ func (s *Service) CreateAccount(a *account.Account) error {
 	if err != nil {
 		return err
 	}
+
+	// the account exists anyway, a lost email can be sent again with ResendVerification
+	if err = s.SendVerification(*a); err != nil {
+		s.logger.Error(err)
+	}
 	return nil
 }
 
-func (s *Service) GenerateJWT(a *account.Account) (string, error) {
-	err := s.repository.AuthorizeAccount(a)
-	logging.GetLogger().Info(a.ID, a.Name, a.Email)
+// GenerateJWT checks the credentials with the configured authenticator.
+// Accounts with two-factor authentication only get a challenge, tokens are
+// issued by VerifySecondFactor.
+func (s *Service) GenerateJWT(a *account.Account, client account.Client) (account.Tokens, error) {
+	username := a.Username
+
+	err := s.limiter.Check(username, client.IP)
+	if err != nil {
+		return account.Tokens{}, err
+	}
+
+	authenticated, err := s.authenticator.Authenticate(username, a.Password)
+	if err != nil {
+		if errors.Is(err, &account.AccountNotFoundErr{}) || errors.Is(err, &account.PasswordDoesNotMatchErr{}) {
+			s.limiter.Fail(username, client.IP)
+		}
+		return account.Tokens{}, err
+	}
+	*a = authenticated
+	s.logger.Info(a.ID, a.Name, a.Email)
+
+	if a.Deactivated != nil {
+		return account.Tokens{}, &account.AccountDeactivatedErr{}
+	}
+	if s.registrationCfg.RequireVerification && !a.EmailVerified {
+		return account.Tokens{}, &account.EmailNotVerifiedErr{}
+	}
+
+	if a.TOTPEnabled {
+		return s.startChallenge(a.ID, client)
+	}
+	s.limiter.Succeed(username)
+	return s.StartSession(a.ID, client)
+}
+
+// Refresh rotates the refresh token and issues a new access token for the same session.
+func (s *Service) Refresh(refreshToken string) (account.Tokens, error) {
+	newRefresh, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return account.Tokens{}, err
+	}
+
+	ss, err := s.sessionsRepository.Rotate(
+		securetoken.Hash(refreshToken),
+		securetoken.Hash(newRefresh),
+		time.Now().Add(s.cfg.RefreshTTL),
+	)
+	if err != nil {
+		return account.Tokens{}, err
+	}
+
+	access, err := jwt.GenerateAccessToken(ss.UserID, ss.OrganizationID, ss.ID)
+	if err != nil {
+		return account.Tokens{}, err
+	}
+
+	return account.Tokens{Access: access, Refresh: newRefresh}, nil
+}
+
+func (s *Service) Logout(sessionID string) error {
+	return s.sessionsRepository.Revoke(sessionID)
+}
+
+func (s *Service) CheckSession(sessionID string) (account.SessionState, error) {
+	return s.sessionsRepository.State(sessionID)
+}
+
+func (s *Service) GetSessions(userID int) ([]account.Session, error) {
+	return s.sessionsRepository.GetAllActive(userID)
+}
+
+// RevokeSession signs out one of the user's sessions, e.g. on a lost device.
+func (s *Service) RevokeSession(userID int, sessionID string) error {
+	return s.sessionsRepository.RevokeForUser(userID, sessionID)
+}
+
+// ChangePassword keeps the current session alive and signs out all the others.
+func (s *Service) ChangePassword(userID int, sessionID, oldPassword, newPassword string) error {
+	a, err := s.repository.GetCredentials(userID)
+	if err != nil {
+		return err
+	}
+	if err = a.CheckPassword(oldPassword); err != nil {
+		return err
+	}
+	if err = account.ValidatePassword(newPassword, s.passwordCfg); err != nil {
+		return err
+	}
+
+	hash, err := account.GeneratePasswordHash(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.passwordsRepository.Change(userID, hash, sessionID)
+}
+
+// RequestPasswordReset mails a reset link to every account registered with the email.
+// Unknown emails are not reported, so the endpoint can't be used to look up users.
+// Failures to send are only logged for the same reason: an error would be
+// returned just for the emails that have accounts.
+func (s *Service) RequestPasswordReset(email string) error {
+	accounts, err := s.repository.GetAllByEmail(email)
+	if err != nil {
+		return err
+	}
 
-	err = a.CheckPassword(a.Password)
+	for _, a := range accounts {
+		if err = s.SendPasswordReset(a); err != nil {
+			s.logger.Error(err)
+		}
+	}
+	return nil
+}
+
+// SendPasswordReset mails a reset link to the account, older links stop working.
+func (s *Service) SendPasswordReset(a account.Account) error {
+	token, err := securetoken.New(securetoken.DefaultSize)
 	if err != nil {
-		return "", err
+		return err
+	}
+	err = s.passwordsRepository.CreateReset(a.ID, securetoken.Hash(token), time.Now().Add(s.resetCfg.TTL))
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(s.resetMessage(a, token))
+}
+
+func (s *Service) ResetPassword(token, password string) error {
+	if err := account.ValidatePassword(password, s.passwordCfg); err != nil {
+		return err
 	}
 
-	token, err := jwt.GenerateAccessToken(a.ID)
+	hash, err := account.GeneratePasswordHash(password)
 	if err != nil {
-		return "", err
+		return err
+	}
+	return s.passwordsRepository.Reset(securetoken.Hash(token), hash)
+}
+
+func (s *Service) resetMessage(a account.Account, token string) mail.Message {
+	link := linkWithToken(s.resetCfg.URL, token)
+
+	return mail.Message{
+		To:      a.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"Hello, %s!\r\n\r\n"+
+				"Someone requested a password reset for account %s.\r\n"+
+				"Follow the link to set a new password, it is valid for %v:\r\n\r\n%s\r\n\r\n"+
+				"If it wasn't you, just ignore this email.\r\n",
+			a.Name, a.Username, s.resetCfg.TTL, link,
+		),
 	}
+}
 
-	return token, nil
+func (s *Service) Search(q account.DirectoryQuery) ([]account.Account, error) {
+	q.Normalize()
+	return s.repository.Search(q)
 }
 
 func (s *Service) GetOne(userID int) (account.Account, error) {
 	a, err := s.repository.GetOne(userID)
-	logging.GetLogger().Info(a.ID, a.Name, a.Email)
+	s.logger.Info(a.ID, a.Name, a.Email)
 
 	return a, err
 }
+
+// StartImpersonation opens a session of userID on behalf of an admin. The
+// session is marked with the admin's id and shows up in the user's session list.
+// It ends after ImpersonationTTL, the refresh token is refused.
+func (s *Service) StartImpersonation(adminID, userID int, client account.Client) (account.Tokens, error) {
+	return s.newSession(userID, &adminID, client)
+}
+
+// StartSession opens a session of a user that is already authenticated, by
+// the password here or e.g. by the identity provider.
+func (s *Service) StartSession(userID int, client account.Client) (account.Tokens, error) {
+	return s.newSession(userID, nil, client)
+}
+
+func (s *Service) newSession(userID int, impersonatorID *int, client account.Client) (account.Tokens, error) {
+	sessionID, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return account.Tokens{}, err
+	}
+	refresh, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return account.Tokens{}, err
+	}
+
+	ttl := s.cfg.RefreshTTL
+	if impersonatorID != nil {
+		ttl = s.cfg.ImpersonationTTL
+	}
+	ss := account.Session{
+		ID:        sessionID,
+		UserID:    userID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		Expires:   time.Now().Add(ttl),
+
+		ImpersonatorID: impersonatorID,
+	}
+	if err = s.sessionsRepository.Create(&ss, securetoken.Hash(refresh)); err != nil {
+		return account.Tokens{}, err
+	}
+
+	access, err := jwt.GenerateAccessToken(userID, ss.OrganizationID, sessionID)
+	if err != nil {
+		return account.Tokens{}, err
+	}
+
+	return account.Tokens{Access: access, Refresh: refresh}, nil
+}
+
+func linkWithToken(base, token string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
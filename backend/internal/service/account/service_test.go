@@ -0,0 +1,99 @@
+package account
+
+import (
+	"errors"
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/mail"
+	"testing"
+	"time"
+)
+
+type emailsStub struct {
+	repository.Account
+	accounts []account.Account
+}
+
+func (r *emailsStub) GetAllByEmail(email string) ([]account.Account, error) {
+	var found []account.Account
+	for _, a := range r.accounts {
+		if a.Email == email {
+			found = append(found, a)
+		}
+	}
+	return found, nil
+}
+
+type resetsStub struct {
+	repository.Password
+	created []int
+}
+
+func (r *resetsStub) CreateReset(userID int, tokenHash string, expires time.Time) error {
+	r.created = append(r.created, userID)
+	return nil
+}
+
+type brokenMailer struct {
+	sent int
+}
+
+func (m *brokenMailer) Send(msg mail.Message) error {
+	m.sent++
+	return errors.New("smtp: connection refused")
+}
+
+func TestPasswordResetDoesNotRevealAccountsWhenMailFails(t *testing.T) {
+	accounts := &emailsStub{accounts: []account.Account{
+		{ID: 1, Email: "ivanov@bmstu.ru"},
+		{ID: 2, Email: "ivanov@bmstu.ru"},
+	}}
+	resets := &resetsStub{}
+	mailer := &brokenMailer{}
+	s := NewService(accounts, nil, nil, resets, nil, nil, nil, nil, mailer,
+		session.JWT{}, session.PasswordReset{TTL: time.Hour}, session.TwoFactor{}, session.Registration{}, session.Password{}, discardLogger())
+
+	if err := s.RequestPasswordReset("ivanov@bmstu.ru"); err != nil {
+		t.Fatalf("existing email: %v", err)
+	}
+	if err := s.RequestPasswordReset("nobody@bmstu.ru"); err != nil {
+		t.Fatalf("unknown email: %v", err)
+	}
+	if mailer.sent != 2 || len(resets.created) != 2 {
+		t.Fatalf("every account has to get a link, sent %d, created %v", mailer.sent, resets.created)
+	}
+}
+
+// sessionsStub keeps the created session and refuses it, so no token has to
+// be signed with the configured keys.
+type sessionsStub struct {
+	repository.Session
+	created account.Session
+}
+
+func (r *sessionsStub) Create(s *account.Session, refreshHash string) error {
+	r.created = *s
+	return &account.CanNotLoginErr{}
+}
+
+func TestImpersonationSessionIsShort(t *testing.T) {
+	sessions := &sessionsStub{}
+	cfg := session.JWT{RefreshTTL: 720 * time.Hour, ImpersonationTTL: 15 * time.Minute}
+	s := NewService(nil, nil, sessions, nil, nil, nil, nil, nil, nil,
+		cfg, session.PasswordReset{}, session.TwoFactor{}, session.Registration{}, session.Password{}, discardLogger())
+
+	before := time.Now()
+	s.StartImpersonation(1, 2, account.Client{})
+	if sessions.created.ImpersonatorID == nil || *sessions.created.ImpersonatorID != 1 {
+		t.Fatalf("session is not marked with the admin: %+v", sessions.created)
+	}
+	if sessions.created.Expires.After(before.Add(time.Hour)) {
+		t.Errorf("impersonation session expires at %v", sessions.created.Expires)
+	}
+
+	s.StartSession(2, account.Client{})
+	if sessions.created.Expires.Before(before.Add(cfg.RefreshTTL)) {
+		t.Errorf("own session expires at %v", sessions.created.Expires)
+	}
+}
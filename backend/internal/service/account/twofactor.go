@@ -0,0 +1,155 @@
+package account
+
+import (
+	"errors"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/securetoken"
+	"reports_system/pkg/totp"
+	"time"
+)
+
+// VerifySecondFactor finishes a login started by GenerateJWT. The code is
+// either a current TOTP code or one of the unused recovery codes.
+func (s *Service) VerifySecondFactor(challenge, code string) (account.Tokens, account.Account, error) {
+	tokenHash := securetoken.Hash(challenge)
+
+	c, err := s.twoFactorRepository.AttemptChallenge(tokenHash)
+	if err != nil {
+		return account.Tokens{}, account.Account{}, err
+	}
+
+	a, err := s.repository.GetOne(c.UserID)
+	if err != nil {
+		return account.Tokens{}, account.Account{}, err
+	}
+	if err = s.limiter.Check(a.Username, c.Client.IP); err != nil {
+		return account.Tokens{}, account.Account{}, err
+	}
+
+	t, err := s.twoFactorRepository.GetTOTP(c.UserID)
+	if err != nil {
+		return account.Tokens{}, account.Account{}, err
+	}
+	if !t.Enabled || t.Secret == nil {
+		return account.Tokens{}, account.Account{}, &account.ChallengeExpiredErr{}
+	}
+
+	if err = s.checkSecondFactor(c.UserID, *t.Secret, code); err != nil {
+		if errors.Is(err, &account.SecondFactorInvalidErr{}) {
+			s.limiter.Fail(a.Username, c.Client.IP)
+		}
+		return account.Tokens{}, account.Account{}, err
+	}
+	if err = s.twoFactorRepository.DeleteChallenge(tokenHash); err != nil {
+		return account.Tokens{}, account.Account{}, err
+	}
+
+	s.limiter.Succeed(a.Username)
+	tokens, err := s.StartSession(c.UserID, c.Client)
+	return tokens, a, err
+}
+
+// EnrollTOTP generates a new secret, it has to be confirmed with ConfirmTOTP
+// before logins start asking for codes.
+func (s *Service) EnrollTOTP(userID int) (account.Enrollment, error) {
+	a, err := s.repository.GetOne(userID)
+	if err != nil {
+		return account.Enrollment{}, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return account.Enrollment{}, err
+	}
+	if err = s.twoFactorRepository.SetPendingSecret(userID, secret); err != nil {
+		return account.Enrollment{}, err
+	}
+
+	return account.Enrollment{
+		Secret: secret,
+		URI:    totp.URI(s.twoFactorCfg.Issuer, a.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables the second factor and returns recovery codes. They are
+// stored hashed, so this is the only time the user sees them.
+func (s *Service) ConfirmTOTP(userID int, code string) ([]string, error) {
+	t, err := s.twoFactorRepository.GetTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if t.Enabled {
+		return nil, &account.TwoFactorAlreadyEnabledErr{}
+	}
+	if t.Secret == nil {
+		return nil, &account.TwoFactorNotEnrolledErr{}
+	}
+
+	counter, ok := totp.Validate(*t.Secret, code, time.Now())
+	if !ok {
+		return nil, &account.SecondFactorInvalidErr{}
+	}
+
+	codes, err := account.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = securetoken.Hash(account.NormalizeRecoveryCode(c))
+	}
+
+	if err = s.twoFactorRepository.Enable(userID, counter, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *Service) DisableTOTP(userID int, password string) error {
+	a, err := s.repository.GetCredentials(userID)
+	if err != nil {
+		return err
+	}
+	if err = a.CheckPassword(password); err != nil {
+		return err
+	}
+	return s.twoFactorRepository.Disable(userID)
+}
+
+func (s *Service) checkSecondFactor(userID int, secret, code string) error {
+	var (
+		ok  bool
+		err error
+	)
+
+	if counter, valid := totp.Validate(secret, code, time.Now()); valid {
+		ok, err = s.twoFactorRepository.UseCounter(userID, counter)
+	} else {
+		ok, err = s.twoFactorRepository.UseRecoveryCode(userID, securetoken.Hash(account.NormalizeRecoveryCode(code)))
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &account.SecondFactorInvalidErr{}
+	}
+	return nil
+}
+
+func (s *Service) startChallenge(userID int, client account.Client) (account.Tokens, error) {
+	token, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return account.Tokens{}, err
+	}
+
+	c := account.Challenge{
+		UserID:  userID,
+		Client:  client,
+		Expires: time.Now().Add(s.twoFactorCfg.ChallengeTTL),
+	}
+	if err = s.twoFactorRepository.CreateChallenge(securetoken.Hash(token), c); err != nil {
+		return account.Tokens{}, err
+	}
+
+	return account.Tokens{Challenge: token}, nil
+}
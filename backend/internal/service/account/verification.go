@@ -0,0 +1,56 @@
+package account
+
+import (
+	"fmt"
+	"reports_system/internal/model/account"
+	"reports_system/pkg/mail"
+	"reports_system/pkg/securetoken"
+	"time"
+)
+
+func (s *Service) VerifyEmail(token string) error {
+	return s.verificationsRepository.Verify(securetoken.Hash(token))
+}
+
+// ResendVerification mails a new link to unverified accounts with the email.
+// Like RequestPasswordReset it doesn't tell whether such accounts exist.
+func (s *Service) ResendVerification(email string) error {
+	accounts, err := s.repository.GetAllByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range accounts {
+		if a.EmailVerified {
+			continue
+		}
+		if err = s.SendVerification(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendVerification mails a new verification link, older links stop working.
+func (s *Service) SendVerification(a account.Account) error {
+	token, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(s.registrationCfg.VerificationTTL)
+	if err = s.verificationsRepository.Create(a.ID, securetoken.Hash(token), expires); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      a.Email,
+		Subject: "Email verification",
+		Body: fmt.Sprintf(
+			"Hello, %s!\r\n\r\n"+
+				"Follow the link to verify the email of account %s, it is valid for %v:\r\n\r\n%s\r\n\r\n"+
+				"If you didn't register, just ignore this email.\r\n",
+			a.Name, a.Username, s.registrationCfg.VerificationTTL, linkWithToken(s.registrationCfg.VerifyURL, token),
+		),
+	})
+}
@@ -0,0 +1,242 @@
+package admin
+
+import (
+	"fmt"
+	"reports_system/internal/model/account"
+	"reports_system/internal/model/report"
+	"reports_system/internal/repository"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/securetoken"
+	"time"
+)
+
+// accountManager is implemented by the account service.
+type accountManager interface {
+	SendPasswordReset(a account.Account) error
+	SendInvitation(i account.Invitation, token string) error
+	StartImpersonation(adminID, userID int, client account.Client) (account.Tokens, error)
+}
+
+// Service lets admins manage accounts of others in their organization. Every
+// change is written to the audit log.
+type Service struct {
+	accountsRepository    repository.Account
+	adminRepository       repository.Admin
+	invitationsRepository repository.Invitation
+	accounts              accountManager
+	logger                logging.Logger
+}
+
+func NewService(
+	accountsRepository repository.Account,
+	adminRepository repository.Admin,
+	invitationsRepository repository.Invitation,
+	accounts accountManager,
+	logger logging.Logger,
+) *Service {
+	return &Service{
+		accountsRepository:    accountsRepository,
+		adminRepository:       adminRepository,
+		invitationsRepository: invitationsRepository,
+		accounts:              accounts,
+		logger:                logger,
+	}
+}
+
+// Bootstrap grants the admin role to the usernames from the config, so the
+// first admin can be appointed without touching the database.
+func (s *Service) Bootstrap(usernames []string) {
+	for _, username := range usernames {
+		a, err := s.accountsRepository.GetOneByUsername(username)
+		if err != nil {
+			s.logger.Infof("admin %q is not bootstrapped due to error %v", username, err)
+			continue
+		}
+		if a.Role == account.RoleAdmin {
+			continue
+		}
+		if err = s.adminRepository.SetRole(a.ID, account.RoleAdmin); err != nil {
+			s.logger.Error(err)
+			continue
+		}
+		s.audit(nil, account.AuditSetRole, a.ID, "bootstrapped from config as "+account.RoleAdmin)
+	}
+}
+
+func (s *Service) GetAll(q account.DirectoryQuery) ([]account.Account, error) {
+	q.Normalize()
+	return s.adminRepository.GetAll(q)
+}
+
+func (s *Service) Deactivate(adminID, userID int) error {
+	if adminID == userID {
+		return &account.OwnAccountErr{}
+	}
+	if _, err := s.target(adminID, userID); err != nil {
+		return err
+	}
+	if err := s.adminRepository.SetDeactivated(userID, true); err != nil {
+		return err
+	}
+	s.audit(&adminID, account.AuditDeactivate, userID, "")
+	return nil
+}
+
+func (s *Service) Reactivate(adminID, userID int) error {
+	if _, err := s.target(adminID, userID); err != nil {
+		return err
+	}
+	if err := s.adminRepository.SetDeactivated(userID, false); err != nil {
+		return err
+	}
+	s.audit(&adminID, account.AuditReactivate, userID, "")
+	return nil
+}
+
+// ForcePasswordReset invalidates the password and mails a reset link to the user.
+func (s *Service) ForcePasswordReset(adminID, userID int) error {
+	a, err := s.target(adminID, userID)
+	if err != nil {
+		return err
+	}
+	if err = s.adminRepository.ForcePasswordReset(userID); err != nil {
+		return err
+	}
+	s.audit(&adminID, account.AuditPasswordReset, userID, "")
+
+	return s.accounts.SendPasswordReset(a)
+}
+
+func (s *Service) SetRole(adminID, userID int, role string) error {
+	if !account.ValidRole(role) {
+		return &account.UnknownRoleErr{}
+	}
+	if adminID == userID && role != account.RoleAdmin {
+		return &account.OwnAccountErr{}
+	}
+	if _, err := s.target(adminID, userID); err != nil {
+		return err
+	}
+	if err := s.adminRepository.SetRole(userID, role); err != nil {
+		return err
+	}
+	s.audit(&adminID, account.AuditSetRole, userID, role)
+	return nil
+}
+
+// SetClearance changes which report classifications the account can see,
+// lowering it hides the reports above the new level right away.
+func (s *Service) SetClearance(adminID, userID int, clearance string) error {
+	if report.ClassificationLevel(clearance) < 0 {
+		return &report.UnknownClassificationErr{}
+	}
+	if _, err := s.target(adminID, userID); err != nil {
+		return err
+	}
+	if err := s.adminRepository.SetClearance(userID, clearance); err != nil {
+		return err
+	}
+	s.audit(&adminID, account.AuditSetClearance, userID, clearance)
+	return nil
+}
+
+// Impersonate issues tokens of the user to the admin, e.g. to reproduce a
+// problem the user reports. Admins and deactivated accounts can't be impersonated.
+func (s *Service) Impersonate(adminID, userID int, client account.Client) (account.Tokens, account.Account, error) {
+	if adminID == userID {
+		return account.Tokens{}, account.Account{}, &account.OwnAccountErr{}
+	}
+
+	a, err := s.target(adminID, userID)
+	if err != nil {
+		return account.Tokens{}, a, err
+	}
+	if a.Role == account.RoleAdmin || a.Deactivated != nil {
+		return account.Tokens{}, a, &account.CanNotImpersonateErr{}
+	}
+
+	// the audit record goes first, there must be no impersonation without a trace
+	details := fmt.Sprintf("ip %s, user agent %q", client.IP, client.UserAgent)
+	if err = s.adminRepository.Audit(auditEntry(&adminID, account.AuditImpersonate, userID, details)); err != nil {
+		return account.Tokens{}, a, err
+	}
+
+	tokens, err := s.accounts.StartImpersonation(adminID, userID, client)
+	return tokens, a, err
+}
+
+func (s *Service) GetAudit(organizationID, limit int) ([]account.AuditEntry, error) {
+	if limit <= 0 || limit > account.DirectoryMaxLimit {
+		limit = account.DirectoryMaxLimit
+	}
+	return s.adminRepository.GetAudit(organizationID, limit)
+}
+
+// Invite mails an invitation link to the email, the person registers with the
+// link and lands in the organization with the role and department chosen here.
+func (s *Service) Invite(adminID int, i *account.Invitation) error {
+	if err := i.Validate(time.Now()); err != nil {
+		return err
+	}
+
+	token, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return err
+	}
+	i.CreatedBy = &adminID
+	if err = s.invitationsRepository.Create(i, securetoken.Hash(token)); err != nil {
+		return err
+	}
+	s.auditInvitation(adminID, account.AuditInvite, i.OrganizationID,
+		fmt.Sprintf("invitation %d for %s as %s, department %q", i.ID, i.Email, i.Role, i.Department))
+
+	return s.accounts.SendInvitation(*i, token)
+}
+
+func (s *Service) GetInvitations(organizationID int) ([]account.Invitation, error) {
+	return s.invitationsRepository.GetAllPending(organizationID)
+}
+
+func (s *Service) RevokeInvitation(adminID, organizationID, invitationID int) error {
+	if err := s.invitationsRepository.Revoke(organizationID, invitationID); err != nil {
+		return err
+	}
+	s.auditInvitation(adminID, account.AuditRevokeInvite, organizationID, fmt.Sprintf("invitation %d", invitationID))
+	return nil
+}
+
+// target finds the account an admin acts on. Accounts of other organizations
+// are reported as missing, an admin can't even tell they exist.
+func (s *Service) target(adminID, userID int) (account.Account, error) {
+	admin, err := s.accountsRepository.GetOne(adminID)
+	if err != nil {
+		return account.Account{}, err
+	}
+	a, err := s.accountsRepository.GetOne(userID)
+	if err != nil {
+		return a, err
+	}
+	if a.OrganizationID != admin.OrganizationID {
+		return account.Account{}, &account.AccountNotFoundErr{}
+	}
+	return a, nil
+}
+
+// audit only logs failures, the action itself has already happened.
+func (s *Service) audit(adminID *int, action string, targetID int, details string) {
+	if err := s.adminRepository.Audit(auditEntry(adminID, action, targetID, details)); err != nil {
+		s.logger.Error(fmt.Errorf("failed to audit %s of account %d due to error %w", action, targetID, err))
+	}
+}
+
+// auditInvitation is audit for invitations, there is no account to target yet.
+func (s *Service) auditInvitation(adminID int, action string, organizationID int, details string) {
+	entry := account.AuditEntry{AdminID: &adminID, Action: action, OrganizationID: organizationID, Details: details}
+	if err := s.adminRepository.Audit(entry); err != nil {
+		s.logger.Error(fmt.Errorf("failed to audit %s due to error %w", action, err))
+	}
+}
+
+func auditEntry(adminID *int, action string, targetID int, details string) account.AuditEntry {
+	return account.AuditEntry{AdminID: adminID, Action: action, TargetID: &targetID, Details: details}
+}
@@ -0,0 +1,52 @@
+package apikey
+
+import (
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/securetoken"
+	"time"
+)
+
+type Service struct {
+	repository repository.APIKey
+	cfg        session.APIKeys
+	logger     logging.Logger
+}
+
+func NewService(repository repository.APIKey, cfg session.APIKeys, logger logging.Logger) *Service {
+	return &Service{repository: repository, cfg: cfg, logger: logger}
+}
+
+// Create returns the key itself, it can't be recovered later.
+func (s *Service) Create(k *account.APIKey) (string, error) {
+	if err := k.Validate(time.Now(), s.cfg); err != nil {
+		return "", err
+	}
+
+	token, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return "", err
+	}
+	key := account.APIKeyPrefix + token
+	k.Prefix = account.KeyPrefix(key)
+
+	if err = s.repository.Create(k, securetoken.Hash(key)); err != nil {
+		return "", err
+	}
+	s.logger.Infof("api key %v created for account %v with scopes %v", k.ID, k.UserID, k.Scopes)
+	return key, nil
+}
+
+func (s *Service) GetAll(userID int) ([]account.APIKey, error) {
+	return s.repository.GetAll(userID)
+}
+
+func (s *Service) Revoke(userID, keyID int) error {
+	return s.repository.Revoke(userID, keyID)
+}
+
+func (s *Service) CheckAPIKey(key string) (account.APIKeyState, error) {
+	return s.repository.State(securetoken.Hash(key))
+}
@@ -0,0 +1,126 @@
+package attachment
+
+import (
+	"errors"
+	"io"
+	"reports_system/internal/model/attachment"
+	"reports_system/internal/repository"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/storage"
+	"reports_system/pkg/textextract"
+	"strings"
+	"time"
+)
+
+const (
+	pollInterval = 30 * time.Second
+	staleAfter   = 10 * time.Minute
+
+	// maxTextLen bounds the number of search terms kept for one attachment
+	maxTextLen = 256 << 10
+)
+
+// Indexer extracts text from uploaded attachments in the background. Work is
+// claimed through the database, so every backend replica can run one.
+type Indexer struct {
+	attachmentsRepository repository.Attachment
+	storage               storage.Storage
+	wake                  chan struct{}
+	logger                logging.Logger
+}
+
+func NewIndexer(attachmentsRepository repository.Attachment, storage storage.Storage, logger logging.Logger) *Indexer {
+	return &Indexer{
+		attachmentsRepository: attachmentsRepository,
+		storage:               storage,
+		wake:                  make(chan struct{}, 1),
+		logger:                logger,
+	}
+}
+
+// Notify wakes the indexer up without waiting for the next poll.
+func (i *Indexer) Notify() {
+	select {
+	case i.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (i *Indexer) Run() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for i.indexNext() {
+		}
+
+		select {
+		case <-ticker.C:
+		case <-i.wake:
+		}
+	}
+}
+
+// indexNext processes a single attachment and reports whether there may be more work.
+func (i *Indexer) indexNext() bool {
+	a, err := i.attachmentsRepository.ClaimForIndexing(staleAfter)
+	if err != nil {
+		if !errors.Is(err, &attachment.AttachmentNotFoundErr{}) {
+			i.logger.Error(err)
+		}
+		return false
+	}
+
+	if !textextract.Supports(a.ContentType) {
+		i.save(a.ID, attachment.TextUnsupported, "")
+		return true
+	}
+
+	text, err := i.extract(a)
+	if err != nil {
+		i.logger.Errorf("failed to extract text from attachment %v: %v", a.ID, err)
+		i.save(a.ID, attachment.TextFailed, "")
+		return true
+	}
+
+	i.logger.Infof("Extracted %v bytes of text from attachment %v", len(text), a.ID)
+	i.save(a.ID, attachment.TextIndexed, text)
+	return true
+}
+
+func (i *Indexer) extract(a attachment.Attachment) (string, error) {
+	blob, err := i.storage.Get(a.StorageKey)
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := textextract.Extract(data, a.ContentType)
+	if err != nil {
+		return "", err
+	}
+
+	return sanitizeText(text), nil
+}
+
+func (i *Indexer) save(attachmentID int, status, text string) {
+	if err := i.attachmentsRepository.SaveText(attachmentID, status, text); err != nil {
+		i.logger.Error(err)
+	}
+}
+
+// sanitizeText drops what postgres refuses to store in a text column
+// and cuts the text to maxTextLen without breaking a utf-8 sequence.
+func sanitizeText(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.ReplaceAll(text, "\x00", "")
+	if len(text) > maxTextLen {
+		text = strings.ToValidUTF8(text[:maxTextLen], "")
+	}
+	return text
+}
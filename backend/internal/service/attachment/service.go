@@ -0,0 +1,163 @@
+package attachment
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reports_system/internal/model/attachment"
+	"reports_system/internal/model/report"
+	"reports_system/internal/repository"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/storage"
+)
+
+const (
+	storageKeyLen = 16
+)
+
+type Service struct {
+	attachmentsRepository repository.Attachment
+	reportsRepository     repository.Report
+	storage               storage.Storage
+	indexer               *Indexer
+	maxSize               int64
+	logger                logging.Logger
+}
+
+func NewService(
+	attachmentsRepository repository.Attachment,
+	reportsRepository repository.Report,
+	storage storage.Storage,
+	indexer *Indexer,
+	maxSize int64,
+	logger logging.Logger,
+) *Service {
+	return &Service{
+		attachmentsRepository: attachmentsRepository,
+		reportsRepository:     reportsRepository,
+		storage:               storage,
+		indexer:               indexer,
+		maxSize:               maxSize,
+		logger:                logger,
+	}
+}
+
+func (s *Service) Upload(userID, reportID int, a *attachment.Attachment, r io.Reader) error {
+	if err := s.checkEditable(userID, reportID); err != nil {
+		return err
+	}
+
+	// the size given by the client is not trusted, the body is read up to one
+	// byte over the limit to tell a file of exactly the limit from a larger one
+	data, err := io.ReadAll(io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > s.maxSize {
+		return &attachment.AttachmentTooLargeErr{}
+	}
+	a.Size = int64(len(data))
+
+	head := data
+	if len(head) > attachment.SniffLen {
+		head = head[:attachment.SniffLen]
+	}
+
+	a.SniffContentType(head)
+	if !a.HasAllowedContentType() {
+		s.logger.Infof("Rejected attachment %v with content type %v", a.Name, a.ContentType)
+		return &attachment.ContentTypeNotAllowedErr{}
+	}
+
+	key, err := generateStorageKey(reportID)
+	if err != nil {
+		return err
+	}
+	a.ReportID = reportID
+	a.StorageKey = key
+
+	if err = s.storage.Put(key, bytes.NewReader(data), a.Size, a.ContentType); err != nil {
+		s.logger.Error(err)
+		return &attachment.CanNotCreateAttachmentErr{}
+	}
+
+	if err = s.attachmentsRepository.Create(a); err != nil {
+		if err := s.storage.Delete(key); err != nil {
+			s.logger.Error(err)
+		}
+		return err
+	}
+
+	s.indexer.Notify()
+	return nil
+}
+
+func (s *Service) GetAll(userID, reportID int) ([]attachment.Attachment, error) {
+	if _, err := s.reportsRepository.GetOne(userID, reportID); err != nil {
+		return nil, err
+	}
+	return s.attachmentsRepository.GetAll(userID, reportID)
+}
+
+func (s *Service) Download(userID, reportID, attachmentID int) (attachment.Attachment, io.ReadCloser, error) {
+	a, err := s.attachmentsRepository.GetOne(userID, reportID, attachmentID)
+	if err != nil {
+		return a, nil, err
+	}
+
+	blob, err := s.storage.Get(a.StorageKey)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, storage.ErrNotFound) {
+			return a, nil, &attachment.AttachmentNotFoundErr{}
+		}
+		return a, nil, err
+	}
+
+	return a, blob, nil
+}
+
+func (s *Service) Delete(userID, reportID, attachmentID int) error {
+	if err := s.checkEditable(userID, reportID); err != nil {
+		return err
+	}
+
+	a, err := s.attachmentsRepository.GetOne(userID, reportID, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.attachmentsRepository.Delete(userID, reportID, attachmentID); err != nil {
+		return err
+	}
+
+	if err = s.storage.Delete(a.StorageKey); err != nil {
+		// metadata is gone already, the blob is only an orphan now
+		s.logger.Error(err)
+	}
+	return nil
+}
+
+// checkEditable lets attachments change only on reports the user sees and
+// that aren't finalized yet.
+func (s *Service) checkEditable(userID, reportID int) error {
+	n, err := s.reportsRepository.GetOne(userID, reportID)
+	if err != nil {
+		return err
+	}
+	if n.Status == report.StatusFinalized {
+		return &report.AlreadyFinalizedErr{}
+	}
+	return nil
+}
+
+func generateStorageKey(reportID int) (string, error) {
+	b := make([]byte, storageKeyLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("reports/%d/%s", reportID, hex.EncodeToString(b)), nil
+}
@@ -0,0 +1,250 @@
+package attachment
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reports_system/internal/model/attachment"
+	"reports_system/internal/model/report"
+	"reports_system/internal/repository"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/storage"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ownerID    = 1
+	strangerID = 2
+	reportID   = 10
+	maxSize    = 1024
+)
+
+// memoryStorage stands in for the local and s3 storages.
+type memoryStorage struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	sizes map[string]int64
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{blobs: map[string][]byte{}, sizes: map[string]int64{}}
+}
+
+func (m *memoryStorage) Put(key string, r io.Reader, size int64, _ string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = data
+	m.sizes[key] = size
+	return nil
+}
+
+func (m *memoryStorage) Get(key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryStorage) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, key)
+	return nil
+}
+
+type reportsStub struct {
+	repository.Report
+	status string
+}
+
+func (r *reportsStub) GetOne(userID, id int) (report.Report, error) {
+	if userID != ownerID || id != reportID {
+		return report.Report{}, &report.ReportNotFoundErr{}
+	}
+	return report.Report{ID: id, Status: r.status}, nil
+}
+
+type attachmentsStub struct {
+	repository.Attachment
+	rows      map[int]attachment.Attachment
+	createErr error
+}
+
+func (r *attachmentsStub) Create(a *attachment.Attachment) error {
+	if r.createErr != nil {
+		return r.createErr
+	}
+	a.ID = len(r.rows) + 1
+	r.rows[a.ID] = *a
+	return nil
+}
+
+func (r *attachmentsStub) GetOne(userID, id, attachmentID int) (attachment.Attachment, error) {
+	a, ok := r.rows[attachmentID]
+	if !ok || userID != ownerID || a.ReportID != id {
+		return a, &attachment.AttachmentNotFoundErr{}
+	}
+	return a, nil
+}
+
+func (r *attachmentsStub) Delete(userID, id, attachmentID int) error {
+	if _, err := r.GetOne(userID, id, attachmentID); err != nil {
+		return err
+	}
+	delete(r.rows, attachmentID)
+	return nil
+}
+
+func newTestService() (*Service, *attachmentsStub, *memoryStorage) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	l := logging.Logger{Entry: logrus.NewEntry(logger)}
+
+	attachments := &attachmentsStub{rows: map[int]attachment.Attachment{}}
+	blobs := newMemoryStorage()
+	indexer := NewIndexer(attachments, blobs, l)
+	return NewService(attachments, &reportsStub{}, blobs, indexer, maxSize, l), attachments, blobs
+}
+
+func TestUploadStoresActualSize(t *testing.T) {
+	s, _, blobs := newTestService()
+
+	body := strings.Repeat("протокол ", 50)
+	a := attachment.Attachment{Name: "notes.txt", Size: 1}
+	if err := s.Upload(ownerID, reportID, &a, strings.NewReader(body)); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Size != int64(len(body)) {
+		t.Errorf("size = %d, want %d", a.Size, len(body))
+	}
+	if blobs.sizes[a.StorageKey] != int64(len(body)) {
+		t.Errorf("storage got size %d, want %d", blobs.sizes[a.StorageKey], len(body))
+	}
+	if string(blobs.blobs[a.StorageKey]) != body {
+		t.Error("stored blob differs from the upload")
+	}
+}
+
+func TestUploadRejectsBodyOverLimit(t *testing.T) {
+	s, attachments, blobs := newTestService()
+
+	// the client claims a small file but sends more than the limit
+	a := attachment.Attachment{Name: "big.txt", Size: 10}
+	err := s.Upload(ownerID, reportID, &a, strings.NewReader(strings.Repeat("a", maxSize+1)))
+	if !errors.Is(err, &attachment.AttachmentTooLargeErr{}) {
+		t.Fatalf("err = %v, want AttachmentTooLargeErr", err)
+	}
+	if len(blobs.blobs) != 0 || len(attachments.rows) != 0 {
+		t.Error("rejected upload was stored")
+	}
+
+	exact := attachment.Attachment{Name: "exact.txt"}
+	if err = s.Upload(ownerID, reportID, &exact, strings.NewReader(strings.Repeat("a", maxSize))); err != nil {
+		t.Fatalf("file of exactly the limit: %v", err)
+	}
+}
+
+func TestUploadRejectsDisallowedContentType(t *testing.T) {
+	s, _, blobs := newTestService()
+
+	a := attachment.Attachment{Name: "page.txt"}
+	err := s.Upload(ownerID, reportID, &a, strings.NewReader("<html><script>alert(1)</script></html>"))
+	if !errors.Is(err, &attachment.ContentTypeNotAllowedErr{}) {
+		t.Fatalf("err = %v, want ContentTypeNotAllowedErr", err)
+	}
+	if len(blobs.blobs) != 0 {
+		t.Error("rejected upload was stored")
+	}
+}
+
+func TestUploadToForeignReport(t *testing.T) {
+	s, _, blobs := newTestService()
+
+	a := attachment.Attachment{Name: "notes.txt"}
+	err := s.Upload(strangerID, reportID, &a, strings.NewReader("text"))
+	if !errors.Is(err, &report.ReportNotFoundErr{}) {
+		t.Fatalf("err = %v, want ReportNotFoundErr", err)
+	}
+	if len(blobs.blobs) != 0 {
+		t.Error("upload to a foreign report was stored")
+	}
+}
+
+func TestUploadRemovesBlobWhenMetadataFails(t *testing.T) {
+	s, attachments, blobs := newTestService()
+	attachments.createErr = errors.New("db is down")
+
+	a := attachment.Attachment{Name: "notes.txt"}
+	if err := s.Upload(ownerID, reportID, &a, strings.NewReader("text")); err == nil {
+		t.Fatal("upload succeeded without metadata")
+	}
+	if len(blobs.blobs) != 0 {
+		t.Error("orphaned blob left in storage")
+	}
+}
+
+func TestDownloadAndDelete(t *testing.T) {
+	s, _, blobs := newTestService()
+
+	a := attachment.Attachment{Name: "notes.txt"}
+	if err := s.Upload(ownerID, reportID, &a, strings.NewReader("text of the notes")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.Download(strangerID, reportID, a.ID); !errors.Is(err, &attachment.AttachmentNotFoundErr{}) {
+		t.Fatalf("stranger download err = %v, want AttachmentNotFoundErr", err)
+	}
+
+	got, blob, err := s.Download(ownerID, reportID, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(blob)
+	blob.Close()
+	if string(data) != "text of the notes" || got.ContentType != a.ContentType {
+		t.Errorf("downloaded %q with type %q", data, got.ContentType)
+	}
+
+	if err = s.Delete(ownerID, reportID, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs.blobs) != 0 {
+		t.Error("blob is still in storage after delete")
+	}
+	if _, _, err = s.Download(ownerID, reportID, a.ID); !errors.Is(err, &attachment.AttachmentNotFoundErr{}) {
+		t.Fatalf("download after delete err = %v, want AttachmentNotFoundErr", err)
+	}
+}
+
+func TestFinalizedReportKeepsItsAttachments(t *testing.T) {
+	s, attachments, blobs := newTestService()
+
+	a := attachment.Attachment{Name: "notes.txt"}
+	if err := s.Upload(ownerID, reportID, &a, strings.NewReader("text of the notes")); err != nil {
+		t.Fatal(err)
+	}
+	s.reportsRepository.(*reportsStub).status = report.StatusFinalized
+
+	late := attachment.Attachment{Name: "late.txt"}
+	if err := s.Upload(ownerID, reportID, &late, strings.NewReader("added after")); !errors.Is(err, &report.AlreadyFinalizedErr{}) {
+		t.Fatalf("upload err = %v, want AlreadyFinalizedErr", err)
+	}
+	if err := s.Delete(ownerID, reportID, a.ID); !errors.Is(err, &report.AlreadyFinalizedErr{}) {
+		t.Fatalf("delete err = %v, want AlreadyFinalizedErr", err)
+	}
+	if len(attachments.rows) != 1 || len(blobs.blobs) != 1 {
+		t.Errorf("finalized report has %d attachments and %d blobs, want 1 and 1", len(attachments.rows), len(blobs.blobs))
+	}
+}
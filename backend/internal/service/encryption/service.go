@@ -0,0 +1,96 @@
+package encryption
+
+import (
+	"errors"
+	"reports_system/internal/repository"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/storage"
+)
+
+const batchSize = 100
+
+// blobRewrapper is implemented by storage.Encrypted.
+type blobRewrapper interface {
+	Rewrap(key, contentType string) (bool, error)
+}
+
+// Service re-wraps data keys after the master key has been rotated.
+type Service struct {
+	reportsRepository     repository.Report
+	attachmentsRepository repository.Attachment
+	blobs                 blobRewrapper
+	logger                logging.Logger
+}
+
+func NewService(
+	reportsRepository repository.Report,
+	attachmentsRepository repository.Attachment,
+	blobs blobRewrapper,
+	logger logging.Logger,
+) *Service {
+	return &Service{
+		reportsRepository:     reportsRepository,
+		attachmentsRepository: attachmentsRepository,
+		blobs:                 blobs,
+		logger:                logger,
+	}
+}
+
+// RotateKeys makes everything sealed with older master keys readable with the
+// current one alone. It may be run again after a failure, what is done already
+// is skipped.
+func (s *Service) RotateKeys() error {
+	if err := s.rotateReports(); err != nil {
+		return err
+	}
+	return s.rotateAttachments()
+}
+
+func (s *Service) rotateReports() error {
+	var afterID, total int
+	for {
+		lastID, rewrapped, err := s.reportsRepository.RewrapKeys(afterID, batchSize)
+		total += rewrapped
+		if err != nil {
+			return err
+		}
+		if lastID == 0 {
+			break
+		}
+		afterID = lastID
+	}
+
+	s.logger.Infof("Rewrapped data keys of %v reports", total)
+	return nil
+}
+
+func (s *Service) rotateAttachments() error {
+	var afterID, total int
+	for {
+		attachments, err := s.attachmentsRepository.GetAllAfter(afterID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(attachments) == 0 {
+			break
+		}
+
+		for _, a := range attachments {
+			afterID = a.ID
+			rewrapped, err := s.blobs.Rewrap(a.StorageKey, a.ContentType)
+			if errors.Is(err, storage.ErrNotFound) {
+				s.logger.Errorf("Blob of attachment %v is missing", a.ID)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if rewrapped {
+				total++
+			}
+		}
+	}
+
+	s.logger.Infof("Rewrapped data keys of %v attachments", total)
+	return nil
+}
@@ -3,6 +3,7 @@ package label
 import (
 	"errors"
 	"reports_system/internal/model/label"
+	"reports_system/internal/model/report"
 	"reports_system/internal/repository"
 	"reports_system/pkg/logging"
 	"strings"
@@ -19,33 +20,50 @@ func NewService(tr repository.Label, nr repository.Report, l logging.Logger) *Se
 }
 
 func (s *Service) Create(userID, reportID int, t *label.Label) error {
-	_, err := s.reportsRepository.GetOne(userID, reportID)
+	n, err := s.reportsRepository.GetOne(userID, reportID)
 	if err != nil {
 		return errors.New("report does not exists or does not belong to accounts")
 	}
+	if n.Status == report.StatusFinalized {
+		return &report.AlreadyFinalizedErr{}
+	}
 
 	labels, err := s.labelsRepository.GetAll(userID)
 	if err != nil {
 		return err
 	}
 
+	if err = t.Validate(); err != nil {
+		return err
+	}
+
 	unique, tuID := s.checkIfUnique(labels, *t)
 	if !unique {
 		s.logger.Infof("Label with ID %v is not unique", tuID)
+		// the existing label is shared by other reports, settings given here
+		// would either be lost or change them behind the user's back
+		existing := labelByID(labels, tuID)
+		if !t.SameAs(existing) {
+			return &label.LabelExistsErr{}
+		}
+		*t = existing
+
 		assigned, err := s.checkIfAssigned(tuID, reportID, userID)
 		if err != nil {
 			return err
 		}
 		if !assigned {
 			s.logger.Infof("Label with ID %v is not assigned to report %v", tuID, reportID)
-			t.ID = tuID
 			err := s.labelsRepository.Assign(tuID, reportID, userID)
 			return err
 		}
-		t.ID = tuID
 		return nil
 	}
 
+	if t.ParentID != nil && !hasLabel(labels, *t.ParentID) {
+		return &label.LabelNotFoundErr{}
+	}
+
 	s.logger.Infof("Label with ID %v is inuque and will be assigned to report with ID %v", t.ID, reportID)
 	err = s.labelsRepository.Create(userID, reportID, t)
 	if err != nil {
@@ -71,24 +89,59 @@ func (s *Service) Delete(userID, labelID int) error {
 	return s.labelsRepository.Delete(userID, labelID)
 }
 
-func (s *Service) Update(userID, labelID int, t label.Label) error {
-	tp, err := s.labelsRepository.GetOne(userID, labelID)
+func (s *Service) Update(userID, labelID int, u label.Update) error {
+	t, err := s.labelsRepository.GetOne(userID, labelID)
 	if err != nil {
 		return err
 	}
 
-	if t.Name == "" {
-		t.Name = tp.Name
+	t.Apply(u)
+	if err = t.Validate(); err != nil {
+		return err
+	}
+
+	labels, err := s.labelsRepository.GetAll(userID)
+	if err != nil {
+		return err
+	}
+	if nameTaken(labels, t) {
+		return &label.LabelNameTakenErr{}
 	}
 
 	return s.labelsRepository.Update(userID, labelID, t)
 }
 
+// Move puts the label under another one of the user's labels, or to the top
+// when parentID is nil.
+func (s *Service) Move(userID, labelID int, parentID *int) error {
+	labels, err := s.labelsRepository.GetAll(userID)
+	if err != nil {
+		return err
+	}
+
+	if !hasLabel(labels, labelID) || parentID != nil && !hasLabel(labels, *parentID) {
+		return &label.LabelNotFoundErr{}
+	}
+
+	t := labelByID(labels, labelID)
+	t.ParentID = parentID
+	if nameTaken(labels, t) {
+		return &label.LabelNameTakenErr{}
+	}
+
+	return s.labelsRepository.Move(userID, labelID, parentID)
+}
+
+// Detach unlinks the label from the report and deletes the label once no
+// report uses it, unless other labels are nested under it.
 func (s *Service) Detach(userID, labelID, reportID int) error {
-	_, err := s.reportsRepository.GetOne(userID, reportID)
+	n, err := s.reportsRepository.GetOne(userID, reportID)
 	if err != nil {
 		return errors.New("report does not exists or does not belong to accounts")
 	}
+	if n.Status == report.StatusFinalized {
+		return &report.AlreadyFinalizedErr{}
+	}
 
 	ns, err := s.reportsRepository.GetAll(userID)
 	if err != nil {
@@ -96,20 +149,35 @@ func (s *Service) Detach(userID, labelID, reportID int) error {
 	}
 
 	t, err := s.labelsRepository.GetOne(userID, labelID)
-	s.logger.Infof("Found label %v: %v, %v", labelID, t.Name)
+	s.logger.Infof("Found label %v: %v", labelID, t.Name)
 	if err != nil {
 		return err
 	}
 
+	if err = s.labelsRepository.Detach(userID, labelID, reportID); err != nil {
+		return err
+	}
+
+	labels, err := s.labelsRepository.GetAll(userID)
+	if err != nil {
+		return err
+	}
+	if hasChildren(labels, labelID) {
+		s.logger.Infof("Label %v has nested labels and is kept", labelID)
+		return nil
+	}
+
 	for _, n := range ns {
+		if n.ID == reportID {
+			continue
+		}
 		n.Labels, err = s.labelsRepository.GetAllByReport(userID, n.ID)
 		if err != nil {
 			return err
 		}
-		if n.HasSpecificLabel(t.Name) && n.ID != reportID {
+		if hasLabel(n.Labels, labelID) {
 			s.logger.Infof("Found this label at report %v", n.ID)
-			err = s.labelsRepository.Detach(userID, labelID, reportID)
-			return err
+			return nil
 		}
 	}
 
@@ -120,7 +188,7 @@ func (s *Service) Detach(userID, labelID, reportID int) error {
 
 func (s *Service) checkIfUnique(labels []label.Label, tu label.Label) (bool, int) {
 	for _, t := range labels {
-		if strings.Compare(t.Name, tu.Name) == 0 {
+		if strings.Compare(t.Name, tu.Name) == 0 && t.SameParent(tu) {
 			s.logger.Infof("Found matching label with id %v", t.ID)
 			return false, t.ID
 		}
@@ -142,3 +210,41 @@ func (s *Service) checkIfAssigned(labelID, reportID, userID int) (bool, error) {
 	}
 	return false, nil
 }
+
+func labelByID(labels []label.Label, labelID int) label.Label {
+	for _, t := range labels {
+		if t.ID == labelID {
+			return t
+		}
+	}
+	return label.Label{}
+}
+
+// nameTaken tells whether another of the labels has the name of t under the
+// same parent.
+func nameTaken(labels []label.Label, t label.Label) bool {
+	for _, o := range labels {
+		if o.ID != t.ID && o.Name == t.Name && o.SameParent(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasChildren(labels []label.Label, labelID int) bool {
+	for _, t := range labels {
+		if t.ParentID != nil && *t.ParentID == labelID {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLabel(labels []label.Label, labelID int) bool {
+	for _, t := range labels {
+		if t.ID == labelID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,329 @@
+package label
+
+import (
+	"errors"
+	"io"
+	"reports_system/internal/model/label"
+	"reports_system/internal/model/report"
+	"reports_system/internal/repository"
+	"reports_system/pkg/logging"
+	"sort"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type labelsStub struct {
+	repository.Label
+	labels   map[int]label.Label
+	assigned map[int][]int
+}
+
+func newLabelsStub(labels ...label.Label) *labelsStub {
+	l := &labelsStub{labels: map[int]label.Label{}, assigned: map[int][]int{}}
+	for _, t := range labels {
+		l.labels[t.ID] = t
+	}
+	return l
+}
+
+func (l *labelsStub) Create(_, _ int, t *label.Label) error {
+	t.ID = len(l.labels) + 1
+	l.labels[t.ID] = *t
+	return nil
+}
+
+func (l *labelsStub) GetAll(_ int) ([]label.Label, error) {
+	labels := make([]label.Label, 0, len(l.labels))
+	for _, t := range l.labels {
+		labels = append(labels, t)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].ID < labels[j].ID })
+	return labels, nil
+}
+
+func (l *labelsStub) GetAllByReport(_, reportID int) ([]label.Label, error) {
+	labels := make([]label.Label, 0)
+	for _, id := range l.assigned[reportID] {
+		labels = append(labels, l.labels[id])
+	}
+	return labels, nil
+}
+
+func (l *labelsStub) GetOne(_, labelID int) (label.Label, error) {
+	t, ok := l.labels[labelID]
+	if !ok {
+		return t, &label.LabelNotFoundErr{}
+	}
+	return t, nil
+}
+
+func (l *labelsStub) Assign(labelID, reportID, _ int) error {
+	l.assigned[reportID] = append(l.assigned[reportID], labelID)
+	return nil
+}
+
+func (l *labelsStub) Detach(_, labelID, reportID int) error {
+	kept := make([]int, 0, len(l.assigned[reportID]))
+	for _, id := range l.assigned[reportID] {
+		if id != labelID {
+			kept = append(kept, id)
+		}
+	}
+	l.assigned[reportID] = kept
+	return nil
+}
+
+func (l *labelsStub) Delete(_, labelID int) error {
+	delete(l.labels, labelID)
+	return nil
+}
+
+func (l *labelsStub) Update(_, labelID int, t label.Label) error {
+	t.ID = labelID
+	l.labels[labelID] = t
+	return nil
+}
+
+// movesStub records whether the label got to the repository to be moved.
+type movesStub struct {
+	*labelsStub
+	moved bool
+}
+
+func (m *movesStub) Move(_, _ int, _ *int) error {
+	m.moved = true
+	return nil
+}
+
+type reportsStub struct {
+	repository.Report
+	finalized map[int]bool
+}
+
+func (r *reportsStub) GetAll(_ int) ([]report.Report, error) {
+	return []report.Report{{ID: 7}, {ID: 8}}, nil
+}
+
+func (r *reportsStub) GetOne(_, reportID int) (report.Report, error) {
+	n := report.Report{ID: reportID}
+	if r.finalized[reportID] {
+		n.Status = report.StatusFinalized
+	}
+	return n, nil
+}
+
+func newTestService(labels repository.Label, finalized ...int) *Service {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	reports := &reportsStub{finalized: map[int]bool{}}
+	for _, id := range finalized {
+		reports.finalized[id] = true
+	}
+	return NewService(labels, reports, logging.Logger{Entry: logrus.NewEntry(l)})
+}
+
+func str(s string) *string {
+	return &s
+}
+
+var department = label.Label{ID: 1, Name: "кафедра", Color: "#1e90ff", Description: "ИУ7", Icon: "folder"}
+
+func TestUpdateChangesGivenFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		update label.Update
+		want   label.Label
+	}{
+		{
+			"nothing given",
+			label.Update{},
+			department,
+		},
+		{
+			"metadata cleared",
+			label.Update{Color: str(""), Description: str(""), Icon: str("")},
+			label.Label{ID: 1, Name: "кафедра"},
+		},
+		{
+			"some fields changed",
+			label.Update{Name: str("факультет"), Color: str("#FF0000")},
+			label.Label{ID: 1, Name: "факультет", Color: "#ff0000", Description: "ИУ7", Icon: "folder"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := newLabelsStub(department)
+			if err := newTestService(labels).Update(1, 1, tt.update); err != nil {
+				t.Fatal(err)
+			}
+			if got := labels.labels[1]; got != tt.want {
+				t.Errorf("label is %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateRejectsEmptyName(t *testing.T) {
+	labels := newLabelsStub(department)
+	if err := newTestService(labels).Update(1, 1, label.Update{Name: str("")}); err == nil {
+		t.Fatal("name of the label was removed")
+	}
+	if labels.labels[1] != department {
+		t.Errorf("label changed to %+v", labels.labels[1])
+	}
+}
+
+func TestCreateReusesLabelOfTheSameName(t *testing.T) {
+	for name, given := range map[string]label.Label{
+		"name only":     {Name: "кафедра"},
+		"same settings": {Name: "кафедра", Color: "#1E90FF", Icon: "folder"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			labels := newLabelsStub(department)
+			l := given
+			if err := newTestService(labels).Create(1, 7, &l); err != nil {
+				t.Fatal(err)
+			}
+			if l != department {
+				t.Errorf("got %+v, want the existing label %+v", l, department)
+			}
+			if len(labels.labels) != 1 || len(labels.assigned[7]) != 1 || labels.assigned[7][0] != 1 {
+				t.Errorf("labels are %+v, assigned %+v", labels.labels, labels.assigned)
+			}
+		})
+	}
+}
+
+func TestCreateRejectsOtherSettingsForExistingName(t *testing.T) {
+	for name, given := range map[string]label.Label{
+		"color":       {Name: "кафедра", Color: "#ff0000"},
+		"description": {Name: "кафедра", Description: "ИУ8"},
+		"icon":        {Name: "кафедра", Icon: "calendar"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			labels := newLabelsStub(department)
+			l := given
+			err := newTestService(labels).Create(1, 7, &l)
+			if !errors.Is(err, &label.LabelExistsErr{}) {
+				t.Fatalf("err = %v, want LabelExistsErr", err)
+			}
+			if labels.labels[1] != department || len(labels.assigned[7]) != 0 {
+				t.Errorf("labels are %+v, assigned %+v", labels.labels, labels.assigned)
+			}
+		})
+	}
+}
+
+func TestCreateNewLabel(t *testing.T) {
+	labels := newLabelsStub(department)
+	l := label.Label{Name: "семинар", Color: "#00AA00"}
+	if err := newTestService(labels).Create(1, 7, &l); err != nil {
+		t.Fatal(err)
+	}
+	if l.ID != 2 || labels.labels[2].Color != "#00aa00" || len(labels.assigned[7]) != 1 {
+		t.Errorf("got %+v, labels %+v, assigned %+v", l, labels.labels, labels.assigned)
+	}
+}
+
+func TestSameNameLivesUnderDifferentParents(t *testing.T) {
+	alpha, beta := 2, 3
+	labels := newLabelsStub(department,
+		label.Label{ID: alpha, Name: "alpha", ParentID: &department.ID},
+		label.Label{ID: beta, Name: "beta", ParentID: &department.ID},
+		label.Label{ID: 4, Name: "design", ParentID: &alpha},
+	)
+
+	l := label.Label{Name: "design", ParentID: &beta}
+	if err := newTestService(labels).Create(1, 7, &l); err != nil {
+		t.Fatal(err)
+	}
+	if l.ID == 4 || len(labels.labels) != 5 || *labels.labels[l.ID].ParentID != beta {
+		t.Errorf("got %+v, labels %+v", l, labels.labels)
+	}
+	if len(labels.assigned[7]) != 1 || labels.assigned[7][0] != l.ID {
+		t.Errorf("assigned %+v, want only the new label", labels.assigned)
+	}
+}
+
+func TestNamesStayUniqueAmongSiblings(t *testing.T) {
+	alpha, beta := 2, 3
+	newLabels := func() *labelsStub {
+		return newLabelsStub(department,
+			label.Label{ID: alpha, Name: "alpha"},
+			label.Label{ID: beta, Name: "beta"},
+			label.Label{ID: 4, Name: "design", ParentID: &alpha},
+			label.Label{ID: 5, Name: "design", ParentID: &beta},
+			label.Label{ID: 6, Name: "review", ParentID: &beta},
+		)
+	}
+
+	labels := newLabels()
+	err := newTestService(labels).Update(1, 6, label.Update{Name: str("design")})
+	if !errors.Is(err, &label.LabelNameTakenErr{}) {
+		t.Errorf("rename err = %v, want LabelNameTakenErr", err)
+	}
+	if labels.labels[6].Name != "review" {
+		t.Errorf("label renamed to %q", labels.labels[6].Name)
+	}
+
+	labels = newLabels()
+	moved := &movesStub{labelsStub: labels}
+	err = newTestService(moved).Move(1, 4, &beta)
+	if !errors.Is(err, &label.LabelNameTakenErr{}) {
+		t.Errorf("move err = %v, want LabelNameTakenErr", err)
+	}
+	if moved.moved {
+		t.Error("label moved next to one of the same name")
+	}
+
+	if err = newTestService(moved).Move(1, 6, &alpha); err != nil || !moved.moved {
+		t.Errorf("move of a label with a free name: err = %v, moved = %v", err, moved.moved)
+	}
+}
+
+func TestDetachDeletesOnlyUnusedLeafLabels(t *testing.T) {
+	child := label.Label{ID: 2, Name: "семинар", ParentID: &department.ID}
+	tests := []struct {
+		name     string
+		labelID  int
+		assigned map[int][]int
+		deleted  bool
+	}{
+		{"leaf used by no other report", child.ID, map[int][]int{7: {child.ID}}, true},
+		{"leaf used by another report", child.ID, map[int][]int{7: {child.ID}, 8: {child.ID}}, false},
+		{"parent used by no other report", department.ID, map[int][]int{7: {department.ID}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := newLabelsStub(department, child)
+			labels.assigned = tt.assigned
+			if err := newTestService(labels).Detach(1, tt.labelID, 7); err != nil {
+				t.Fatal(err)
+			}
+			if len(labels.assigned[7]) != 0 {
+				t.Errorf("label is still assigned: %+v", labels.assigned)
+			}
+			if _, ok := labels.labels[tt.labelID]; ok == tt.deleted {
+				t.Errorf("label exists = %v, want %v", ok, !tt.deleted)
+			}
+		})
+	}
+}
+
+func TestFinalizedReportKeepsItsLabels(t *testing.T) {
+	labels := newLabelsStub(department)
+	labels.assigned[7] = []int{department.ID}
+	s := newTestService(labels, 7)
+
+	l := label.Label{Name: "семинар"}
+	if err := s.Create(1, 7, &l); !errors.Is(err, &report.AlreadyFinalizedErr{}) {
+		t.Fatalf("create err = %v, want AlreadyFinalizedErr", err)
+	}
+	if err := s.Detach(1, department.ID, 7); !errors.Is(err, &report.AlreadyFinalizedErr{}) {
+		t.Fatalf("detach err = %v, want AlreadyFinalizedErr", err)
+	}
+	if len(labels.labels) != 1 || len(labels.assigned[7]) != 1 {
+		t.Errorf("labels are %+v, assigned %+v", labels.labels, labels.assigned)
+	}
+}
@@ -0,0 +1,67 @@
+package numbering
+
+import (
+	"reports_system/internal/model/report"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	seqPlaceholder        = "{{seq}}"
+	yearPlaceholder       = "{{year}}"
+	departmentPlaceholder = "{{department}}"
+)
+
+type Service struct {
+	numberingRepository repository.Numbering
+	accountsRepository  repository.Account
+	cfg                 session.Numbering
+	logger              logging.Logger
+}
+
+func NewService(
+	numberingRepository repository.Numbering,
+	accountsRepository repository.Account,
+	cfg session.Numbering,
+	logger logging.Logger,
+) *Service {
+	return &Service{
+		numberingRepository: numberingRepository,
+		accountsRepository:  accountsRepository,
+		cfg:                 cfg,
+		logger:              logger,
+	}
+}
+
+// Finalize turns a draft into an official protocol and returns its registration number.
+func (s *Service) Finalize(userID, reportID int) (string, error) {
+	a, err := s.accountsRepository.GetOne(userID)
+	if err != nil {
+		return "", err
+	}
+	if a.Department == "" {
+		return "", &report.DepartmentRequiredErr{}
+	}
+
+	year := time.Now().Year()
+	pattern := s.pattern(a.Department)
+
+	return s.numberingRepository.Finalize(userID, reportID, a.OrganizationID, a.Department, year, func(seq int) string {
+		return strings.NewReplacer(
+			seqPlaceholder, strconv.Itoa(seq),
+			yearPlaceholder, strconv.Itoa(year),
+			departmentPlaceholder, a.Department,
+		).Replace(pattern)
+	})
+}
+
+func (s *Service) pattern(department string) string {
+	if p, ok := s.cfg.Departments[department]; ok && p != "" {
+		return p
+	}
+	return s.cfg.Format
+}
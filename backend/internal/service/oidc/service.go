@@ -0,0 +1,258 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/oidc"
+	"reports_system/pkg/password"
+	"reports_system/pkg/securetoken"
+	"strings"
+	"time"
+)
+
+// maxUsernameAttempts limits the search for a free username on provisioning.
+const maxUsernameAttempts = 20
+
+// sessionStarter is implemented by the account service.
+type sessionStarter interface {
+	StartSession(userID int, client account.Client) (account.Tokens, error)
+}
+
+// Service logs users in with the identity provider of the university. Second
+// factor and lockout are left to the provider.
+type Service struct {
+	provider           *oidc.Provider
+	accountsRepository repository.Account
+	oidcRepository     repository.OIDC
+	accounts           sessionStarter
+	cfg                session.OIDC
+	logger             logging.Logger
+}
+
+func NewService(
+	accountsRepository repository.Account,
+	oidcRepository repository.OIDC,
+	accounts sessionStarter,
+	cfg session.OIDC,
+	logger logging.Logger,
+) *Service {
+	s := &Service{
+		accountsRepository: accountsRepository,
+		oidcRepository:     oidcRepository,
+		accounts:           accounts,
+		cfg:                cfg,
+		logger:             logger,
+	}
+	if cfg.Enabled {
+		s.provider = oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		}, nil)
+	}
+	return s
+}
+
+// Begin returns the state to bind to the browser and the provider URL to
+// redirect it to.
+func (s *Service) Begin(ctx context.Context) (string, string, error) {
+	if s.provider == nil {
+		return "", "", &account.SSODisabledErr{}
+	}
+
+	state, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return "", "", err
+	}
+
+	redirect, err := s.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = s.oidcRepository.CreateLogin(account.OIDCLogin{
+		StateHash: securetoken.Hash(state),
+		Nonce:     nonce,
+		Verifier:  verifier,
+		Expires:   time.Now().Add(s.cfg.LoginTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return state, redirect, nil
+}
+
+// Complete handles the callback of the provider. The account is found by the
+// provider subject, by a verified email for existing accounts, or created.
+func (s *Service) Complete(ctx context.Context, code, state string, client account.Client) (account.Tokens, account.Account, error) {
+	if s.provider == nil {
+		return account.Tokens{}, account.Account{}, &account.SSODisabledErr{}
+	}
+
+	login, err := s.oidcRepository.ConsumeLogin(securetoken.Hash(state))
+	if err != nil {
+		return account.Tokens{}, account.Account{}, err
+	}
+	if time.Now().After(login.Expires) {
+		return account.Tokens{}, account.Account{}, &account.InvalidSSOStateErr{}
+	}
+
+	claims, err := s.provider.Exchange(ctx, code, login.Verifier, login.Nonce)
+	if err != nil {
+		s.logger.Info(err)
+		return account.Tokens{}, account.Account{}, &account.SSOFailedErr{}
+	}
+
+	a, err := s.findOrProvision(claims)
+	if err != nil {
+		return account.Tokens{}, a, err
+	}
+	if a.Deactivated != nil {
+		return account.Tokens{}, a, &account.AccountDeactivatedErr{}
+	}
+
+	tokens, err := s.accounts.StartSession(a.ID, client)
+	return tokens, a, err
+}
+
+func (s *Service) findOrProvision(claims oidc.Claims) (account.Account, error) {
+	id := account.Identity{Issuer: claims.Issuer, Subject: claims.Subject}
+
+	userID, err := s.oidcRepository.GetUserID(id)
+	if err == nil {
+		a, err := s.accountsRepository.GetOne(userID)
+		if err != nil {
+			return a, err
+		}
+		return s.sync(a, claims)
+	}
+	if !errors.Is(err, &account.AccountNotFoundErr{}) {
+		return account.Account{}, err
+	}
+
+	if claims.Email != "" {
+		a, found, err := s.linkByEmail(id, claims)
+		if err != nil || found {
+			return a, err
+		}
+	}
+
+	return s.provision(id, claims)
+}
+
+// linkByEmail attaches the identity to an account registered with a password
+// before single sign-on was enabled. The provider must vouch for the email,
+// otherwise anyone could take over an account by setting its email at the provider.
+// The account must have verified the email too, or whoever registered it first
+// would get the sessions of its owner, and it must be an active local one.
+func (s *Service) linkByEmail(id account.Identity, claims oidc.Claims) (account.Account, bool, error) {
+	accounts, err := s.accountsRepository.GetAllByEmail(claims.Email)
+	if err != nil {
+		return account.Account{}, false, err
+	}
+	if len(accounts) == 0 {
+		return account.Account{}, false, nil
+	}
+	if len(accounts) > 1 || !claims.EmailVerified {
+		return account.Account{}, false, &account.SSOEmailConflictErr{}
+	}
+
+	a, err := s.accountsRepository.GetOne(accounts[0].ID)
+	if err != nil {
+		return a, false, err
+	}
+	if !a.EmailVerified || a.AuthSource != account.AuthSourceLocal || a.Deactivated != nil {
+		return account.Account{}, false, &account.SSOEmailConflictErr{}
+	}
+	if err = s.oidcRepository.Link(id, a.ID); err != nil {
+		return a, false, err
+	}
+	s.logger.Infof("account %v linked to %s of %s", a.ID, id.Subject, id.Issuer)
+
+	a, err = s.sync(a, claims)
+	return a, true, err
+}
+
+func (s *Service) provision(id account.Identity, claims oidc.Claims) (account.Account, error) {
+	department, role := account.MapGroups(claims.Strings(s.cfg.GroupsClaim), s.cfg.Groups)
+	if !account.ValidRole(role) {
+		role = account.RoleUser
+	}
+
+	a := account.Account{
+		Name:          displayName(claims),
+		Email:         claims.Email,
+		Department:    department,
+		PasswordHash:  password.Unusable,
+		EmailVerified: claims.EmailVerified,
+		Role:          role,
+		AuthSource:    account.AuthSourceOIDC,
+	}
+
+	for attempt := 0; attempt < maxUsernameAttempts; attempt++ {
+		a.Username = account.ProvisionedUsername(claims.PreferredUsername, claims.Email, attempt)
+
+		err := s.oidcRepository.Provision(&a, id)
+		if err == nil {
+			s.logger.Infof("account %v provisioned for %s of %s", a.ID, id.Subject, id.Issuer)
+			return a, nil
+		}
+		if !errors.Is(err, &account.UsernameTakenErr{}) {
+			return a, err
+		}
+	}
+	return a, &account.UsernameTakenErr{}
+}
+
+// sync applies the groups and name from the provider on every login, so
+// changes made there don't have to be repeated here.
+func (s *Service) sync(a account.Account, claims oidc.Claims) (account.Account, error) {
+	department, role := account.MapGroups(claims.Strings(s.cfg.GroupsClaim), s.cfg.Groups)
+
+	updated := a
+	if name := displayName(claims); name != "" {
+		updated.Name = name
+	}
+	if department != "" {
+		updated.Department = department
+	}
+	if account.RolesFromGroups(s.cfg.Groups) {
+		updated.Role = account.RoleUser
+		if account.ValidRole(role) {
+			updated.Role = role
+		}
+	}
+
+	if updated.Name == a.Name && updated.Department == a.Department && updated.Role == a.Role {
+		return a, nil
+	}
+	if err := s.oidcRepository.Sync(updated); err != nil {
+		return a, err
+	}
+	return updated, nil
+}
+
+func displayName(claims oidc.Claims) string {
+	if name := strings.TrimSpace(claims.Name); name != "" {
+		return name
+	}
+	if claims.PreferredUsername != "" {
+		return claims.PreferredUsername
+	}
+	return claims.Email
+}
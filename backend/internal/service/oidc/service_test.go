@@ -0,0 +1,327 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/oidc/oidctest"
+	"reports_system/pkg/password"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type accountsStub struct {
+	repository.Account
+	accounts map[int]account.Account
+}
+
+func (r *accountsStub) GetOne(userID int) (account.Account, error) {
+	a, ok := r.accounts[userID]
+	if !ok {
+		return a, &account.AccountNotFoundErr{}
+	}
+	return a, nil
+}
+
+func (r *accountsStub) GetAllByEmail(email string) ([]account.Account, error) {
+	found := make([]account.Account, 0)
+	for _, a := range r.accounts {
+		if a.Email == email {
+			found = append(found, a)
+		}
+	}
+	return found, nil
+}
+
+type oidcStub struct {
+	accounts   *accountsStub
+	logins     map[string]account.OIDCLogin
+	identities map[account.Identity]int
+}
+
+func (r *oidcStub) CreateLogin(l account.OIDCLogin) error {
+	r.logins[l.StateHash] = l
+	return nil
+}
+
+func (r *oidcStub) ConsumeLogin(stateHash string) (account.OIDCLogin, error) {
+	l, ok := r.logins[stateHash]
+	if !ok {
+		return l, &account.InvalidSSOStateErr{}
+	}
+	delete(r.logins, stateHash)
+	return l, nil
+}
+
+func (r *oidcStub) GetUserID(id account.Identity) (int, error) {
+	userID, ok := r.identities[id]
+	if !ok {
+		return 0, &account.AccountNotFoundErr{}
+	}
+	return userID, nil
+}
+
+func (r *oidcStub) Link(id account.Identity, userID int) error {
+	r.identities[id] = userID
+	return nil
+}
+
+func (r *oidcStub) Provision(a *account.Account, id account.Identity) error {
+	for _, other := range r.accounts.accounts {
+		if other.Username == a.Username {
+			return &account.UsernameTakenErr{}
+		}
+	}
+	a.ID = len(r.accounts.accounts) + 1
+	r.accounts.accounts[a.ID] = *a
+	r.identities[id] = a.ID
+	return nil
+}
+
+func (r *oidcStub) Sync(a account.Account) error {
+	r.accounts.accounts[a.ID] = a
+	return nil
+}
+
+type sessionsStub struct{}
+
+func (sessionsStub) StartSession(userID int, _ account.Client) (account.Tokens, error) {
+	return account.Tokens{Access: "access", Refresh: "refresh"}, nil
+}
+
+type testEnv struct {
+	provider *oidctest.Server
+	service  *Service
+	accounts *accountsStub
+	oidc     *oidcStub
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	provider, err := oidctest.NewServer("reports-system", "client-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(provider.Close)
+
+	accounts := &accountsStub{accounts: map[int]account.Account{
+		1: {ID: 1, Username: "ivanov", Name: "Иванов", Email: "ivanov@bmstu.ru", Role: account.RoleUser,
+			EmailVerified: true, AuthSource: account.AuthSourceLocal},
+	}}
+	oidcRepository := &oidcStub{
+		accounts:   accounts,
+		logins:     map[string]account.OIDCLogin{},
+		identities: map[account.Identity]int{},
+	}
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	s := NewService(accounts, oidcRepository, sessionsStub{}, session.OIDC{
+		Enabled:      true,
+		Issuer:       provider.URL,
+		ClientID:     "reports-system",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost/api/v1/accounts/oidc/callback",
+		Scopes:       []string{"openid", "profile", "email"},
+		GroupsClaim:  "groups",
+		Groups:       []session.OIDCGroup{{Group: "iu7", Department: "ИУ7"}},
+		LoginTTL:     time.Minute,
+	}, logging.Logger{Entry: logrus.NewEntry(l)})
+
+	return &testEnv{provider: provider, service: s, accounts: accounts, oidc: oidcRepository}
+}
+
+// login begins a login and lets the provider authorize it with claims.
+func (env *testEnv) login(t *testing.T, claims map[string]interface{}) (account.Account, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	state, redirect, err := env.service.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, returnedState, err := env.provider.Authorize(redirect, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if returnedState != state {
+		t.Fatalf("state %q came back as %q", state, returnedState)
+	}
+
+	_, a, err := env.service.Complete(ctx, code, state, account.Client{})
+	return a, err
+}
+
+func TestCompleteProvisionsAccount(t *testing.T) {
+	env := newTestEnv(t)
+
+	a, err := env.login(t, map[string]interface{}{
+		"sub":                "petrov-subject",
+		"email":              "petrov@bmstu.ru",
+		"email_verified":     true,
+		"name":               "Петров",
+		"preferred_username": "petrov",
+		"groups":             []string{"iu7"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID == 1 || a.Username != "petrov" || a.Department != "ИУ7" || a.PasswordHash != password.Unusable ||
+		a.AuthSource != account.AuthSourceOIDC {
+		t.Errorf("unexpected provisioned account %+v", a)
+	}
+
+	again, err := env.login(t, map[string]interface{}{"sub": "petrov-subject", "name": "Петров П."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != a.ID || again.Name != "Петров П." {
+		t.Errorf("second login got %+v, want account %v with the new name", again, a.ID)
+	}
+}
+
+func TestCompleteLinksAccountByVerifiedEmail(t *testing.T) {
+	env := newTestEnv(t)
+
+	a, err := env.login(t, map[string]interface{}{
+		"sub":            "ivanov-subject",
+		"email":          "ivanov@bmstu.ru",
+		"email_verified": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID != 1 {
+		t.Fatalf("logged in as %v, want the existing account 1", a.ID)
+	}
+	id := account.Identity{Issuer: env.provider.URL, Subject: "ivanov-subject"}
+	if env.oidc.identities[id] != 1 {
+		t.Error("identity was not linked to the account")
+	}
+}
+
+func TestCompleteRefusesUnverifiedEmailOfExistingAccount(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, err := env.login(t, map[string]interface{}{
+		"sub":            "attacker",
+		"email":          "ivanov@bmstu.ru",
+		"email_verified": false,
+	})
+	if !errors.Is(err, &account.SSOEmailConflictErr{}) {
+		t.Fatalf("err = %v, want SSOEmailConflictErr", err)
+	}
+	if len(env.oidc.identities) != 0 {
+		t.Error("unverified email was linked to an existing account")
+	}
+}
+
+func TestCompleteLinksOnlyActiveLocalAccountsWithVerifiedEmail(t *testing.T) {
+	deactivated := time.Now()
+	for name, change := range map[string]func(a *account.Account){
+		"unverified email": func(a *account.Account) { a.EmailVerified = false },
+		"ldap account":     func(a *account.Account) { a.AuthSource = account.AuthSourceLDAP },
+		"deactivated":      func(a *account.Account) { a.Deactivated = &deactivated },
+	} {
+		t.Run(name, func(t *testing.T) {
+			env := newTestEnv(t)
+			a := env.accounts.accounts[1]
+			change(&a)
+			env.accounts.accounts[1] = a
+
+			_, err := env.login(t, map[string]interface{}{
+				"sub":            "ivanov-subject",
+				"email":          "ivanov@bmstu.ru",
+				"email_verified": true,
+			})
+			if !errors.Is(err, &account.SSOEmailConflictErr{}) {
+				t.Fatalf("err = %v, want SSOEmailConflictErr", err)
+			}
+			if len(env.oidc.identities) != 0 {
+				t.Error("identity was linked")
+			}
+		})
+	}
+}
+
+func TestCompleteRejectsForeignNonce(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, err := env.login(t, map[string]interface{}{"nonce": "nonce-of-another-login"})
+	if !errors.Is(err, &account.SSOFailedErr{}) {
+		t.Fatalf("err = %v, want SSOFailedErr", err)
+	}
+}
+
+func TestCompleteChecksState(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	state, redirect, err := env.service.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := env.provider.Authorize(redirect, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = env.service.Complete(ctx, code, "forged-state", account.Client{}); !errors.Is(err, &account.InvalidSSOStateErr{}) {
+		t.Errorf("forged state err = %v, want InvalidSSOStateErr", err)
+	}
+	if _, _, err = env.service.Complete(ctx, code, state, account.Client{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = env.service.Complete(ctx, code, state, account.Client{}); !errors.Is(err, &account.InvalidSSOStateErr{}) {
+		t.Errorf("replayed state err = %v, want InvalidSSOStateErr", err)
+	}
+}
+
+func TestCompleteRejectsExpiredLogin(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	state, redirect, err := env.service.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := env.provider.Authorize(redirect, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hash, l := range env.oidc.logins {
+		l.Expires = time.Now().Add(-time.Second)
+		env.oidc.logins[hash] = l
+	}
+
+	if _, _, err = env.service.Complete(ctx, code, state, account.Client{}); !errors.Is(err, &account.InvalidSSOStateErr{}) {
+		t.Errorf("err = %v, want InvalidSSOStateErr", err)
+	}
+}
+
+func TestCompleteRefusesDeactivatedAccount(t *testing.T) {
+	env := newTestEnv(t)
+	deactivated := time.Now()
+	a := env.accounts.accounts[1]
+	a.Deactivated = &deactivated
+	env.accounts.accounts[1] = a
+	env.oidc.identities[account.Identity{Issuer: env.provider.URL, Subject: "ivanov-subject"}] = 1
+
+	if _, err := env.login(t, map[string]interface{}{"sub": "ivanov-subject"}); !errors.Is(err, &account.AccountDeactivatedErr{}) {
+		t.Fatalf("err = %v, want AccountDeactivatedErr", err)
+	}
+}
+
+func TestBeginWhenDisabled(t *testing.T) {
+	s := NewService(nil, nil, nil, session.OIDC{}, logging.Logger{Entry: logrus.NewEntry(logrus.New())})
+	if _, _, err := s.Begin(context.Background()); !errors.Is(err, &account.SSODisabledErr{}) {
+		t.Fatalf("err = %v, want SSODisabledErr", err)
+	}
+}
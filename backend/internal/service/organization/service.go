@@ -0,0 +1,41 @@
+package organization
+
+import (
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+)
+
+type Service struct {
+	repository repository.Organization
+	logger     logging.Logger
+}
+
+func NewService(repository repository.Organization, logger logging.Logger) *Service {
+	return &Service{repository: repository, logger: logger}
+}
+
+// Bootstrap creates the organizations from the config and updates their names
+// and domains. Existing accounts stay where they are, the domains only decide
+// where new accounts go.
+func (s *Service) Bootstrap(organizations []session.Organization) {
+	for _, c := range organizations {
+		o := account.Organization{Slug: c.Slug, Name: c.Name, Domains: append([]string{}, c.Domains...)}
+		if o.Slug == "" || o.Name == "" {
+			s.logger.Infof("organization %q is not bootstrapped, slug and name are required", o.Slug)
+			continue
+		}
+		o.NormalizeDomains()
+
+		if err := s.repository.Sync(&o); err != nil {
+			s.logger.Error(err)
+			continue
+		}
+		s.logger.Infof("organization %v is %q with domains %v", o.ID, o.Slug, o.Domains)
+	}
+}
+
+func (s *Service) GetOne(organizationID int) (account.Organization, error) {
+	return s.repository.GetOne(organizationID)
+}
@@ -0,0 +1,133 @@
+package profile
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"reports_system/internal/model/account"
+	"reports_system/internal/model/attachment"
+	"reports_system/internal/model/report"
+	"time"
+)
+
+// exportedAccount leaves out the password hash and other secrets.
+type exportedAccount struct {
+	Name          string            `json:"name"`
+	Username      string            `json:"username"`
+	Email         string            `json:"email"`
+	Department    string            `json:"department"`
+	EmailVerified bool              `json:"emailVerified"`
+	Sessions      []account.Session `json:"sessions"`
+	Exported      time.Time         `json:"exported"`
+}
+
+type exportedReport struct {
+	report.Report
+	Attachments []attachment.Attachment `json:"attachments"`
+}
+
+// Export writes a zip archive with everything stored about the user:
+// account.json, reports.json, templates.json and attachment files under
+// attachments/<report id>/.
+func (s *Service) Export(userID int, w io.Writer) error {
+	a, err := s.accountsRepository.GetOne(userID)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.sessionsRepository.GetAllActive(userID)
+	if err != nil {
+		return err
+	}
+	templates, err := s.templatesRepository.GetAll(userID)
+	if err != nil {
+		return err
+	}
+	reports, err := s.collectReports(userID)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+
+	err = writeJSON(archive, "account.json", exportedAccount{
+		Name:          a.Name,
+		Username:      a.Username,
+		Email:         a.Email,
+		Department:    a.Department,
+		EmailVerified: a.EmailVerified,
+		Sessions:      sessions,
+		Exported:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(archive, "reports.json", reports); err != nil {
+		return err
+	}
+	if err = writeJSON(archive, "templates.json", templates); err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		for _, at := range r.Attachments {
+			if err = s.writeAttachment(archive, at); err != nil {
+				return err
+			}
+		}
+	}
+
+	return archive.Close()
+}
+
+func (s *Service) collectReports(userID int) ([]exportedReport, error) {
+	reports, err := s.reportsRepository.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]exportedReport, len(reports))
+	for i, r := range reports {
+		full, err := s.reportsRepository.GetOne(userID, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		full.Labels, err = s.labelsRepository.GetAllByReport(userID, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		attachments, err := s.attachmentsRepository.GetAll(userID, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		exported[i] = exportedReport{Report: full, Attachments: attachments}
+	}
+	return exported, nil
+}
+
+func (s *Service) writeAttachment(archive *zip.Writer, a attachment.Attachment) error {
+	blob, err := s.storage.Get(a.StorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to read attachment %d due to error %w", a.ID, err)
+	}
+	defer blob.Close()
+
+	f, err := archive.Create(fmt.Sprintf("attachments/%d/%d-%s", a.ReportID, a.ID, path.Base(a.Name)))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, blob)
+	return err
+}
+
+func writeJSON(archive *zip.Writer, name string, v interface{}) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,192 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/mail"
+	"reports_system/pkg/securetoken"
+	"reports_system/pkg/storage"
+	"time"
+)
+
+// verificationSender is implemented by the account service.
+type verificationSender interface {
+	SendVerification(a account.Account) error
+}
+
+type Service struct {
+	accountsRepository    repository.Account
+	sessionsRepository    repository.Session
+	profilesRepository    repository.Profile
+	reportsRepository     repository.Report
+	labelsRepository      repository.Label
+	attachmentsRepository repository.Attachment
+	templatesRepository   repository.Template
+	verifier              verificationSender
+	mailer                mail.Sender
+	deletionCfg           session.AccountDeletion
+	storage               storage.Storage
+	logger                logging.Logger
+}
+
+func NewService(
+	accountsRepository repository.Account,
+	sessionsRepository repository.Session,
+	profilesRepository repository.Profile,
+	reportsRepository repository.Report,
+	labelsRepository repository.Label,
+	attachmentsRepository repository.Attachment,
+	templatesRepository repository.Template,
+	verifier verificationSender,
+	mailer mail.Sender,
+	deletionCfg session.AccountDeletion,
+	storage storage.Storage,
+	logger logging.Logger,
+) *Service {
+	return &Service{
+		accountsRepository:    accountsRepository,
+		sessionsRepository:    sessionsRepository,
+		profilesRepository:    profilesRepository,
+		reportsRepository:     reportsRepository,
+		labelsRepository:      labelsRepository,
+		attachmentsRepository: attachmentsRepository,
+		templatesRepository:   templatesRepository,
+		verifier:              verifier,
+		mailer:                mailer,
+		deletionCfg:           deletionCfg,
+		storage:               storage,
+		logger:                logger,
+	}
+}
+
+func (s *Service) Get(userID int) (account.Account, error) {
+	return s.accountsRepository.GetOne(userID)
+}
+
+// Update changes the profile, a new email has to be verified again.
+func (s *Service) Update(userID int, u account.ProfileUpdate) (account.Account, error) {
+	a, err := s.accountsRepository.GetOne(userID)
+	if err != nil {
+		return a, err
+	}
+
+	emailChanged := a.Apply(u)
+	if err = a.ValidateProfile(); err != nil {
+		return a, err
+	}
+	if err = s.accountsRepository.UpdateProfile(a); err != nil {
+		return a, err
+	}
+
+	if emailChanged {
+		if err = s.verifier.SendVerification(a); err != nil {
+			s.logger.Error(err)
+		}
+	}
+	return a, nil
+}
+
+// RequestDeletion mails a deletion token to an account without a password,
+// older tokens stop working. Accounts with a password confirm with it.
+func (s *Service) RequestDeletion(userID int) error {
+	a, err := s.accountsRepository.GetCredentials(userID)
+	if err != nil {
+		return err
+	}
+	if a.HasPassword() {
+		return &account.DeletionByPasswordErr{}
+	}
+
+	token, err := securetoken.New(securetoken.DefaultSize)
+	if err != nil {
+		return err
+	}
+	err = s.profilesRepository.CreateDeletion(a.ID, securetoken.Hash(token), time.Now().Add(s.deletionCfg.TTL))
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      a.Email,
+		Subject: "Account deletion",
+		Body: fmt.Sprintf(
+			"Hello, %s!\r\n\r\n"+
+				"Someone asked to delete account %s. To confirm, send this token with the deletion request, "+
+				"it is valid for %v:\r\n\r\n%s\r\n\r\n"+
+				"If it wasn't you, just ignore this email.\r\n",
+			a.Name, a.Username, s.deletionCfg.TTL, token,
+		),
+	})
+}
+
+// Delete removes the account after checking the password, or the mailed
+// token for accounts without one. Reports are either handed over to the
+// account with username d.TransferTo or deleted with their attachments,
+// finalized reports can only be transferred.
+func (s *Service) Delete(userID int, d account.Deletion) error {
+	a, err := s.accountsRepository.GetCredentials(userID)
+	if err != nil {
+		return err
+	}
+	if a.HasPassword() {
+		if err = a.CheckPassword(d.Password); err != nil {
+			return err
+		}
+	}
+
+	targetID := 0
+	switch d.Reports {
+	case account.ReportsTransfer:
+		target, err := s.accountsRepository.GetOneByUsername(d.TransferTo)
+		if err != nil {
+			if errors.Is(err, &account.AccountNotFoundErr{}) {
+				return &account.TransferTargetNotFoundErr{}
+			}
+			return err
+		}
+		if target.ID == userID || target.OrganizationID != a.OrganizationID {
+			return &account.TransferTargetNotFoundErr{}
+		}
+		targetID = target.ID
+	case account.ReportsDelete:
+		finalized, err := s.profilesRepository.HasFinalized(userID)
+		if err != nil {
+			return err
+		}
+		if finalized {
+			return &account.FinalizedReportsErr{}
+		}
+	default:
+		return &account.UnknownReportsDispositionErr{}
+	}
+
+	// the token is used only once the request is known to be valid, so a
+	// typo in the new owner doesn't cost the user another email
+	if !a.HasPassword() {
+		if d.Token == "" {
+			return &account.InvalidDeletionTokenErr{}
+		}
+		if err = s.profilesRepository.UseDeletion(userID, securetoken.Hash(d.Token)); err != nil {
+			return err
+		}
+	}
+
+	if d.Reports == account.ReportsTransfer {
+		return s.profilesRepository.DeleteTransferring(userID, targetID)
+	}
+
+	keys, err := s.profilesRepository.DeleteWithData(userID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.storage.Delete(key); err != nil {
+			s.logger.Error(err)
+		}
+	}
+	return nil
+}
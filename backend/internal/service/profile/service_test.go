@@ -0,0 +1,230 @@
+package profile
+
+import (
+	"errors"
+	"io"
+	"reports_system/internal/model/account"
+	"reports_system/internal/repository"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"reports_system/pkg/mail"
+	"reports_system/pkg/password"
+	"reports_system/pkg/securetoken"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type accountsStub struct {
+	repository.Account
+	accounts map[int]account.Account
+}
+
+func (r *accountsStub) GetCredentials(userID int) (account.Account, error) {
+	a, ok := r.accounts[userID]
+	if !ok {
+		return a, &account.AccountNotFoundErr{}
+	}
+	return a, nil
+}
+
+func (r *accountsStub) GetOneByUsername(username string) (account.Account, error) {
+	for _, a := range r.accounts {
+		if a.Username == username {
+			return a, nil
+		}
+	}
+	return account.Account{}, &account.AccountNotFoundErr{}
+}
+
+type deletionToken struct {
+	userID  int
+	expires time.Time
+	used    bool
+}
+
+type profilesStub struct {
+	repository.Profile
+	tokens    map[string]*deletionToken
+	finalized map[int]bool
+	deleted   []int
+}
+
+func (r *profilesStub) HasFinalized(userID int) (bool, error) {
+	return r.finalized[userID], nil
+}
+
+func (r *profilesStub) CreateDeletion(userID int, tokenHash string, expires time.Time) error {
+	r.tokens[tokenHash] = &deletionToken{userID: userID, expires: expires}
+	return nil
+}
+
+func (r *profilesStub) UseDeletion(userID int, tokenHash string) error {
+	t, ok := r.tokens[tokenHash]
+	if !ok || t.used || t.userID != userID || time.Now().After(t.expires) {
+		return &account.InvalidDeletionTokenErr{}
+	}
+	t.used = true
+	return nil
+}
+
+func (r *profilesStub) DeleteTransferring(userID, _ int) error {
+	r.deleted = append(r.deleted, userID)
+	return nil
+}
+
+func (r *profilesStub) DeleteWithData(userID int) ([]string, error) {
+	r.deleted = append(r.deleted, userID)
+	return nil, nil
+}
+
+type mailerStub struct {
+	sent []mail.Message
+}
+
+func (m *mailerStub) Send(msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// token takes the token out of the last deletion email.
+func (m *mailerStub) token(t *testing.T) string {
+	t.Helper()
+	if len(m.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	lines := strings.Split(m.sent[len(m.sent)-1].Body, "\r\n")
+	return lines[4]
+}
+
+func newTestService(t *testing.T) (*Service, *profilesStub, *mailerStub) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := &accountsStub{accounts: map[int]account.Account{
+		1: {ID: 1, Username: "local", Email: "local@bmstu.ru", PasswordHash: string(hash)},
+		2: {ID: 2, Username: "ldap", Email: "ldap@bmstu.ru", PasswordHash: password.Unusable},
+	}}
+	profiles := &profilesStub{tokens: map[string]*deletionToken{}, finalized: map[int]bool{}}
+	mailer := &mailerStub{}
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	s := NewService(accounts, nil, profiles, nil, nil, nil, nil, nil, mailer,
+		session.AccountDeletion{TTL: time.Hour}, nil, logging.Logger{Entry: logrus.NewEntry(l)})
+	return s, profiles, mailer
+}
+
+func TestDeleteWithPassword(t *testing.T) {
+	s, profiles, _ := newTestService(t)
+
+	err := s.Delete(1, account.Deletion{Password: "wrong", Reports: account.ReportsDelete})
+	if !errors.Is(err, &account.PasswordDoesNotMatchErr{}) {
+		t.Fatalf("err = %v, want PasswordDoesNotMatchErr", err)
+	}
+	if err = s.Delete(1, account.Deletion{Password: "secret-password", Reports: account.ReportsDelete}); err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles.deleted) != 1 || profiles.deleted[0] != 1 {
+		t.Errorf("deleted = %v, want [1]", profiles.deleted)
+	}
+}
+
+func TestAccountWithPasswordCanNotRequestToken(t *testing.T) {
+	s, _, mailer := newTestService(t)
+
+	if err := s.RequestDeletion(1); !errors.Is(err, &account.DeletionByPasswordErr{}) {
+		t.Fatalf("err = %v, want DeletionByPasswordErr", err)
+	}
+	if len(mailer.sent) != 0 {
+		t.Error("deletion email was sent to an account with a password")
+	}
+}
+
+func TestDeleteExternalAccountWithMailedToken(t *testing.T) {
+	s, profiles, mailer := newTestService(t)
+
+	err := s.Delete(2, account.Deletion{Reports: account.ReportsDelete})
+	if !errors.Is(err, &account.InvalidDeletionTokenErr{}) {
+		t.Fatalf("delete without token err = %v, want InvalidDeletionTokenErr", err)
+	}
+
+	if err = s.RequestDeletion(2); err != nil {
+		t.Fatal(err)
+	}
+	if mailer.sent[0].To != "ldap@bmstu.ru" {
+		t.Errorf("deletion email was sent to %s", mailer.sent[0].To)
+	}
+	token := mailer.token(t)
+	if _, ok := profiles.tokens[securetoken.Hash(token)]; !ok {
+		t.Fatalf("mailed token %q is not the stored one", token)
+	}
+
+	if err = s.Delete(2, account.Deletion{Token: token, Reports: account.ReportsDelete}); err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles.deleted) != 1 || profiles.deleted[0] != 2 {
+		t.Errorf("deleted = %v, want [2]", profiles.deleted)
+	}
+}
+
+func TestDeletionTokenIsKeptOnInvalidRequest(t *testing.T) {
+	s, profiles, mailer := newTestService(t)
+
+	if err := s.RequestDeletion(2); err != nil {
+		t.Fatal(err)
+	}
+	token := mailer.token(t)
+
+	err := s.Delete(2, account.Deletion{Token: token, Reports: account.ReportsTransfer, TransferTo: "nobody"})
+	if !errors.Is(err, &account.TransferTargetNotFoundErr{}) {
+		t.Fatalf("err = %v, want TransferTargetNotFoundErr", err)
+	}
+	if err = s.Delete(2, account.Deletion{Token: token, Reports: account.ReportsTransfer, TransferTo: "local"}); err != nil {
+		t.Fatalf("token was used up by the invalid request: %v", err)
+	}
+	if len(profiles.deleted) != 1 {
+		t.Errorf("deleted = %v, want [2]", profiles.deleted)
+	}
+}
+
+func TestDeletionTokenOfAnotherAccount(t *testing.T) {
+	s, profiles, mailer := newTestService(t)
+
+	if err := s.RequestDeletion(2); err != nil {
+		t.Fatal(err)
+	}
+	profiles.tokens[securetoken.Hash(mailer.token(t))].userID = 3
+
+	err := s.Delete(2, account.Deletion{Token: mailer.token(t), Reports: account.ReportsDelete})
+	if !errors.Is(err, &account.InvalidDeletionTokenErr{}) {
+		t.Fatalf("err = %v, want InvalidDeletionTokenErr", err)
+	}
+}
+
+func TestFinalizedReportsCanOnlyBeTransferred(t *testing.T) {
+	s, profiles, mailer := newTestService(t)
+	profiles.finalized[2] = true
+
+	if err := s.RequestDeletion(2); err != nil {
+		t.Fatal(err)
+	}
+	token := mailer.token(t)
+
+	err := s.Delete(2, account.Deletion{Token: token, Reports: account.ReportsDelete})
+	if !errors.Is(err, &account.FinalizedReportsErr{}) {
+		t.Fatalf("err = %v, want FinalizedReportsErr", err)
+	}
+	if len(profiles.deleted) != 0 {
+		t.Fatalf("deleted = %v, want none", profiles.deleted)
+	}
+	if err = s.Delete(2, account.Deletion{Token: token, Reports: account.ReportsTransfer, TransferTo: "local"}); err != nil {
+		t.Fatalf("transfer after the refusal: %v", err)
+	}
+}
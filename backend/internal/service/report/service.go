@@ -1,27 +1,122 @@
 package report
 
 import (
+	"reports_system/internal/model/label"
 	"reports_system/internal/model/report"
+	"reports_system/internal/model/template"
 	"reports_system/internal/repository"
 	"reports_system/pkg/logging"
+	"reports_system/pkg/storage"
+	"time"
 )
 
+// labelCreator is the part of the label service used to pre-assign labels,
+// so that templates go through the same uniqueness checks as the api does.
+type labelCreator interface {
+	Create(userID, reportID int, t *label.Label) error
+}
+
 type Service struct {
-	reportsRepository repository.Report
-	labelsRepository  repository.Label
-	logger            logging.Logger
+	reportsRepository     repository.Report
+	labelsRepository      repository.Label
+	attachmentsRepository repository.Attachment
+	templatesRepository   repository.Template
+	accountsRepository    repository.Account
+	labels                labelCreator
+	storage               storage.Storage
+	logger                logging.Logger
 }
 
-func NewService(reportsRepository repository.Report, labelsRepository repository.Label, logger logging.Logger) *Service {
-	return &Service{reportsRepository: reportsRepository, labelsRepository: labelsRepository, logger: logger}
+func NewService(
+	reportsRepository repository.Report,
+	labelsRepository repository.Label,
+	attachmentsRepository repository.Attachment,
+	templatesRepository repository.Template,
+	accountsRepository repository.Account,
+	labels labelCreator,
+	storage storage.Storage,
+	logger logging.Logger,
+) *Service {
+	return &Service{
+		reportsRepository:     reportsRepository,
+		labelsRepository:      labelsRepository,
+		attachmentsRepository: attachmentsRepository,
+		templatesRepository:   templatesRepository,
+		accountsRepository:    accountsRepository,
+		labels:                labels,
+		storage:               storage,
+		logger:                logger,
+	}
 }
 
 func (s *Service) Create(userID int, n *report.Report) error {
-	err := s.reportsRepository.Create(userID, n)
+	a, err := s.accountsRepository.GetOne(userID)
+	if err != nil {
+		return err
+	}
+	if err = n.Classify(a.Clearance); err != nil {
+		return err
+	}
+
+	err = s.reportsRepository.Create(userID, n)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateFromTemplate creates a report from the template skeleton. Header and
+// body given in n take precedence over the ones rendered from the template.
+func (s *Service) CreateFromTemplate(userID, templateID int, n *report.Report) error {
+	t, err := s.templatesRepository.GetOne(userID, templateID)
 	if err != nil {
 		return err
 	}
 
+	a, err := s.accountsRepository.GetOne(userID)
+	if err != nil {
+		return err
+	}
+
+	draft := t.Instantiate(template.Values{
+		Date:       time.Now(),
+		Department: a.Department,
+		Author:     a.Name,
+	})
+	if n.Header != "" {
+		draft.Header = n.Header
+	}
+	if n.Body != "" {
+		draft.Body = n.Body
+		draft.GenerateShortBody()
+	}
+	draft.Classification = n.Classification
+	*n = draft
+	if err = n.Classify(a.Clearance); err != nil {
+		return err
+	}
+
+	if err = s.reportsRepository.Create(userID, n); err != nil {
+		return err
+	}
+
+	for _, name := range t.DefaultLabels {
+		l := label.Label{Name: name}
+		if err = s.labels.Create(userID, n.ID, &l); err != nil {
+			// labels go through the label service and can't share a
+			// transaction with the report, so the report is removed instead
+			// of being left half built
+			if err := s.reportsRepository.Delete(userID, n.ID); err != nil {
+				s.logger.Error(err)
+			}
+			n.ID = 0
+			n.Labels = nil
+			return err
+		}
+		n.Labels = append(n.Labels, l)
+	}
+
 	return nil
 }
 
@@ -59,7 +154,30 @@ func (s *Service) GetOne(userID, reportID int) (report.Report, error) {
 }
 
 func (s *Service) Delete(userID, reportID int) error {
-	return s.reportsRepository.Delete(userID, reportID)
+	n, err := s.reportsRepository.GetOne(userID, reportID)
+	if err != nil {
+		return err
+	}
+	if n.Status == report.StatusFinalized {
+		return &report.AlreadyFinalizedErr{}
+	}
+
+	attachments, err := s.attachmentsRepository.GetAll(userID, reportID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.reportsRepository.Delete(userID, reportID); err != nil {
+		return err
+	}
+
+	// attachment rows are removed by cascade, blobs have to be cleaned up by hand
+	for _, a := range attachments {
+		if err := s.storage.Delete(a.StorageKey); err != nil {
+			s.logger.Error(err)
+		}
+	}
+	return nil
 }
 
 func (s *Service) Update(userID int, n report.Report, needBodyUpdate bool) error {
@@ -67,9 +185,23 @@ func (s *Service) Update(userID int, n report.Report, needBodyUpdate bool) error
 	if err != nil {
 		return err
 	}
+	if prev.Status == report.StatusFinalized {
+		return &report.AlreadyFinalizedErr{}
+	}
 	if n.Header == "" {
 		n.Header = prev.Header
 	}
+	if n.Classification == "" {
+		n.Classification = prev.Classification
+	} else {
+		a, err := s.accountsRepository.GetOne(userID)
+		if err != nil {
+			return err
+		}
+		if err = n.Classify(a.Clearance); err != nil {
+			return err
+		}
+	}
 
 	if !needBodyUpdate {
 		n.Body = prev.Body
@@ -79,12 +211,32 @@ func (s *Service) Update(userID int, n report.Report, needBodyUpdate bool) error
 	return s.reportsRepository.Update(userID, n)
 }
 
+// expandLabels turns every label name into the ids of the labels it matches
+// and all of their descendants, so a parent label finds reports tagged with
+// any label below it.
+func (s *Service) expandLabels(userID int, labelNames []string) ([][]int, error) {
+	groups := make([][]int, 0, len(labelNames))
+	for _, name := range labelNames {
+		ids, err := s.labelsRepository.GetSubtreeIDs(userID, name)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, ids)
+	}
+	return groups, nil
+}
+
 func (s *Service) FindByLabels(userID int, labelNames []string) ([]report.Report, error) {
 	ns, err := s.reportsRepository.GetAll(userID)
 	if err != nil {
 		return ns, err
 	}
 
+	groups, err := s.expandLabels(userID, labelNames)
+	if err != nil {
+		return ns, err
+	}
+
 	var (
 		reportsWithAllLabels []report.Report
 	)
@@ -96,10 +248,50 @@ func (s *Service) FindByLabels(userID int, labelNames []string) ([]report.Report
 		}
 
 		s.logger.Infof("Found labels from report %v: %v", n.ID, n.Labels)
-		if n.HasEveryLabel(labelNames) {
+		if n.HasLabelFromEach(groups) {
 			reportsWithAllLabels = append(reportsWithAllLabels, n)
 		}
 	}
 
 	return reportsWithAllLabels, nil
 }
+
+func (s *Service) Search(userID int, query string, labelNames []string) ([]report.Report, error) {
+	ns, err := s.reportsRepository.Search(userID, query)
+	if err != nil {
+		return ns, err
+	}
+
+	groups, err := s.expandLabels(userID, labelNames)
+	if err != nil {
+		return ns, err
+	}
+
+	found := make([]report.Report, 0, len(ns))
+	for _, n := range ns {
+		n.Labels, err = s.labelsRepository.GetAllByReport(userID, n.ID)
+		if err != nil {
+			return ns, err
+		}
+
+		if n.HasLabelFromEach(groups) {
+			found = append(found, n)
+		}
+	}
+
+	return found, nil
+}
+
+func (s *Service) Export(userID int, labelNames []string, fn func(row report.RegistryRow) error) error {
+	groups, err := s.expandLabels(userID, labelNames)
+	if err != nil {
+		return err
+	}
+
+	return s.reportsRepository.StreamRegistry(userID, func(row report.RegistryRow) error {
+		if !row.HasLabelFromEach(groups) {
+			return nil
+		}
+		return fn(row)
+	})
+}
@@ -0,0 +1,175 @@
+package report
+
+import (
+	"errors"
+	"io"
+	"reports_system/internal/model/account"
+	"reports_system/internal/model/attachment"
+	"reports_system/internal/model/label"
+	"reports_system/internal/model/report"
+	"reports_system/internal/model/template"
+	"reports_system/internal/repository"
+	"reports_system/pkg/logging"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type reportsStub struct {
+	repository.Report
+	reports map[int]report.Report
+	nextID  int
+}
+
+func newReportsStub() *reportsStub {
+	return &reportsStub{reports: map[int]report.Report{}, nextID: 1}
+}
+
+func (r *reportsStub) Create(_ int, n *report.Report) error {
+	n.ID = r.nextID
+	r.nextID++
+	r.reports[n.ID] = *n
+	return nil
+}
+
+func (r *reportsStub) GetOne(_, reportID int) (report.Report, error) {
+	n, ok := r.reports[reportID]
+	if !ok {
+		return n, &report.ReportNotFoundErr{}
+	}
+	return n, nil
+}
+
+func (r *reportsStub) Delete(_, reportID int) error {
+	if _, ok := r.reports[reportID]; !ok {
+		return &report.ReportNotFoundErr{}
+	}
+	delete(r.reports, reportID)
+	return nil
+}
+
+func (r *reportsStub) Update(_ int, n report.Report) error {
+	r.reports[n.ID] = n
+	return nil
+}
+
+type templatesStub struct {
+	repository.Template
+	t template.Template
+}
+
+func (r *templatesStub) GetOne(_, _ int) (template.Template, error) {
+	return r.t, nil
+}
+
+type accountsStub struct {
+	repository.Account
+}
+
+func (r *accountsStub) GetOne(userID int) (account.Account, error) {
+	return account.Account{ID: userID, Name: "Иванов", Clearance: report.ClassificationInternal}, nil
+}
+
+type attachmentsStub struct {
+	repository.Attachment
+}
+
+func (r *attachmentsStub) GetAll(_, _ int) ([]attachment.Attachment, error) {
+	return nil, nil
+}
+
+// labelsStub fails on the label named failOn.
+type labelsStub struct {
+	created []string
+	failOn  string
+}
+
+func (l *labelsStub) Create(_, _ int, t *label.Label) error {
+	if t.Name == l.failOn {
+		return errors.New("label can't be created")
+	}
+	t.ID = len(l.created) + 1
+	l.created = append(l.created, t.Name)
+	return nil
+}
+
+func newTestService(reports *reportsStub, labels *labelsStub, t template.Template) *Service {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	return NewService(reports, nil, &attachmentsStub{}, &templatesStub{t: t}, &accountsStub{},
+		labels, nil, logging.Logger{Entry: logrus.NewEntry(l)})
+}
+
+func TestCreateFromTemplateAssignsDefaultLabels(t *testing.T) {
+	reports, labels := newReportsStub(), &labelsStub{}
+	s := newTestService(reports, labels, template.Template{
+		HeaderPattern: "Отчёт {{author}}",
+		DefaultLabels: []string{"отчёт", "черновик"},
+	})
+
+	var n report.Report
+	if err := s.CreateFromTemplate(1, 1, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Header != "Отчёт Иванов" {
+		t.Errorf("header = %q", n.Header)
+	}
+	if len(n.Labels) != 2 || len(reports.reports) != 1 {
+		t.Errorf("got %d labels and %d reports, want 2 and 1", len(n.Labels), len(reports.reports))
+	}
+}
+
+func TestCreateFromTemplateRemovesReportWhenLabelFails(t *testing.T) {
+	reports, labels := newReportsStub(), &labelsStub{failOn: "черновик"}
+	s := newTestService(reports, labels, template.Template{
+		HeaderPattern: "Отчёт",
+		DefaultLabels: []string{"отчёт", "черновик"},
+	})
+
+	var n report.Report
+	if err := s.CreateFromTemplate(1, 1, &n); err == nil {
+		t.Fatal("report was created although a default label failed")
+	}
+	if len(reports.reports) != 0 {
+		t.Errorf("half built report was left behind: %+v", reports.reports)
+	}
+	if n.ID != 0 || n.Labels != nil {
+		t.Errorf("report of a failed creation is returned: %+v", n)
+	}
+}
+
+func TestFinalizedReportCanNotBeChanged(t *testing.T) {
+	reports := newReportsStub()
+	reports.reports[1] = report.Report{ID: 1, Header: "Протокол", Status: report.StatusFinalized}
+	s := newTestService(reports, &labelsStub{}, template.Template{})
+
+	err := s.Update(1, report.Report{ID: 1, Header: "Исправленный протокол"}, false)
+	if !errors.Is(err, &report.AlreadyFinalizedErr{}) {
+		t.Errorf("update err = %v, want AlreadyFinalizedErr", err)
+	}
+	if err = s.Delete(1, 1); !errors.Is(err, &report.AlreadyFinalizedErr{}) {
+		t.Errorf("delete err = %v, want AlreadyFinalizedErr", err)
+	}
+	if n := reports.reports[1]; n.Header != "Протокол" {
+		t.Errorf("finalized report was changed: %+v", n)
+	}
+}
+
+func TestDraftReportCanBeChanged(t *testing.T) {
+	reports := newReportsStub()
+	reports.reports[1] = report.Report{ID: 1, Header: "Черновик", Status: report.StatusDraft}
+	s := newTestService(reports, &labelsStub{}, template.Template{})
+
+	if err := s.Update(1, report.Report{ID: 1, Header: "Отчёт"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if n := reports.reports[1]; n.Header != "Отчёт" {
+		t.Errorf("header = %q, want the updated one", n.Header)
+	}
+	if err := s.Delete(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(1, 1); !errors.Is(err, &report.ReportNotFoundErr{}) {
+		t.Errorf("second delete err = %v, want ReportNotFoundErr", err)
+	}
+}
@@ -1,19 +1,49 @@
 package service
 
 import (
+	"context"
+	"io"
 	"reports_system/internal/model/account"
+	"reports_system/internal/model/attachment"
 	"reports_system/internal/model/label"
 	"reports_system/internal/model/report"
+	"reports_system/internal/model/template"
 	"reports_system/internal/repository"
 	authService "reports_system/internal/service/account"
+	adminService "reports_system/internal/service/admin"
+	apiKeyService "reports_system/internal/service/apikey"
+	attachmentService "reports_system/internal/service/attachment"
 	labelService "reports_system/internal/service/label"
+	numberingService "reports_system/internal/service/numbering"
+	oidcService "reports_system/internal/service/oidc"
+	organizationService "reports_system/internal/service/organization"
+	profileService "reports_system/internal/service/profile"
 	reportService "reports_system/internal/service/report"
+	templateService "reports_system/internal/service/template"
+	"reports_system/internal/session"
 	"reports_system/pkg/logging"
+	"reports_system/pkg/mail"
+	"reports_system/pkg/storage"
 )
 
 type Account interface {
-	CreateAccount(u *account.Account) error
-	GenerateJWT(u *account.Account) (string, error)
+	CreateAccount(u *account.Account, invite string) error
+	GenerateJWT(u *account.Account, client account.Client) (account.Tokens, error)
+	Refresh(refreshToken string) (account.Tokens, error)
+	Logout(sessionID string) error
+	CheckSession(sessionID string) (account.SessionState, error)
+	GetSessions(userID int) ([]account.Session, error)
+	RevokeSession(userID int, sessionID string) error
+	ChangePassword(userID int, sessionID, oldPassword, newPassword string) error
+	RequestPasswordReset(email string) error
+	ResetPassword(token, password string) error
+	VerifySecondFactor(challenge, code string) (account.Tokens, account.Account, error)
+	EnrollTOTP(userID int) (account.Enrollment, error)
+	ConfirmTOTP(userID int, code string) ([]string, error)
+	DisableTOTP(userID int, password string) error
+	VerifyEmail(token string) error
+	ResendVerification(email string) error
+	Search(q account.DirectoryQuery) ([]account.Account, error)
 	GetOne(userID int) (account.Account, error)
 }
 
@@ -24,6 +54,9 @@ type Report interface {
 	Delete(userID, reportID int) error
 	Update(userID int, n report.Report, needBodyUpdate bool) error
 	FindByLabels(userID int, labelNames []string) ([]report.Report, error)
+	Search(userID int, query string, labelNames []string) ([]report.Report, error)
+	Export(userID int, labelNames []string, fn func(row report.RegistryRow) error) error
+	CreateFromTemplate(userID, templateID int, n *report.Report) error
 }
 
 type Label interface {
@@ -32,20 +65,115 @@ type Label interface {
 	GetAllByReport(userID, reportID int) ([]label.Label, error)
 	GetOne(userID, labelID int) (label.Label, error)
 	Delete(userID, labelID int) error
-	Update(userID, labelID int, t label.Label) error
+	Update(userID, labelID int, u label.Update) error
+	Move(userID, labelID int, parentID *int) error
 	Detach(userID, labelID, reportID int) error
 }
 
+type Attachment interface {
+	Upload(userID, reportID int, a *attachment.Attachment, r io.Reader) error
+	GetAll(userID, reportID int) ([]attachment.Attachment, error)
+	Download(userID, reportID, attachmentID int) (attachment.Attachment, io.ReadCloser, error)
+	Delete(userID, reportID, attachmentID int) error
+}
+
+type Template interface {
+	Create(userID int, t *template.Template) error
+	GetAll(userID int) ([]template.Template, error)
+	GetOne(userID, templateID int) (template.Template, error)
+	Update(userID int, t template.Template) error
+	Delete(userID, templateID int) error
+}
+
+type Profile interface {
+	Get(userID int) (account.Account, error)
+	Update(userID int, u account.ProfileUpdate) (account.Account, error)
+	RequestDeletion(userID int) error
+	Delete(userID int, d account.Deletion) error
+	Export(userID int, w io.Writer) error
+}
+
+type Admin interface {
+	Bootstrap(usernames []string)
+	GetAll(q account.DirectoryQuery) ([]account.Account, error)
+	Deactivate(adminID, userID int) error
+	Reactivate(adminID, userID int) error
+	ForcePasswordReset(adminID, userID int) error
+	SetRole(adminID, userID int, role string) error
+	SetClearance(adminID, userID int, clearance string) error
+	Impersonate(adminID, userID int, client account.Client) (account.Tokens, account.Account, error)
+	GetAudit(organizationID, limit int) ([]account.AuditEntry, error)
+	Invite(adminID int, i *account.Invitation) error
+	GetInvitations(organizationID int) ([]account.Invitation, error)
+	RevokeInvitation(adminID, organizationID, invitationID int) error
+}
+
+type OIDC interface {
+	Begin(ctx context.Context) (string, string, error)
+	Complete(ctx context.Context, code, state string, client account.Client) (account.Tokens, account.Account, error)
+}
+
+type APIKey interface {
+	Create(k *account.APIKey) (string, error)
+	GetAll(userID int) ([]account.APIKey, error)
+	Revoke(userID, keyID int) error
+	CheckAPIKey(key string) (account.APIKeyState, error)
+}
+
+type Numbering interface {
+	Finalize(userID, reportID int) (string, error)
+}
+
+type Organization interface {
+	Bootstrap(organizations []session.Organization)
+	GetOne(organizationID int) (account.Organization, error)
+}
+
 type Service struct {
 	Account
 	Report
 	Label
+	Attachment
+	Template
+	Numbering
+	Profile
+	Admin
+	OIDC
+	APIKey
+	Organization
+
+	Indexer *attachmentService.Indexer
 }
 
-func New(repo *repository.Repository, logger logging.Logger) *Service {
+func New(repo *repository.Repository, blobs storage.Storage, mailer mail.Sender, cfg *session.Config, logger logging.Logger) *Service {
+	indexer := attachmentService.NewIndexer(repo.Attachment, blobs, logger)
+	labels := labelService.NewService(repo.Label, repo.Report, logger)
+	authenticator, err := authService.NewAuthenticator(repo.Account, cfg.Auth, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	accounts := authService.NewService(
+		repo.Account, authenticator, repo.Session, repo.Password, repo.TwoFactor, repo.Verification, repo.Invitation,
+		authService.NewLimiter(repo.LoginAttempt, cfg.Lockout),
+		mailer, cfg.JWT, cfg.PasswordReset, cfg.TwoFactor, cfg.Registration, cfg.Password, logger,
+	)
+	profiles := profileService.NewService(
+		repo.Account, repo.Session, repo.Profile, repo.Report, repo.Label, repo.Attachment, repo.Template,
+		accounts, mailer, cfg.Deletion, blobs, logger,
+	)
+
 	return &Service{
-		Account: authService.NewService(repo.Account),
-		Report:  reportService.NewService(repo.Report, repo.Label, logger),
-		Label:   labelService.NewService(repo.Label, repo.Report, logger),
+		Account:      accounts,
+		Report:       reportService.NewService(repo.Report, repo.Label, repo.Attachment, repo.Template, repo.Account, labels, blobs, logger),
+		Label:        labels,
+		Attachment:   attachmentService.NewService(repo.Attachment, repo.Report, blobs, indexer, cfg.Attachments.MaxSize, logger),
+		Template:     templateService.NewService(repo.Template, logger),
+		Numbering:    numberingService.NewService(repo.Numbering, repo.Account, cfg.Numbering, logger),
+		Profile:      profiles,
+		Admin:        adminService.NewService(repo.Account, repo.Admin, repo.Invitation, accounts, logger),
+		APIKey:       apiKeyService.NewService(repo.APIKey, cfg.APIKeys, logger),
+		OIDC:         oidcService.NewService(repo.Account, repo.OIDC, accounts, cfg.OIDC, logger),
+		Organization: organizationService.NewService(repo.Organization, logger),
+		Indexer:      indexer,
 	}
 }
@@ -0,0 +1,68 @@
+package template
+
+import (
+	"reports_system/internal/model/template"
+	"reports_system/internal/repository"
+	"reports_system/pkg/logging"
+)
+
+type Service struct {
+	templatesRepository repository.Template
+	logger              logging.Logger
+}
+
+func NewService(templatesRepository repository.Template, logger logging.Logger) *Service {
+	return &Service{templatesRepository: templatesRepository, logger: logger}
+}
+
+func (s *Service) Create(userID int, t *template.Template) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if t.DefaultLabels == nil {
+		t.DefaultLabels = []string{}
+	}
+
+	return s.templatesRepository.Create(userID, t)
+}
+
+func (s *Service) GetAll(userID int) ([]template.Template, error) {
+	return s.templatesRepository.GetAll(userID)
+}
+
+func (s *Service) GetOne(userID, templateID int) (template.Template, error) {
+	return s.templatesRepository.GetOne(userID, templateID)
+}
+
+func (s *Service) Update(userID int, t template.Template) error {
+	prev, err := s.templatesRepository.GetOne(userID, t.ID)
+	if err != nil {
+		return err
+	}
+
+	if t.Name == "" {
+		t.Name = prev.Name
+	}
+	if t.HeaderPattern == "" {
+		t.HeaderPattern = prev.HeaderPattern
+	}
+	if t.BodySkeleton == "" {
+		t.BodySkeleton = prev.BodySkeleton
+	}
+	if t.DefaultLabels == nil {
+		t.DefaultLabels = prev.DefaultLabels
+	}
+
+	if err = t.Validate(); err != nil {
+		return err
+	}
+
+	return s.templatesRepository.Update(userID, t)
+}
+
+func (s *Service) Delete(userID, templateID int) error {
+	if _, err := s.templatesRepository.GetOne(userID, templateID); err != nil {
+		return err
+	}
+	return s.templatesRepository.Delete(userID, templateID)
+}
@@ -1,9 +1,11 @@
 package session
 
 import (
+	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"reports_system/pkg/logging"
 	"sync"
+	"time"
 )
 
 const (
@@ -23,22 +25,266 @@ type DB struct {
 type Listen struct {
 	Port   string `yaml:"port"`
 	BindIP string `yaml:"bind_ip"`
+	// TrustedProxies are the addresses allowed to set X-Forwarded-For, the
+	// client ip of requests from anywhere else is their remote address.
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
+// JWTKey is a PEM file with a PKCS#8 private key, RSA for RS256 or Ed25519
+// for EdDSA. A retired key may be given by its public part only.
+type JWTKey struct {
+	ID   string `yaml:"id"`
+	Path string `yaml:"path"`
+}
+
+// JWT tokens are signed with SigningKey and verified with any of Keys. To
+// rotate, add the new key to every backend first, then switch SigningKey and
+// remove the old key once AccessTTL has passed. Without SigningKey a key is
+// generated on start, which only works for a single backend. Sessions of
+// admins acting as a user end after ImpersonationTTL and can't be refreshed.
 type JWT struct {
-	Secret string `yaml:"secret"`
+	Keys             []JWTKey      `yaml:"keys"`
+	SigningKey       string        `yaml:"signing_key"`
+	AccessTTL        time.Duration `yaml:"access_ttl" env-default:"15m"`
+	RefreshTTL       time.Duration `yaml:"refresh_ttl" env-default:"720h"`
+	ImpersonationTTL time.Duration `yaml:"impersonation_ttl" env-default:"15m"`
+}
+
+type S3 struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	UseSSL    bool   `yaml:"use_ssl"`
+}
+
+type Storage struct {
+	Type string `yaml:"type" env-default:"local"`
+	Path string `yaml:"path" env-default:"build/storage"`
+	S3   S3     `yaml:"s3"`
+}
+
+// EncryptionKey is a 256-bit master key, either inline as base64 in Key or
+// as a file with the raw 32 bytes at Path.
+type EncryptionKey struct {
+	ID   string `yaml:"id"`
+	Key  string `yaml:"key"`
+	Path string `yaml:"path"`
+}
+
+// Encryption of reports and attachments at rest. Data keys are wrapped
+// with CurrentKey and unwrapped with any of Keys. To rotate, add the new key
+// to every backend first, then switch CurrentKey, run cmd/rotatekeys and
+// remove the old key once it's done. The search index is keyed with a key
+// derived from IndexKey, it has to stay among Keys through rotations, the
+// index is unusable once it changes.
+type Encryption struct {
+	Keys       []EncryptionKey `yaml:"keys"`
+	CurrentKey string          `yaml:"current_key"`
+	IndexKey   string          `yaml:"index_key"`
+}
+
+type Attachments struct {
+	MaxSize int64 `yaml:"max_size" env-default:"26214400"`
+}
+
+// Numbering describes registration numbers of finalized protocols. Patterns may
+// contain {{seq}}, {{year}} and {{department}}, departments can override the default.
+type Numbering struct {
+	Format      string            `yaml:"format" env-default:"№ {{seq}}/{{year}}-{{department}}"`
+	Departments map[string]string `yaml:"departments"`
+}
+
+type SMTP struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port" env-default:"587"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Mail type is one of smtp, file or log.
+type Mail struct {
+	Type string `yaml:"type" env-default:"log"`
+	From string `yaml:"from" env-default:"reports@localhost"`
+	Path string `yaml:"path" env-default:"build/mail.log"`
+	SMTP SMTP   `yaml:"smtp"`
+}
+
+// PasswordReset URL is the frontend page receiving the token as ?token= parameter.
+type PasswordReset struct {
+	TTL time.Duration `yaml:"ttl" env-default:"1h"`
+	URL string        `yaml:"url" env-default:"http://localhost/reset-password"`
+}
+
+// AccountDeletion TTL is how long the token mailed to accounts without a
+// password to confirm their deletion is valid.
+type AccountDeletion struct {
+	TTL time.Duration `yaml:"ttl" env-default:"1h"`
+}
+
+// TwoFactor Issuer is the name authenticator apps show next to the codes.
+type TwoFactor struct {
+	Issuer       string        `yaml:"issuer" env-default:"Reports System"`
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+}
+
+// Lockout of logins after too many failures in a row, counted per username and
+// per ip. Every failure over the threshold doubles the delay up to MaxDelay.
+type Lockout struct {
+	UserThreshold int           `yaml:"user_threshold" env-default:"5"`
+	IPThreshold   int           `yaml:"ip_threshold" env-default:"20"`
+	BaseDelay     time.Duration `yaml:"base_delay" env-default:"30s"`
+	MaxDelay      time.Duration `yaml:"max_delay" env-default:"1h"`
+	Window        time.Duration `yaml:"window" env-default:"24h"`
+}
+
+// Argon2 Memory is in KiB.
+type Argon2 struct {
+	Time    uint32 `yaml:"time" env-default:"3"`
+	Memory  uint32 `yaml:"memory" env-default:"65536"`
+	Threads uint8  `yaml:"threads" env-default:"2"`
+	KeyLen  uint32 `yaml:"key_len" env-default:"32"`
+}
+
+// Password Algorithm is bcrypt or argon2id. Hashes made with other settings keep
+// working and are upgraded on the next successful login. BreachedList is a file
+// with leaked passwords, one per line, that can't be used. With bcrypt passwords
+// are also limited to the 72 bytes it hashes, whatever MaxLength says.
+type Password struct {
+	Algorithm    string `yaml:"algorithm" env-default:"bcrypt"`
+	BcryptCost   int    `yaml:"bcrypt_cost" env-default:"12"`
+	Argon2       Argon2 `yaml:"argon2"`
+	MinLength    int    `yaml:"min_length" env-default:"8"`
+	MaxLength    int    `yaml:"max_length" env-default:"128"`
+	BreachedList string `yaml:"breached_list"`
+}
+
+// Registration AllowedDomains limits emails of new accounts, e.g. bmstu.ru,
+// any domain is allowed when it is empty. With RequireVerification accounts
+// can't log in until the email is verified, VerifyURL is put into the email.
+// Invitations from admins lead to InviteURL, the registration page that sends
+// the token along, and are valid for InvitationTTL unless the admin decides.
+type Registration struct {
+	AllowedDomains      []string      `yaml:"allowed_domains"`
+	RequireVerification bool          `yaml:"require_verification"`
+	VerificationTTL     time.Duration `yaml:"verification_ttl" env-default:"48h"`
+	VerifyURL           string        `yaml:"verify_url" env-default:"http://localhost/api/v1/accounts/verify"`
+	InvitationTTL       time.Duration `yaml:"invitation_ttl" env-default:"168h"`
+	InviteURL           string        `yaml:"invite_url" env-default:"http://localhost/register"`
+}
+
+// LDAP finds users with UserFilter under BaseDN, binding as BindDN, and checks
+// passwords by binding as the user. The defaults suit Active Directory, for
+// OpenLDAP set the filter and UsernameAttribute to uid.
+type LDAP struct {
+	URL                 string        `yaml:"url" env-default:"ldap://localhost:389"`
+	StartTLS            bool          `yaml:"start_tls"`
+	InsecureSkipVerify  bool          `yaml:"insecure_skip_verify"`
+	BindDN              string        `yaml:"bind_dn"`
+	BindPassword        string        `yaml:"bind_password"`
+	BaseDN              string        `yaml:"base_dn"`
+	UserFilter          string        `yaml:"user_filter" env-default:"(&(objectClass=user)(sAMAccountName=%s))"`
+	UsernameAttribute   string        `yaml:"username_attribute" env-default:"sAMAccountName"`
+	NameAttribute       string        `yaml:"name_attribute" env-default:"displayName"`
+	EmailAttribute      string        `yaml:"email_attribute" env-default:"mail"`
+	DepartmentAttribute string        `yaml:"department_attribute" env-default:"department"`
+	Timeout             time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+// Auth Backends are tried in order until one knows the username, local checks
+// the password stored in the database and ldap binds to the directory.
+type Auth struct {
+	Backends []string `yaml:"backends" env-default:"local"`
+	LDAP     LDAP     `yaml:"ldap"`
+}
+
+// OIDCGroup maps a group of the identity provider to a department and a role,
+// either may be empty.
+type OIDCGroup struct {
+	Group      string `yaml:"group"`
+	Department string `yaml:"department"`
+	Role       string `yaml:"role"`
+}
+
+// OIDC enables single sign-on with an OpenID Connect provider. Accounts are
+// created on the first login, GroupsClaim of the ID token is mapped to the
+// department and role with Groups on every login.
+type OIDC struct {
+	Enabled      bool          `yaml:"enabled"`
+	Issuer       string        `yaml:"issuer"`
+	ClientID     string        `yaml:"client_id"`
+	ClientSecret string        `yaml:"client_secret"`
+	RedirectURL  string        `yaml:"redirect_url" env-default:"http://localhost/api/v1/accounts/oidc/callback"`
+	Scopes       []string      `yaml:"scopes" env-default:"openid,profile,email"`
+	GroupsClaim  string        `yaml:"groups_claim" env-default:"groups"`
+	Groups       []OIDCGroup   `yaml:"groups"`
+	LoginTTL     time.Duration `yaml:"login_ttl" env-default:"10m"`
+}
+
+// APIKeys DefaultTTL applies when the user doesn't choose the expiry, which
+// can't be later than MaxTTL.
+type APIKeys struct {
+	DefaultTTL time.Duration `yaml:"default_ttl" env-default:"2160h"`
+	MaxTTL     time.Duration `yaml:"max_ttl" env-default:"8760h"`
+}
+
+// Admin Bootstrap lists usernames that are granted the admin role on start,
+// so the first admin doesn't have to be appointed in the database.
+type Admin struct {
+	Bootstrap []string `yaml:"bootstrap"`
+}
+
+// Organization is a tenant, accounts with emails in one of its Domains
+// belong to it. Accounts with other emails go to the "default" one. Anyone
+// can type any email, so Domains require Registration.RequireVerification.
+type Organization struct {
+	Slug    string   `yaml:"slug"`
+	Name    string   `yaml:"name"`
+	Domains []string `yaml:"domains"`
 }
 
 type Config struct {
-	IsDebug *bool  `yaml:"is_debug"`
-	DB      DB     `yaml:"db"`
-	Listen  Listen `yaml:"listen"`
-	JWT     JWT    `yaml:"jwt"`
+	IsDebug       *bool           `yaml:"is_debug"`
+	DB            DB              `yaml:"db"`
+	Listen        Listen          `yaml:"listen"`
+	JWT           JWT             `yaml:"jwt"`
+	Storage       Storage         `yaml:"storage"`
+	Encryption    Encryption      `yaml:"encryption"`
+	Attachments   Attachments     `yaml:"attachments"`
+	Numbering     Numbering       `yaml:"numbering"`
+	Mail          Mail            `yaml:"mail"`
+	PasswordReset PasswordReset   `yaml:"password_reset"`
+	Deletion      AccountDeletion `yaml:"account_deletion"`
+	TwoFactor     TwoFactor       `yaml:"two_factor"`
+	Lockout       Lockout         `yaml:"lockout"`
+	Password      Password        `yaml:"password"`
+	Registration  Registration    `yaml:"registration"`
+	Admin         Admin           `yaml:"admin"`
+	OIDC          OIDC            `yaml:"oidc"`
+	Auth          Auth            `yaml:"auth"`
+	APIKeys       APIKeys         `yaml:"api_keys"`
+	Organizations []Organization  `yaml:"organizations"`
 }
 
 var instance *Config
 var once sync.Once
 
+// Check finds settings that are fine one by one but unsafe together, the
+// application refuses to start with them.
+func (c *Config) Check() error {
+	if c.Registration.RequireVerification {
+		return nil
+	}
+	for _, o := range c.Organizations {
+		if len(o.Domains) > 0 {
+			return fmt.Errorf("organization %q is chosen by email domain, "+
+				"registration.require_verification has to be enabled for that", o.Slug)
+		}
+	}
+	return nil
+}
+
 func GetConfig() *Config {
 	once.Do(func() {
 		logger := logging.GetLogger()
@@ -0,0 +1,60 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+func readConfig(t *testing.T, path string) *Config {
+	t.Helper()
+
+	var cfg Config
+	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	return &cfg
+}
+
+// The mirror is another backend over the same database, so its config has to
+// be the same as the one of the main backends, section by section.
+func TestMirrorConfigMatches(t *testing.T) {
+	main := reflect.ValueOf(*readConfig(t, "../../etc/config/config.yml"))
+	mirror := reflect.ValueOf(*readConfig(t, "../../etc/config/mirrow.yml"))
+
+	for i := 0; i < main.NumField(); i++ {
+		got, want := mirror.Field(i).Interface(), main.Field(i).Interface()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s of the mirror is %+v, want %+v", main.Type().Field(i).Name, got, want)
+		}
+	}
+}
+
+func TestCheckRequiresVerificationForDomains(t *testing.T) {
+	if err := readConfig(t, "../../etc/config/config.yml").Check(); err != nil {
+		t.Fatalf("the shipped config: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		verify  bool
+		domains []string
+		valid   bool
+	}{
+		{"domains with verification", true, []string{"bmstu.ru"}, true},
+		{"domains without verification", false, []string{"bmstu.ru"}, false},
+		{"no domains without verification", false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Registration:  Registration{RequireVerification: tt.verify},
+				Organizations: []Organization{{Slug: "default", Name: "МГТУ", Domains: tt.domains}},
+			}
+			if err := cfg.Check(); (err == nil) != tt.valid {
+				t.Fatalf("err = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
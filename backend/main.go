@@ -4,16 +4,29 @@ import (
 	"reports_system/cmd/server"
 	_ "reports_system/docs"
 	"reports_system/internal/handlers/account"
+	"reports_system/internal/handlers/admin"
+	"reports_system/internal/handlers/apikey"
+	"reports_system/internal/handlers/attachment"
 	"reports_system/internal/handlers/label"
+	"reports_system/internal/handlers/middleware"
+	"reports_system/internal/handlers/numbering"
+	"reports_system/internal/handlers/organization"
+	"reports_system/internal/handlers/profile"
 	"reports_system/internal/handlers/report"
+	"reports_system/internal/handlers/sso"
+	"reports_system/internal/handlers/template"
+	"reports_system/internal/handlers/wellknown"
 	"reports_system/internal/mapper"
 	"reports_system/internal/repository"
 	"reports_system/internal/service"
 	"reports_system/internal/session"
 	"reports_system/pkg/client/psqlclient"
+	"reports_system/pkg/envelope"
+	"reports_system/pkg/jwt"
 	"reports_system/pkg/logging"
+	"reports_system/pkg/mail"
+	"reports_system/pkg/storage"
 
-	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -30,11 +43,22 @@ import (
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
+
+// @securityDefinitions.apikey PersonalAPIKey
+// @in header
+// @name X-API-Key
 func main() {
 	logging.Init()
 	logger := logging.GetLogger()
 
 	cfg := session.GetConfig()
+	if err := cfg.Check(); err != nil {
+		logger.Fatal(err)
+	}
+
+	if err := jwt.Init(); err != nil {
+		logger.Fatal(err)
+	}
 
 	client, err := psqlclient.NewClient(cfg.DB)
 	if err != nil {
@@ -42,17 +66,44 @@ func main() {
 	}
 
 	logger.Info("Create new gin router")
-	router := gin.New()
+	router, err := server.NewRouter(cfg.Listen)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
 	router.GET("api/v1/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	logger.Info("loading encryption keys")
+	keys, err := envelope.Load(cfg.Encryption)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	logger.Info("initializing repository")
-	repos := repository.New(client, logger)
+	repos := repository.New(client, keys, logger)
+
+	logger.Info("initializing attachments storage")
+	backend, err := storage.New(cfg.Storage)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	blobs := storage.NewEncrypted(backend, keys)
+
+	logger.Info("initializing mail sender")
+	mailer, err := mail.New(cfg.Mail, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
 	logger.Info("initializing services")
-	services := service.New(repos, logger)
+	services := service.New(repos, blobs, mailer, cfg, logger)
+	go services.Indexer.Run()
+	services.Organization.Bootstrap(cfg.Organizations)
+	services.Admin.Bootstrap(cfg.Admin.Bootstrap)
 	mappers := mapper.New(logger)
 
+	middleware.Init(services.Account, services.APIKey)
+
 	accountHandler := account.NewHandler(logger, services.Account, mappers.Account)
 	accountHandler.Register(router)
 
@@ -62,5 +113,32 @@ func main() {
 	labelsHandler := label.NewHandler(logger, services.Label, mappers.Label)
 	labelsHandler.Register(router)
 
+	attachmentsHandler := attachment.NewHandler(logger, services.Attachment, mappers.Attachment, cfg.Attachments.MaxSize)
+	attachmentsHandler.Register(router)
+
+	templatesHandler := template.NewHandler(logger, services.Template, mappers.Template)
+	templatesHandler.Register(router)
+
+	numberingHandler := numbering.NewHandler(logger, services.Numbering)
+	numberingHandler.Register(router)
+
+	profileHandler := profile.NewHandler(logger, services.Profile, mappers.Account)
+	profileHandler.Register(router)
+
+	adminHandler := admin.NewHandler(logger, services.Admin, mappers.Account)
+	adminHandler.Register(router)
+
+	ssoHandler := sso.NewHandler(logger, services.OIDC, mappers.Account)
+	ssoHandler.Register(router)
+
+	apiKeyHandler := apikey.NewHandler(logger, services.APIKey, mappers.Account)
+	apiKeyHandler.Register(router)
+
+	organizationHandler := organization.NewHandler(logger, services.Organization, mappers.Account)
+	organizationHandler.Register(router)
+
+	wellKnownHandler := wellknown.NewHandler(logger)
+	wellKnownHandler.Register(router)
+
 	server.Run(cfg, router, logger)
 }
@@ -0,0 +1,298 @@
+// Package envelope encrypts data at rest with AES-256-GCM. Every value gets
+// its own data key, which is stored next to the ciphertext wrapped by one of
+// the master keys. Rotating the master key only re-wraps the data keys, the
+// data itself is never encrypted again.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reports_system/internal/session"
+	"strings"
+)
+
+const (
+	// KeySize of master and data keys, AES-256.
+	KeySize = 32
+
+	// magic starts every sealed value, values without it were stored before
+	// the encryption was turned on and are returned as they are.
+	magic = "RSE1"
+	// textPrefix marks sealed values kept in text columns as base64.
+	textPrefix = "$rse1$"
+)
+
+var (
+	ErrUnknownKey = errors.New("value is sealed with an unknown master key")
+	ErrMalformed  = errors.New("sealed value is malformed")
+)
+
+// Keyring seals with the current master key and opens values sealed with any
+// of the known ones. The blind index is keyed with a key derived from the
+// index master key, which unlike the current one never changes.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+	index   []byte
+}
+
+func NewKeyring(current, index string, keys map[string][]byte) (*Keyring, error) {
+	k := &Keyring{current: current, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("encryption key id %q must be 1 to 255 bytes long", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		k.keys[id] = aead
+	}
+	if _, ok := k.keys[current]; !ok {
+		return nil, fmt.Errorf("current encryption key %q is not among the keys", current)
+	}
+	if _, ok := k.keys[index]; !ok {
+		return nil, fmt.Errorf("index encryption key %q is not among the keys", index)
+	}
+	k.index = indexKey(keys[index])
+	return k, nil
+}
+
+// Load reads the master keys from the config, a key is given either inline
+// as base64 or as a file with the raw 32 bytes.
+func Load(cfg session.Encryption) (*Keyring, error) {
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		if _, ok := keys[k.ID]; ok {
+			return nil, fmt.Errorf("encryption key id %q is used twice", k.ID)
+		}
+
+		var (
+			key []byte
+			err error
+		)
+		switch {
+		case k.Key != "" && k.Path != "":
+			return nil, fmt.Errorf("encryption key %q has both key and path", k.ID)
+		case k.Key != "":
+			key, err = base64.StdEncoding.DecodeString(k.Key)
+		case k.Path != "":
+			key, err = os.ReadFile(k.Path)
+		default:
+			return nil, fmt.Errorf("encryption key %q has neither key nor path", k.ID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load encryption key %q due to error %w", k.ID, err)
+		}
+		keys[k.ID] = key
+	}
+	return NewKeyring(cfg.CurrentKey, cfg.IndexKey, keys)
+}
+
+// Seal encrypts plaintext with a fresh data key wrapped by the current key.
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := k.wrap(k.current, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	out := header(k.current, wrapped)
+	nonce := make([]byte, data.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return data.Seal(out, nonce, plaintext, []byte(magic)), nil
+}
+
+// Open decrypts a sealed value, values that were never sealed are returned as is.
+func (k *Keyring) Open(sealed []byte) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return sealed, nil
+	}
+
+	s, err := parse(sealed)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := k.unwrap(s.keyID, s.wrapped)
+	if err != nil {
+		return nil, err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.payload) < data.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := s.payload[:data.NonceSize()], s.payload[data.NonceSize():]
+	plaintext, err := data.Open(nil, nonce, ciphertext, []byte(magic))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
+
+// Rewrap wraps the data key of a sealed value with the current key, values
+// that were never sealed get sealed. It reports whether the value changed.
+func (k *Keyring) Rewrap(sealed []byte) ([]byte, bool, error) {
+	if !IsSealed(sealed) {
+		out, err := k.Seal(sealed)
+		return out, err == nil, err
+	}
+
+	s, err := parse(sealed)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.keyID == k.current {
+		return sealed, false, nil
+	}
+
+	dataKey, err := k.unwrap(s.keyID, s.wrapped)
+	if err != nil {
+		return nil, false, err
+	}
+	wrapped, err := k.wrap(k.current, dataKey)
+	if err != nil {
+		return nil, false, err
+	}
+	return append(header(k.current, wrapped), s.payload...), true, nil
+}
+
+// SealString is Seal for text columns.
+func (k *Keyring) SealString(plaintext string) (string, error) {
+	sealed, err := k.Seal([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return textPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenString is Open for text columns.
+func (k *Keyring) OpenString(s string) (string, error) {
+	if !strings.HasPrefix(s, textPrefix) {
+		return s, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(s[len(textPrefix):])
+	if err != nil || !IsSealed(sealed) {
+		return "", ErrMalformed
+	}
+	plaintext, err := k.Open(sealed)
+	return string(plaintext), err
+}
+
+// RewrapString is Rewrap for text columns.
+func (k *Keyring) RewrapString(s string) (string, bool, error) {
+	if !strings.HasPrefix(s, textPrefix) {
+		out, err := k.SealString(s)
+		return out, err == nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(s[len(textPrefix):])
+	if err != nil || !IsSealed(sealed) {
+		return "", false, ErrMalformed
+	}
+	out, changed, err := k.Rewrap(sealed)
+	if err != nil || !changed {
+		return s, false, err
+	}
+	return textPrefix + base64.StdEncoding.EncodeToString(out), true, nil
+}
+
+func IsSealed(b []byte) bool {
+	return len(b) >= len(magic) && string(b[:len(magic)]) == magic
+}
+
+func (k *Keyring) wrap(keyID string, dataKey []byte) ([]byte, error) {
+	master := k.keys[keyID]
+	nonce := make([]byte, master.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return master.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+func (k *Keyring) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	master, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if len(wrapped) < master.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := wrapped[:master.NonceSize()], wrapped[master.NonceSize():]
+	dataKey, err := master.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return dataKey, nil
+}
+
+// sealed value layout: magic, key id length (1 byte), key id, wrapped data
+// key length (2 bytes), wrapped data key, then nonce and ciphertext.
+type sealedValue struct {
+	keyID   string
+	wrapped []byte
+	payload []byte
+}
+
+func header(keyID string, wrapped []byte) []byte {
+	out := make([]byte, 0, len(magic)+1+len(keyID)+2+len(wrapped))
+	out = append(out, magic...)
+	out = append(out, byte(len(keyID)))
+	out = append(out, keyID...)
+	out = append(out, byte(len(wrapped)>>8), byte(len(wrapped)))
+	return append(out, wrapped...)
+}
+
+func parse(b []byte) (sealedValue, error) {
+	var s sealedValue
+
+	b = b[len(magic):]
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return s, ErrMalformed
+	}
+	s.keyID, b = string(b[1:1+int(b[0])]), b[1+int(b[0]):]
+
+	if len(b) < 2 {
+		return s, ErrMalformed
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return s, ErrMalformed
+	}
+	s.wrapped, s.payload = b[2:2+n], b[2+n:]
+	return s, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes long, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,256 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"reports_system/internal/session"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func testKeyring(t *testing.T, current string, keys map[string][]byte) *Keyring {
+	t.Helper()
+
+	k, err := NewKeyring(current, "old", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestSealOpen(t *testing.T) {
+	k := testKeyring(t, "old", map[string][]byte{"old": testKey(1)})
+	plaintext := []byte("текст протокола")
+
+	sealed, err := k.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || bytes.Contains(sealed, plaintext) {
+		t.Fatalf("value is not sealed: %q", sealed)
+	}
+	again, err := k.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(sealed, again) {
+		t.Fatal("the same plaintext is sealed the same way twice")
+	}
+
+	got, err := k.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+}
+
+func TestOpenUnsealed(t *testing.T) {
+	k := testKeyring(t, "old", map[string][]byte{"old": testKey(1)})
+	plaintext := []byte("stored before the encryption")
+
+	got, err := k.Open(plaintext)
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, %v, want the value as it is", got, err)
+	}
+}
+
+func TestOpenRejectsTampering(t *testing.T) {
+	k := testKeyring(t, "old", map[string][]byte{"old": testKey(1)})
+
+	sealed, err := k.Seal([]byte("текст протокола"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]byte{
+		"ciphertext": append(append([]byte{}, sealed[:len(sealed)-1]...), sealed[len(sealed)-1]^1),
+		"truncated":  sealed[:len(magic)+3],
+		"key id":     append([]byte(magic), 200),
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := k.Open(value); !errors.Is(err, ErrMalformed) {
+				t.Fatalf("err = %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := testKeyring(t, "old", map[string][]byte{"old": testKey(1)})
+	sealed, err := old.Seal([]byte("текст протокола"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := testKeyring(t, "new", map[string][]byte{"old": testKey(1), "new": testKey(2)})
+	rewrapped, changed, err := rotated.Rewrap(sealed)
+	if err != nil || !changed {
+		t.Fatalf("rewrap returned %v, %v", changed, err)
+	}
+	if _, changed, err = rotated.Rewrap(rewrapped); err != nil || changed {
+		t.Fatalf("second rewrap returned %v, %v, want no change", changed, err)
+	}
+
+	onlyNew, err := NewKeyring("new", "new", map[string][]byte{"new": testKey(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = onlyNew.Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("opening with a removed key err = %v, want ErrUnknownKey", err)
+	}
+	got, err := onlyNew.Open(rewrapped)
+	if err != nil || string(got) != "текст протокола" {
+		t.Fatalf("got %q, %v after rotation", got, err)
+	}
+
+	// the data itself is never encrypted again, only the data key
+	if !bytes.HasSuffix(rewrapped, sealed[len(sealed)-40:]) {
+		t.Fatal("ciphertext changed on rewrap")
+	}
+}
+
+func TestStrings(t *testing.T) {
+	k := testKeyring(t, "old", map[string][]byte{"old": testKey(1)})
+
+	sealed, err := k.SealString("заголовок")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, textPrefix) || strings.Contains(sealed, "заголовок") {
+		t.Fatalf("value is not sealed: %q", sealed)
+	}
+	if got, err := k.OpenString(sealed); err != nil || got != "заголовок" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if got, err := k.OpenString("заголовок"); err != nil || got != "заголовок" {
+		t.Fatalf("unsealed text came back as %q, %v", got, err)
+	}
+	if _, err := k.OpenString(textPrefix + "not base64"); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("err = %v, want ErrMalformed", err)
+	}
+
+	resealed, changed, err := k.RewrapString("заголовок")
+	if err != nil || !changed || !strings.HasPrefix(resealed, textPrefix) {
+		t.Fatalf("unsealed text was rewrapped into %q, %v, %v", resealed, changed, err)
+	}
+}
+
+func TestNewKeyringChecksKeys(t *testing.T) {
+	tests := map[string]struct {
+		current, index string
+		keys           map[string][]byte
+	}{
+		"short key":       {"a", "a", map[string][]byte{"a": testKey(1)[:16]}},
+		"unknown current": {"b", "a", map[string][]byte{"a": testKey(1)}},
+		"unknown index":   {"a", "b", map[string][]byte{"a": testKey(1)}},
+		"empty id":        {"", "", map[string][]byte{"": testKey(1)}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewKeyring(tt.current, tt.index, tt.keys); err == nil {
+				t.Fatal("keyring was created")
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, testKey(2), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := Load(session.Encryption{
+		Keys: []session.EncryptionKey{
+			{ID: "old", Key: base64.StdEncoding.EncodeToString(testKey(1))},
+			{ID: "new", Path: path},
+		},
+		CurrentKey: "new",
+		IndexKey:   "old",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = k.Seal([]byte("текст")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Load(session.Encryption{
+		Keys: []session.EncryptionKey{
+			{ID: "old", Key: base64.StdEncoding.EncodeToString(testKey(1))},
+			{ID: "old", Path: path},
+		},
+		CurrentKey: "old",
+		IndexKey:   "old",
+	})
+	if err == nil {
+		t.Fatal("a key id used twice was accepted")
+	}
+}
+
+func TestTerms(t *testing.T) {
+	k := testKeyring(t, "old", map[string][]byte{"old": testKey(1)})
+
+	text := k.Terms("Протокол заседания кафедры ИУ7 о секретном отчёте")
+	for _, term := range text {
+		if strings.ContainsAny(strings.ToLower(term), "абвгдеёжзийклмнопрстуфхцчшщъыьэюя") {
+			t.Fatalf("term %q gives the text away", term)
+		}
+	}
+
+	contains := func(terms []string, query string) bool {
+		for _, q := range k.Terms(query) {
+			found := false
+			for _, term := range terms {
+				found = found || term == q
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	for _, query := range []string{"протокол", "ПРОТОКОЛА", "заседание кафедры", "отчет", "секретный", "иу7"} {
+		if !contains(text, query) {
+			t.Errorf("%q is not found", query)
+		}
+	}
+	for _, query := range []string{"приказ", "протокол приказа"} {
+		if contains(text, query) {
+			t.Errorf("%q is found", query)
+		}
+	}
+
+	if got := k.Terms("протокол, протокола и протоколы"); len(got) != 1 {
+		t.Errorf("forms of one word gave %v terms", len(got))
+	}
+	if got := k.Terms("и, а; — ?"); len(got) != 0 {
+		t.Errorf("text without words gave %v", got)
+	}
+
+	// the terms depend on the index key only, rotation doesn't change them
+	rotated, err := NewKeyring("new", "old", map[string][]byte{"old": testKey(1), "new": testKey(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rotated.Terms("протокол"); got[0] != k.Terms("протокол")[0] {
+		t.Error("terms changed with the current key")
+	}
+	other, err := NewKeyring("new", "new", map[string][]byte{"new": testKey(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := other.Terms("протокол"); got[0] == k.Terms("протокол")[0] {
+		t.Error("terms of another index key are the same")
+	}
+}
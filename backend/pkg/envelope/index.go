@@ -0,0 +1,80 @@
+package envelope
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// termSize of a blind index term in bytes, enough to keep unrelated words
+	// from colliding.
+	termSize = 12
+	// minStem keeps the stemmer from cutting short words down to nothing.
+	minStem = 4
+)
+
+// indexLabel separates the index key from other uses of the master key.
+var indexLabel = []byte("reports_system blind index")
+
+// endings are inflections cut off by the stemmer, longer ones go first. It is
+// a light stemmer: word forms mostly end up the same, which is what matters
+// for a search that compares whole terms.
+var endings = []string{
+	"иями",
+	"ами", "ями", "ого", "его", "ому", "ему", "ыми", "ими", "ием", "иям", "иях",
+	"ая", "яя", "ое", "ее", "ые", "ие", "ия", "ии", "ию", "ой", "ей", "ый", "ий", "ом", "ем", "ам", "ям", "ах", "ях",
+	"ов", "ев", "ую", "юю",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+// Terms turns text into blind index terms: every word is lowercased, stemmed
+// and keyed with the index key. The same words give the same terms, so text
+// can be searched without being stored, while the terms tell nothing about
+// the words without the key. Terms come sorted and without repeats.
+func (k *Keyring) Terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if utf8.RuneCountInString(word) < 2 {
+			continue
+		}
+		term := k.term(stem(word))
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+func (k *Keyring) term(word string) string {
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(word))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil)[:termSize])
+}
+
+func stem(word string) string {
+	word = strings.ReplaceAll(word, "ё", "е")
+	for _, ending := range endings {
+		if strings.HasSuffix(word, ending) && utf8.RuneCountInString(word)-utf8.RuneCountInString(ending) >= minStem {
+			return strings.TrimSuffix(word, ending)
+		}
+	}
+	return word
+}
+
+func indexKey(master []byte) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write(indexLabel)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,123 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	CSVContentType  = "text/csv; charset=utf-8"
+	XLSXContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	xlsxSheet = "Sheet1"
+)
+
+// utf8BOM makes Excel detect the encoding of a csv file, otherwise
+// cyrillic text is opened as cp1251.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// formulaPrefixes make spreadsheets treat a cell as a formula. Such cells
+// come from user input, e.g. report headers, so they are always kept as text.
+const formulaPrefixes = "=+-@\t\r"
+
+func escapeCell(v string) string {
+	if v != "" && strings.ContainsRune(formulaPrefixes, rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func escapeRecord(record []string) []string {
+	escaped := make([]string, len(record))
+	for i, v := range record {
+		escaped[i] = escapeCell(v)
+	}
+	return escaped
+}
+
+// Writer writes table rows one by one into the underlying stream.
+type Writer interface {
+	Write(record []string) error
+	Close() error
+}
+
+type csvWriter struct {
+	out     io.Writer
+	w       *csv.Writer
+	started bool
+}
+
+// NewCSVWriter doesn't write anything until the first row, so a response can
+// still be turned into an error after the writer is set up.
+func NewCSVWriter(w io.Writer) (Writer, error) {
+	return &csvWriter{out: w, w: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) Write(record []string) error {
+	if err := c.start(); err != nil {
+		return err
+	}
+	return c.w.Write(escapeRecord(record))
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.start(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) start() error {
+	if c.started {
+		return nil
+	}
+	c.started = true
+	_, err := c.out.Write(utf8BOM)
+	return err
+}
+
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func NewXLSXWriter(w io.Writer) (Writer, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxWriter{out: w, file: file, stream: stream}, nil
+}
+
+func (x *xlsxWriter) Write(record []string) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(record))
+	for i, v := range record {
+		values[i] = escapeCell(v)
+	}
+	return x.stream.SetRow(cell, values)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+
+	if err := x.stream.Flush(); err != nil {
+		return fmt.Errorf("failed to flush xlsx rows due to error %w", err)
+	}
+	_, err := x.file.WriteTo(x.out)
+	return err
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestCSVWriterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCSVWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("writer wrote %d bytes before the first row", buf.Len())
+	}
+
+	if err = w.Write([]string{"=HYPERLINK(\"http://evil\")", "+1", "-1", "@SUM(A1)", "Протокол №1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(buf.Bytes(), utf8BOM) {
+		t.Fatal("csv doesn't start with the utf-8 bom")
+	}
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes()[len(utf8BOM):])).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"'=HYPERLINK(\"http://evil\")", "'+1", "'-1", "'@SUM(A1)", "Протокол №1"}
+	for i, v := range want {
+		if records[0][i] != v {
+			t.Errorf("cell %d = %q, want %q", i, records[0][i], v)
+		}
+	}
+}
+
+func TestCSVWriterWritesBOMForEmptyFile(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewCSVWriter(&buf)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), utf8BOM) {
+		t.Fatalf("got %v, want only the bom", buf.Bytes())
+	}
+}
+
+func TestXLSXWriterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write([]string{"=1+1", "plain"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for cell, want := range map[string]string{"A1": "'=1+1", "B1": "plain"} {
+		got, err := f.GetCellValue(xlsxSheet, cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", cell, got, want)
+		}
+		if formula, _ := f.GetCellFormula(xlsxSheet, cell); formula != "" {
+			t.Errorf("%s has formula %q", cell, formula)
+		}
+	}
+}
@@ -3,34 +3,37 @@ package jwt
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/cristalhq/jwt/v3"
 	"reports_system/internal/session"
 	"time"
 )
 
-const (
-	tokenTTL = 12 * time.Hour
-)
-
 type UserClaims struct {
 	jwt.RegisteredClaims
-	UserID int
+	UserID         int
+	OrganizationID int    `json:"org"`
+	SessionID      string `json:"sid"`
 }
 
-func GenerateAccessToken(id int) (string, error) {
-	key := []byte(session.GetConfig().JWT.Secret)
+// GenerateAccessToken signs with the signing key and puts its id into the header.
+func GenerateAccessToken(id, organizationID int, sessionID string) (string, error) {
+	cfg := session.GetConfig().JWT
 
-	signer, err := jwt.NewSignerHS(jwt.HS256, key)
+	r, err := keys()
 	if err != nil {
 		return "", err
 	}
-	builder := jwt.NewBuilder(signer)
+	builder := jwt.NewBuilder(r.signing.signer, jwt.WithKeyID(r.signing.id))
+
 	claims := UserClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenTTL)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.AccessTTL)),
 		},
-		UserID: id,
+		UserID:         id,
+		OrganizationID: organizationID,
+		SessionID:      sessionID,
 	}
 
 	token, err := builder.Build(claims)
@@ -41,26 +44,55 @@ func GenerateAccessToken(id int) (string, error) {
 	return token.String(), nil
 }
 
-func GetIdFromToken(token string) (int, error) {
-	key := []byte(session.GetConfig().JWT.Secret)
-	verifier, err := jwt.NewVerifierHS(jwt.HS256, key)
+// ParseAccessToken accepts tokens of every configured key, so a rotation
+// doesn't log anyone out.
+func ParseAccessToken(token string) (UserClaims, error) {
+	var uc UserClaims
+
+	verifier, err := verifierFor(token)
 	if err != nil {
-		return 0, err
+		return uc, err
 	}
 
 	tok, err := jwt.ParseAndVerifyString(token, verifier)
 	if err != nil {
-		return 0, err
+		return uc, err
 	}
 
-	var uc UserClaims
 	err = json.Unmarshal(tok.RawClaims(), &uc)
 	if err != nil {
-		return 0, err
+		return uc, err
 	}
 	if valid := uc.IsValidAt(time.Now()); !valid {
-		return 0, errors.New("token has been expired")
+		return uc, errors.New("token has been expired")
 	}
 
-	return uc.UserID, nil
+	return uc, nil
+}
+
+func verifierFor(token string) (jwt.Verifier, error) {
+	r, err := keys()
+	if err != nil {
+		return nil, err
+	}
+
+	tok, err := jwt.ParseString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// the algorithm comes from the key, never from the token, so a token
+	// can't make a public key be used as an HMAC secret
+	kid := tok.Header().KeyID
+	if kid == "" {
+		return nil, errors.New("token has no key id")
+	}
+	k, ok := r.byID[kid]
+	if !ok {
+		return nil, fmt.Errorf("token is signed with unknown key %q", kid)
+	}
+	if tok.Header().Algorithm != k.alg {
+		return nil, fmt.Errorf("token is signed with %s, key %q is %s", tok.Header().Algorithm, kid, k.alg)
+	}
+	return k.verifier, nil
 }
@@ -0,0 +1,63 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cristalhq/jwt/v3"
+	"reports_system/internal/session"
+)
+
+// useKeys loads the ring once for the package, the config file isn't read.
+func useKeys(t *testing.T) *keyRing {
+	t.Helper()
+	ringOnce.Do(func() {
+		ring, ringErr = loadKeys(session.JWT{})
+	})
+	if ringErr != nil {
+		t.Fatal(ringErr)
+	}
+	return ring
+}
+
+func sign(t *testing.T, signer jwt.Signer, opts ...jwt.BuilderOption) string {
+	t.Helper()
+	token, err := jwt.NewBuilder(signer, opts...).Build(UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		UserID:           1,
+		SessionID:        "s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token.String()
+}
+
+func TestKeyIsGeneratedWithoutSigningKey(t *testing.T) {
+	r := useKeys(t)
+	if !r.generated || r.signing == nil || r.signing.alg != jwt.EdDSA {
+		t.Fatalf("got signing key %+v, generated %v", r.signing, r.generated)
+	}
+
+	claims, err := ParseAccessToken(sign(t, r.signing.signer, jwt.WithKeyID(r.signing.id)))
+	if err != nil || claims.UserID != 1 {
+		t.Fatalf("a token of the generated key: %+v, %v", claims, err)
+	}
+}
+
+func TestHS256TokensAreRefused(t *testing.T) {
+	r := useKeys(t)
+
+	signer, err := jwt.NewSignerHS(jwt.HS256, []byte("$ecr3t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{
+		"without key id":          sign(t, signer),
+		"with the signing key id": sign(t, signer, jwt.WithKeyID(r.signing.id)),
+	} {
+		if _, err := ParseAccessToken(token); err == nil {
+			t.Errorf("hs256 token %s is accepted", name)
+		}
+	}
+}
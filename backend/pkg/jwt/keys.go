@@ -0,0 +1,228 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+	"sync"
+
+	"github.com/cristalhq/jwt/v3"
+)
+
+// key is a signing key from the config. Keys given only by the public part
+// can't sign, they verify tokens issued before a rotation.
+type key struct {
+	id       string
+	alg      jwt.Algorithm
+	public   crypto.PublicKey
+	signer   jwt.Signer
+	verifier jwt.Verifier
+}
+
+type keyRing struct {
+	signing *key
+	byID    map[string]*key
+	ordered []*key
+	// generated is set when no signing key is configured and one was made
+	// on start, it lives only as long as the process.
+	generated bool
+}
+
+var (
+	ring     *keyRing
+	ringErr  error
+	ringOnce sync.Once
+)
+
+// Init loads the keys, so a broken config is found on start instead of on
+// the first login.
+func Init() error {
+	r, err := keys()
+	if err != nil {
+		return err
+	}
+	if r.generated {
+		logging.GetLogger().Warnf("jwt signing_key is not configured, tokens are signed with generated key %q: "+
+			"they are not accepted by other replicas and stop working on restart", r.signing.id)
+	}
+	return nil
+}
+
+func keys() (*keyRing, error) {
+	ringOnce.Do(func() {
+		ring, ringErr = loadKeys(session.GetConfig().JWT)
+	})
+	return ring, ringErr
+}
+
+func loadKeys(cfg session.JWT) (*keyRing, error) {
+	r := &keyRing{byID: make(map[string]*key, len(cfg.Keys))}
+
+	for _, k := range cfg.Keys {
+		if k.ID == "" {
+			return nil, fmt.Errorf("jwt key %s has no id", k.Path)
+		}
+		if _, ok := r.byID[k.ID]; ok {
+			return nil, fmt.Errorf("jwt key id %q is used twice", k.ID)
+		}
+		loaded, err := loadKey(k.ID, k.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load jwt key %q due to error %w", k.ID, err)
+		}
+		r.byID[k.ID] = loaded
+		r.ordered = append(r.ordered, loaded)
+	}
+
+	if cfg.SigningKey == "" {
+		generated, err := generateKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate jwt signing key due to error %w", err)
+		}
+		if _, ok := r.byID[generated.id]; ok {
+			return nil, fmt.Errorf("jwt key id %q is used twice", generated.id)
+		}
+		r.byID[generated.id] = generated
+		r.ordered = append(r.ordered, generated)
+		r.signing, r.generated = generated, true
+		return r, nil
+	}
+
+	r.signing = r.byID[cfg.SigningKey]
+	if r.signing == nil {
+		return nil, fmt.Errorf("jwt signing key %q is not among the keys", cfg.SigningKey)
+	}
+	if r.signing.signer == nil {
+		return nil, fmt.Errorf("jwt signing key %q has no private part", cfg.SigningKey)
+	}
+	return r, nil
+}
+
+// generateKey makes an Ed25519 key for setups without a configured one,
+// e.g. a single backend in development.
+func generateKey() (*key, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := jwt.NewSignerEdDSA(priv)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := jwt.NewVerifierEdDSA(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &key{
+		id:       "generated-" + hex.EncodeToString(pub[:8]),
+		alg:      jwt.EdDSA,
+		public:   pub,
+		signer:   signer,
+		verifier: verifier,
+	}, nil
+}
+
+func loadKey(id, path string) (*key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported pem block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := &key{id: id}
+	switch pk := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.alg, k.public = jwt.RS256, &pk.PublicKey
+		if k.signer, err = jwt.NewSignerRS(jwt.RS256, pk); err != nil {
+			return nil, err
+		}
+	case ed25519.PrivateKey:
+		k.alg, k.public = jwt.EdDSA, pk.Public()
+		if k.signer, err = jwt.NewSignerEdDSA(pk); err != nil {
+			return nil, err
+		}
+	case *rsa.PublicKey:
+		k.alg, k.public = jwt.RS256, pk
+	case ed25519.PublicKey:
+		k.alg, k.public = jwt.EdDSA, pk
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		k.verifier, err = jwt.NewVerifierRS(jwt.RS256, pub)
+	case ed25519.PublicKey:
+		k.verifier, err = jwt.NewVerifierEdDSA(pub)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// JSONWebKey is the public part of a key as published in the JWKS, RFC 7517.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// PublicKeys returns every key tokens may be signed with, including the ones
+// kept only to verify tokens issued before a rotation.
+func PublicKeys() ([]JSONWebKey, error) {
+	r, err := keys()
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := make([]JSONWebKey, 0, len(r.ordered))
+	for _, k := range r.ordered {
+		jwk := JSONWebKey{Kid: k.id, Use: "sig", Alg: string(k.alg)}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks, nil
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reports_system/pkg/logging"
+	"sync"
+)
+
+// File appends messages to a local file instead of sending them, or only
+// logs them when path is empty. Meant for development and tests.
+type File struct {
+	from   string
+	path   string
+	logger logging.Logger
+	mu     sync.Mutex
+}
+
+func NewFile(from, path string, logger logging.Logger) *File {
+	return &File{from: from, path: path, logger: logger}
+}
+
+func (f *File) Send(msg Message) error {
+	f.logger.Infof("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	if f.path == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open mail file due to error %w", err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(compose(f.from, msg), "\r\n\r\n"...))
+	return err
+}
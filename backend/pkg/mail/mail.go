@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"fmt"
+	"reports_system/internal/session"
+	"reports_system/pkg/logging"
+)
+
+const (
+	smtpType = "smtp"
+	fileType = "file"
+	logType  = "log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers plain text emails to users.
+type Sender interface {
+	Send(msg Message) error
+}
+
+func New(cfg session.Mail, logger logging.Logger) (Sender, error) {
+	switch cfg.Type {
+	case smtpType:
+		return NewSMTP(cfg.From, cfg.SMTP), nil
+	case fileType:
+		return NewFile(cfg.From, cfg.Path, logger), nil
+	case logType, "":
+		return NewFile(cfg.From, "", logger), nil
+	default:
+		return nil, fmt.Errorf("unknown mail sender type %q", cfg.Type)
+	}
+}
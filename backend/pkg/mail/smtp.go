@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"reports_system/internal/session"
+	"time"
+)
+
+type SMTP struct {
+	from string
+	addr string
+	auth smtp.Auth
+}
+
+func NewSMTP(from string, cfg session.SMTP) *SMTP {
+	s := &SMTP{from: from, addr: net.JoinHostPort(cfg.Host, cfg.Port)}
+	if cfg.Username != "" {
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return s
+}
+
+// Send uses STARTTLS whenever the server offers it.
+func (s *SMTP) Send(msg Message) error {
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, compose(s.from, msg)); err != nil {
+		return fmt.Errorf("failed to send mail to %s due to error %w", msg.To, err)
+	}
+	return nil
+}
+
+func compose(from string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return b.Bytes()
+}
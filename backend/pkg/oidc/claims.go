@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cristalhq/jwt/v3"
+)
+
+// leeway tolerates clocks of the provider and this server drifting apart.
+const leeway = time.Minute
+
+// Claims are the ID token claims used to find or provision an account. Raw
+// keeps all of them, e.g. for the configurable groups claim.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+
+	Raw map[string]json.RawMessage `json:"-"`
+}
+
+// Strings returns a claim that is a string or a list of strings, as groups
+// are in most providers. Anything else is ignored.
+func (c Claims) Strings(name string) []string {
+	raw, ok := c.Raw[name]
+	if !ok {
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil && one != "" {
+		return []string{one}
+	}
+	return nil
+}
+
+func (p *Provider) verify(ctx context.Context, endpoints *discovery, raw, nonce string) (Claims, error) {
+	var claims Claims
+
+	token, err := jwt.ParseString(raw)
+	if err != nil {
+		return claims, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	verifier, err := p.keys.verifier(ctx, token.Header())
+	if err != nil {
+		return claims, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err = verifier.Verify(token.Payload(), token.Signature()); err != nil {
+		return claims, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if err = json.Unmarshal(token.RawClaims(), &claims); err != nil {
+		return claims, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err = json.Unmarshal(token.RawClaims(), &claims.Raw); err != nil {
+		return claims, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != endpoints.Issuer:
+		return claims, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	case !claims.IsForAudience(p.cfg.ClientID):
+		return claims, fmt.Errorf("%w: issued for another client", ErrInvalidToken)
+	case claims.ExpiresAt == nil || !claims.IsValidExpiresAt(now.Add(-leeway)):
+		return claims, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case !claims.IsValidNotBefore(now.Add(leeway)):
+		return claims, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return claims, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	case claims.Subject == "":
+		return claims, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,151 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cristalhq/jwt/v3"
+)
+
+// refreshInterval limits how often keys are fetched again when a token is
+// signed with an unknown key, so forged tokens can't flood the provider.
+const refreshInterval = time.Minute
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet caches the provider keys and refetches them when the provider
+// rotates its signing key.
+type keySet struct {
+	uri      string
+	provider *Provider
+
+	mu      sync.Mutex
+	keys    []jsonWebKey
+	fetched time.Time
+}
+
+func newKeySet(uri string, provider *Provider) *keySet {
+	return &keySet{uri: uri, provider: provider}
+}
+
+func (s *keySet) verifier(ctx context.Context, header jwt.Header) (jwt.Verifier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.find(header)
+	if !ok && time.Since(s.fetched) > refreshInterval {
+		if err := s.fetch(ctx); err != nil {
+			return nil, err
+		}
+		key, ok = s.find(header)
+	}
+	if !ok {
+		return nil, fmt.Errorf("no key %q for %s", header.KeyID, header.Algorithm)
+	}
+	return newVerifier(header.Algorithm, key)
+}
+
+func (s *keySet) find(header jwt.Header) (jsonWebKey, bool) {
+	for _, k := range s.keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if header.KeyID != "" && k.Kid != header.KeyID {
+			continue
+		}
+		if k.Alg != "" && k.Alg != string(header.Algorithm) {
+			continue
+		}
+		return k, true
+	}
+	return jsonWebKey{}, false
+}
+
+func (s *keySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.uri, nil)
+	if err != nil {
+		return err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err = s.provider.do(req, &set); err != nil {
+		return fmt.Errorf("failed to fetch provider keys due to error %w", err)
+	}
+	s.keys = set.Keys
+	s.fetched = time.Now()
+	return nil
+}
+
+// newVerifier supports the algorithms providers actually use, RS* and ES*.
+// HS* is refused, the client secret must not be usable to forge tokens.
+func newVerifier(alg jwt.Algorithm, k jsonWebKey) (jwt.Verifier, error) {
+	switch alg {
+	case jwt.RS256, jwt.RS384, jwt.RS512:
+		if k.Kty != "RSA" {
+			return nil, errors.New("key type does not match algorithm")
+		}
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.NewVerifierRS(alg, &rsa.PublicKey{N: n, E: int(e.Int64())})
+	case jwt.ES256, jwt.ES384, jwt.ES512:
+		if k.Kty != "EC" {
+			return nil, errors.New("key type does not match algorithm")
+		}
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.NewVerifierES(alg, &ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,159 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reports_system/pkg/oidc"
+	"reports_system/pkg/oidc/oidctest"
+	"testing"
+	"time"
+)
+
+const (
+	clientID     = "reports-system"
+	clientSecret = "client-secret"
+	redirectURL  = "http://localhost/api/v1/accounts/oidc/callback"
+)
+
+func newProvider(t *testing.T) (*oidctest.Server, *oidc.Provider) {
+	t.Helper()
+
+	server, err := oidctest.NewServer(clientID, clientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	return server, oidc.NewProvider(oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email"},
+	}, server.Client())
+}
+
+// login goes through the whole flow and returns the verified claims.
+func login(t *testing.T, server *oidctest.Server, p *oidc.Provider, claims map[string]interface{}) (oidc.Claims, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := server.Authorize(authURL, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state" {
+		t.Fatalf("state = %q, the provider must send it back as is", state)
+	}
+	return p.Exchange(ctx, code, "verifier", "nonce")
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	server, p := newProvider(t)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+
+	if got := u.Scheme + "://" + u.Host + u.Path; got != server.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %s", got)
+	}
+	if q.Get("code_challenge") != oidc.Challenge("verifier") {
+		t.Error("code challenge is not the S256 hash of the verifier")
+	}
+	if q.Get("code_verifier") != "" {
+		t.Error("the verifier itself was sent to the browser")
+	}
+	if q.Get("redirect_uri") != redirectURL || q.Get("scope") != "openid email" {
+		t.Errorf("unexpected query %v", q)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	server, p := newProvider(t)
+
+	claims, err := login(t, server, p, map[string]interface{}{
+		"email":          "ivanov@bmstu.ru",
+		"email_verified": true,
+		"groups":         []string{"iu7", "reports-admins"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "subject" || claims.Email != "ivanov@bmstu.ru" || !claims.EmailVerified {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if groups := claims.Strings("groups"); len(groups) != 2 || groups[1] != "reports-admins" {
+		t.Errorf("groups = %v", groups)
+	}
+}
+
+func TestExchangeRejectsInvalidTokens(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"nonce mismatch":  {"nonce": "replayed"},
+		"another issuer":  {"iss": "https://evil.example.com"},
+		"another client":  {"aud": "another-client"},
+		"expired":         {"exp": time.Now().Add(-time.Hour).Unix()},
+		"not valid yet":   {"nbf": time.Now().Add(time.Hour).Unix()},
+		"without subject": {"sub": ""},
+	}
+	for name, claims := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, p := newProvider(t)
+
+			if _, err := login(t, server, p, claims); !errors.Is(err, oidc.ErrInvalidToken) {
+				t.Errorf("err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestExchangeChecksVerifier(t *testing.T) {
+	server, p := newProvider(t)
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := server.Authorize(authURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = p.Exchange(ctx, code, "stolen-code-without-verifier", "nonce"); err == nil {
+		t.Fatal("code was redeemed without the right verifier")
+	}
+}
+
+func TestExchangeCodeOnlyOnce(t *testing.T) {
+	server, p := newProvider(t)
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := server.Authorize(authURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = p.Exchange(ctx, code, "verifier", "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Exchange(ctx, code, "verifier", "nonce"); err == nil {
+		t.Fatal("code was redeemed twice")
+	}
+}
@@ -0,0 +1,52 @@
+package password
+
+import (
+	"bufio"
+	"os"
+	"reports_system/pkg/logging"
+	"strings"
+	"sync"
+)
+
+var (
+	breached     map[string]struct{}
+	breachedOnce sync.Once
+)
+
+// IsBreached looks the password up in the list of leaked passwords, one per
+// line. Comparison ignores case, so variations like Qwerty are caught too.
+// The list is read from path on the first call.
+func IsBreached(password, path string) bool {
+	breachedOnce.Do(func() { loadBreached(path) })
+
+	_, ok := breached[strings.ToLower(password)]
+	return ok
+}
+
+func loadBreached(path string) {
+	breached = make(map[string]struct{})
+
+	if path == "" {
+		return
+	}
+
+	logger := logging.GetLogger()
+
+	f, err := os.Open(path)
+	if err != nil {
+		logger.Errorf("breached passwords list is not loaded due to error %v", err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			breached[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		logger.Errorf("failed to read breached passwords list due to error %v", err)
+	}
+	logger.Infof("loaded %d breached passwords", len(breached))
+}
@@ -0,0 +1,162 @@
+// Package password hashes passwords with the algorithm from the config. The
+// algorithm and its parameters are encoded in the hash, so hashes made with
+// older settings still verify and can be upgraded with NeedsRehash.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reports_system/internal/session"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+
+	// Unusable is stored instead of a hash when the password has to be reset,
+	// no password matches it.
+	Unusable = "!"
+
+	// BcryptMaxBytes is as much of a password as bcrypt hashes.
+	BcryptMaxBytes = 72
+
+	argon2Prefix   = "$argon2id$"
+	argon2SaltSize = 16
+)
+
+var (
+	ErrMismatch        = errors.New("password does not match")
+	ErrMalformedHash   = errors.New("malformed password hash")
+	ErrUnknownHashType = errors.New("unknown password hash algorithm")
+)
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	keyLen  uint32
+}
+
+func Hash(password string) (string, error) {
+	cfg := session.GetConfig().Password
+
+	switch cfg.Algorithm {
+	case Argon2id:
+		return hashArgon2(password, paramsFromConfig(cfg.Argon2))
+	case Bcrypt, "":
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), cfg.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrUnknownHashType, cfg.Algorithm)
+	}
+}
+
+// Verify returns ErrMismatch for a wrong password.
+func Verify(hash, password string) error {
+	if hash == Unusable {
+		return ErrMismatch
+	}
+	if strings.HasPrefix(hash, argon2Prefix) {
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return err
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, params.keyLen)
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	}
+
+	// bcrypt would ignore the rest and let in any password with the same start
+	if len(password) > BcryptMaxBytes {
+		return ErrMismatch
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	return err
+}
+
+// Truncates tells whether the algorithm hashes only BcryptMaxBytes of a password.
+func Truncates(algorithm string) bool {
+	return algorithm == Bcrypt || algorithm == ""
+}
+
+// NeedsRehash tells whether the hash was made with another algorithm or
+// parameters than the configured ones.
+func NeedsRehash(hash string) bool {
+	cfg := session.GetConfig().Password
+
+	if cfg.Algorithm == Argon2id {
+		params, _, _, err := decodeArgon2(hash)
+		return err != nil || params != paramsFromConfig(cfg.Argon2)
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != cfg.BcryptCost
+}
+
+func paramsFromConfig(cfg session.Argon2) argon2Params {
+	return argon2Params{memory: cfg.Memory, time: cfg.Time, threads: cfg.Threads, keyLen: cfg.KeyLen}
+}
+
+// hashArgon2 uses the PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func hashArgon2(password string, params argon2Params) (string, error) {
+	salt := make([]byte, argon2SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, params.keyLen)
+
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix, argon2.Version, params.memory, params.time, params.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	var (
+		params  argon2Params
+		version int
+	)
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrMalformedHash
+	}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads)
+	if err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	params.keyLen = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,24 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerifyRejectsBcryptPasswordsLongerThanHashed(t *testing.T) {
+	p := strings.Repeat("a", BcryptMaxBytes)
+	hash, err := bcrypt.GenerateFromPassword([]byte(p), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Verify(string(hash), p); err != nil {
+		t.Fatalf("the password itself: %v", err)
+	}
+	if err = Verify(string(hash), p+"anything"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("a longer password with the same start: err = %v, want ErrMismatch", err)
+	}
+}
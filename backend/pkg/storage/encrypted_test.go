@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"reports_system/pkg/envelope"
+	"testing"
+)
+
+func testKeyring(t *testing.T, current string, keys map[string][]byte) *envelope.Keyring {
+	t.Helper()
+
+	k, err := envelope.NewKeyring(current, current, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func readBlob(t *testing.T, s Storage, key string) []byte {
+	t.Helper()
+
+	blob, err := s.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blob.Close()
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEncrypted(t *testing.T) {
+	backend, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewEncrypted(backend, testKeyring(t, "old", map[string][]byte{"old": bytes.Repeat([]byte{1}, envelope.KeySize)}))
+	testStorage(t, s)
+
+	data := []byte("содержимое вложения")
+	if err = s.Put("reports/1/blob", bytes.NewReader(data), int64(len(data)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	stored := readBlob(t, backend, "reports/1/blob")
+	if !envelope.IsSealed(stored) || bytes.Contains(stored, data) {
+		t.Fatalf("backend holds %q", stored)
+	}
+}
+
+func TestEncryptedReadsBlobsStoredBefore(t *testing.T) {
+	backend, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("stored before the encryption")
+	if err = backend.Put("reports/1/blob", bytes.NewReader(data), int64(len(data)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewEncrypted(backend, testKeyring(t, "old", map[string][]byte{"old": bytes.Repeat([]byte{1}, envelope.KeySize)}))
+	if got := readBlob(t, s, "reports/1/blob"); !bytes.Equal(got, data) {
+		t.Fatalf("got %q, want %q", got, data)
+	}
+
+	rewrapped, err := s.Rewrap("reports/1/blob", "text/plain")
+	if err != nil || !rewrapped {
+		t.Fatalf("rewrap returned %v, %v", rewrapped, err)
+	}
+	if !envelope.IsSealed(readBlob(t, backend, "reports/1/blob")) {
+		t.Fatal("blob is not sealed after rewrap")
+	}
+	if got := readBlob(t, s, "reports/1/blob"); !bytes.Equal(got, data) {
+		t.Fatalf("got %q after rewrap, want %q", got, data)
+	}
+}
+
+func TestEncryptedRotation(t *testing.T) {
+	backend, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, newKey := bytes.Repeat([]byte{1}, envelope.KeySize), bytes.Repeat([]byte{2}, envelope.KeySize)
+
+	data := []byte("содержимое вложения")
+	old := NewEncrypted(backend, testKeyring(t, "old", map[string][]byte{"old": oldKey}))
+	if err = old.Put("reports/1/blob", bytes.NewReader(data), int64(len(data)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := NewEncrypted(backend, testKeyring(t, "new", map[string][]byte{"old": oldKey, "new": newKey}))
+	if rewrapped, err := rotated.Rewrap("reports/1/blob", "text/plain"); err != nil || !rewrapped {
+		t.Fatalf("rewrap returned %v, %v", rewrapped, err)
+	}
+	if rewrapped, err := rotated.Rewrap("reports/1/blob", "text/plain"); err != nil || rewrapped {
+		t.Fatalf("second rewrap returned %v, %v, want no change", rewrapped, err)
+	}
+
+	onlyNew := NewEncrypted(backend, testKeyring(t, "new", map[string][]byte{"new": newKey}))
+	if got := readBlob(t, onlyNew, "reports/1/blob"); !bytes.Equal(got, data) {
+		t.Fatalf("got %q after rotation, want %q", got, data)
+	}
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reports_system/internal/session"
+)
+
+const (
+	localType = "local"
+	s3Type    = "s3"
+)
+
+var ErrNotFound = errors.New("blob does not exist")
+
+// Storage keeps attachment blobs outside of the database.
+type Storage interface {
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+func New(cfg session.Storage) (Storage, error) {
+	switch cfg.Type {
+	case localType, "":
+		return NewLocal(cfg.Path)
+	case s3Type:
+		return NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", cfg.Type)
+	}
+}
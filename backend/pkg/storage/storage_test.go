@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reports_system/internal/session"
+	"testing"
+)
+
+// testStorage checks the behaviour every backend has to share.
+func testStorage(t *testing.T, s Storage) {
+	t.Helper()
+
+	const key = "reports/1/blob"
+	data := []byte("содержимое вложения")
+
+	if err := s.Put(key, bytes.NewReader(data), int64(len(data)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := s.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %q, want %q", got, data)
+	}
+
+	if err = s.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after delete err = %v, want ErrNotFound", err)
+	}
+	if _, err = s.Get("reports/1/missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get of a missing blob err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+}
+
+func TestLocalRejectsKeysOutsideRoot(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Put("../escape", bytes.NewReader(nil), 0, ""); err == nil {
+		t.Fatal("blob was written outside of the root")
+	}
+}
+
+// TestS3 runs against the MinIO of docker-compose, e.g.
+// TEST_S3_ENDPOINT=localhost:9000 go test ./pkg/storage/
+func TestS3(t *testing.T) {
+	endpoint := os.Getenv("TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("TEST_S3_ENDPOINT is not set")
+	}
+
+	s, err := NewS3(session.S3{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    "attachments-test",
+		AccessKey: "minio",
+		SecretKey: "minio-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+}
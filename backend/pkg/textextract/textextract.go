@@ -0,0 +1,151 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	pdfContentType  = "application/pdf"
+	docxContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	odtContentType  = "application/vnd.oasis.opendocument.text"
+	txtContentType  = "text/plain"
+
+	docxDocument = "word/document.xml"
+	odtContent   = "content.xml"
+)
+
+var ErrUnsupported = errors.New("text extraction is not supported for this content type")
+
+// Supports tells whether Extract knows how to read the given content type.
+func Supports(contentType string) bool {
+	switch {
+	case strings.HasPrefix(contentType, pdfContentType),
+		strings.HasPrefix(contentType, docxContentType),
+		strings.HasPrefix(contentType, odtContentType),
+		strings.HasPrefix(contentType, txtContentType):
+		return true
+	}
+	return false
+}
+
+// Extract returns the plain text of a PDF, DOCX, ODT or TXT document.
+func Extract(data []byte, contentType string) (string, error) {
+	switch {
+	case strings.HasPrefix(contentType, pdfContentType):
+		return extractPDF(data)
+	case strings.HasPrefix(contentType, docxContentType):
+		return extractXMLFromZip(data, docxDocument, docxBreaks)
+	case strings.HasPrefix(contentType, odtContentType):
+		return extractXMLFromZip(data, odtContent, odtBreaks)
+	case strings.HasPrefix(contentType, txtContentType):
+		return extractTXT(data)
+	}
+	return "", ErrUnsupported
+}
+
+func extractPDF(data []byte) (text string, err error) {
+	// the pdf reader panics on some malformed files instead of returning an error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed pdf: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := r.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := io.ReadAll(plain)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// docxBreaks and odtBreaks map element names to the text they stand for.
+var (
+	docxBreaks = map[string]string{"p": "\n", "br": "\n", "cr": "\n", "tab": "\t"}
+	odtBreaks  = map[string]string{"p": "\n", "h": "\n", "line-break": "\n", "tab": "\t", "s": " "}
+)
+
+func extractXMLFromZip(data []byte, name string, breaks map[string]string) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range archive.File {
+		if f.Name != name {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+
+		return extractXML(rc, breaks)
+	}
+
+	return "", fmt.Errorf("%s is missing in the document", name)
+}
+
+func extractXML(r io.Reader, breaks map[string]string) (string, error) {
+	var (
+		sb      strings.Builder
+		decoder = xml.NewDecoder(r)
+	)
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			sb.Write(t)
+		case xml.EndElement:
+			if s, ok := breaks[t.Name.Local]; ok {
+				sb.WriteString(s)
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// extractTXT falls back to cp1251 for files that are not valid utf-8,
+// which is what most old russian text files are.
+func extractTXT(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+
+	decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}